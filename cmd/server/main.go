@@ -11,9 +11,11 @@ import (
 	"time"
 
 	"github.com/Soli0222/spotify-nowplaying/internal/auth"
+	"github.com/Soli0222/spotify-nowplaying/internal/autopost"
 	tokencrypto "github.com/Soli0222/spotify-nowplaying/internal/crypto"
 	"github.com/Soli0222/spotify-nowplaying/internal/handler"
 	"github.com/Soli0222/spotify-nowplaying/internal/metrics"
+	"github.com/Soli0222/spotify-nowplaying/internal/outbox"
 	"github.com/Soli0222/spotify-nowplaying/internal/spotify"
 	"github.com/Soli0222/spotify-nowplaying/internal/store"
 
@@ -119,6 +121,10 @@ func main() {
 		}
 	}
 
+	// バックグラウンドジョブ用のコンテキスト（シャットダウン時にキャンセル）
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
 	// Database接続（オプション - databaseURLが設定されている場合のみ）
 	var db *store.Store
 	var jwtConfig auth.JWTConfig
@@ -149,12 +155,35 @@ func main() {
 
 		jwtConfig = auth.DefaultJWTConfig()
 
+		// 登録済みユーザーは/note・/tweetでも自分のテンプレートを使う
+		h.SetTemplateStore(db)
+
+		// Spotifyトークンは有効期限前に更新してDBに保存する
+		spotifyTokens := spotify.NewTokenSource(spotifyClient, db)
+
 		// API handlers
 		spotifyAuthHandler := handler.NewSpotifyAuthHandler(db, spotifyClient, jwtConfig)
 		miAuthHandler := handler.NewMiAuthHandler(db, jwtConfig)
 		twitterAuthHandler := handler.NewTwitterAuthHandler(db, jwtConfig)
-		settingsHandler := handler.NewSettingsHandler(db, jwtConfig)
-		apiPostHandler := handler.NewAPIPostHandler(db, spotifyClient)
+		mastodonAuthHandler := handler.NewMastodonAuthHandler(db, jwtConfig)
+		blueskyAuthHandler := handler.NewBlueskyAuthHandler(db, jwtConfig)
+		settingsHandler := handler.NewSettingsHandler(db, spotifyTokens, jwtConfig)
+		posters := handler.NewPosterRegistry(handler.DefaultPosters(db)...)
+		apiPostHandler := handler.NewAPIPostHandler(db, spotifyClient, spotifyTokens, posters)
+		templateHandler := handler.NewTemplateHandler(db, posters)
+		postHistoryHandler := handler.NewPostHistoryHandler(db, posters)
+		autoPostConfig := autopost.LoadConfig()
+		autoPostHandler := handler.NewAutoPostHandler(db, autoPostConfig.Enabled)
+
+		// Auto-post scheduler (opt-in via AUTOPOST_ENABLED)
+		if autoPostConfig.Enabled {
+			scheduler := autopost.NewScheduler(autoPostConfig, db, apiPostHandler)
+			go scheduler.Run(jobsCtx)
+		}
+
+		// Retry posts that failed transiently (rate limits, server errors)
+		outboxWorker := outbox.NewWorker(outbox.LoadConfig(), db, apiPostHandler)
+		go outboxWorker.Run(jobsCtx)
 
 		// API routes
 		api := e.Group("/api")
@@ -166,6 +195,8 @@ func main() {
 
 		// Public API post route (authenticated by URL token + optional header token)
 		api.GET("/post/:token", apiPostHandler.PostNowPlaying)
+		// JSON post route with per-request options (authenticated by header token)
+		api.POST("/post", apiPostHandler.CreatePost)
 
 		// MiAuth callback (no JWT required, uses session)
 		api.GET("/miauth/callback", miAuthHandler.CallbackMiAuth)
@@ -173,6 +204,9 @@ func main() {
 		// Twitter callback (no JWT required, uses session)
 		api.GET("/twitter/callback", twitterAuthHandler.CallbackTwitterAuth)
 
+		// Mastodon callback (no JWT required, uses session)
+		api.GET("/mastodon/callback", mastodonAuthHandler.CallbackMastodonAuth)
+
 		// Protected routes (require JWT)
 		protected := api.Group("")
 		protected.Use(auth.JWTMiddleware(jwtConfig))
@@ -193,10 +227,34 @@ func main() {
 		protected.GET("/twitter/start", twitterAuthHandler.StartTwitterAuth)
 		protected.DELETE("/twitter", twitterAuthHandler.DisconnectTwitter)
 
+		// Mastodon
+		protected.POST("/mastodon/start", mastodonAuthHandler.StartMastodonAuth)
+		protected.DELETE("/mastodon", mastodonAuthHandler.DisconnectMastodon)
+
+		// Bluesky
+		protected.POST("/bluesky", blueskyAuthHandler.ConnectBluesky)
+		protected.DELETE("/bluesky", blueskyAuthHandler.DisconnectBluesky)
+
+		// Post preview (dry run)
+		protected.POST("/post/preview", apiPostHandler.PreviewPost)
+
+		// Post history
+		protected.GET("/posts", postHistoryHandler.ListPosts)
+		protected.DELETE("/posts/:id", postHistoryHandler.DeletePost)
+
 		// Settings
 		protected.POST("/settings/header-token", settingsHandler.GenerateHeaderToken)
 		protected.DELETE("/settings/header-token", settingsHandler.DisableHeaderToken)
 		protected.POST("/settings/api-url-token/regenerate", settingsHandler.RegenerateAPIURLToken)
+		protected.GET("/settings/posting", settingsHandler.GetPostSettings)
+		protected.PUT("/settings/posting", settingsHandler.UpdatePostSettings)
+		protected.GET("/settings/misskey", settingsHandler.GetMisskeySettings)
+		protected.PUT("/settings/misskey", settingsHandler.UpdateMisskeySettings)
+		protected.GET("/settings/autopost", autoPostHandler.GetAutoPostSettings)
+		protected.PUT("/settings/autopost", autoPostHandler.UpdateAutoPostSettings)
+		protected.GET("/settings/templates", templateHandler.GetTemplates)
+		protected.PUT("/settings/templates/:platform", templateHandler.UpdateTemplate)
+		protected.POST("/settings/templates/preview", templateHandler.PreviewTemplate)
 
 		// Serve SPA static files
 		e.Static("/assets", "frontend/dist/assets")
@@ -245,6 +303,9 @@ func main() {
 
 	log.Println("Shutting down servers...")
 
+	// バックグラウンドジョブを停止
+	cancelJobs()
+
 	// グレースフルシャットダウン（タイムアウト10秒）
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -0,0 +1,229 @@
+package autopost
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/handler"
+	"github.com/Soli0222/spotify-nowplaying/internal/spotify"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultInterval    = 30 * time.Second
+	defaultConcurrency = 4
+)
+
+// Config holds auto-post scheduler settings
+type Config struct {
+	Enabled     bool
+	Interval    time.Duration
+	Concurrency int
+}
+
+// LoadConfig loads the scheduler config from environment variables
+func LoadConfig() Config {
+	config := Config{
+		Enabled:     false, // opt-in
+		Interval:    defaultInterval,
+		Concurrency: defaultConcurrency,
+	}
+
+	// AUTOPOST_ENABLED (default: false)
+	if val := os.Getenv("AUTOPOST_ENABLED"); val == "true" {
+		config.Enabled = true
+	}
+
+	// AUTOPOST_INTERVAL (Go duration, e.g. "30s")
+	if val := os.Getenv("AUTOPOST_INTERVAL"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= time.Second {
+			config.Interval = d
+		}
+	}
+
+	// AUTOPOST_CONCURRENCY (number of users polled in parallel)
+	if val := os.Getenv("AUTOPOST_CONCURRENCY"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			config.Concurrency = n
+		}
+	}
+
+	return config
+}
+
+// Store is the subset of store.Store used by the scheduler
+type Store interface {
+	ListEnabledAutoPostSettings(ctx context.Context) ([]store.AutoPostSettings, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (*store.User, error)
+	ClaimAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string, lease time.Duration) (bool, error)
+	CompleteAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string) error
+	SkipAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string) error
+	ReleaseAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string) error
+}
+
+// Publisher fetches playback and posts it on behalf of a user
+type Publisher interface {
+	FetchPlayback(ctx context.Context, user *store.User) (*spotify.PlayerResponse, error)
+	PublishPlayback(ctx context.Context, user *store.User, playerResp *spotify.PlayerResponse, opts handler.PublishOptions) handler.PostResponse
+}
+
+// Scheduler polls Spotify for users with auto-post enabled and posts when the track changes
+type Scheduler struct {
+	config    Config
+	store     Store
+	publisher Publisher
+	logger    *slog.Logger
+}
+
+// NewScheduler creates a new Scheduler
+func NewScheduler(config Config, s Store, publisher Publisher) *Scheduler {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultConcurrency
+	}
+	return &Scheduler{
+		config:    config,
+		store:     s,
+		publisher: publisher,
+		logger:    slog.Default(),
+	}
+}
+
+// Run polls every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	s.logger.Info("autopost scheduler started", "interval", s.config.Interval.String())
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.Poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("autopost scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll checks every enabled user once
+func (s *Scheduler) Poll(ctx context.Context) {
+	settingsList, err := s.store.ListEnabledAutoPostSettings(ctx)
+	if err != nil {
+		s.logger.Error("failed to list autopost settings", "error", err)
+		return
+	}
+
+	sem := make(chan struct{}, s.config.Concurrency)
+	var wg sync.WaitGroup
+	for _, settings := range settingsList {
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(settings store.AutoPostSettings) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.pollUser(ctx, settings)
+		}(settings)
+	}
+	wg.Wait()
+}
+
+// pollUser posts the user's playback if it changed since the last auto-post
+func (s *Scheduler) pollUser(ctx context.Context, settings store.AutoPostSettings) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Interval)
+	defer cancel()
+
+	user, err := s.store.GetUserByID(ctx, settings.UserID)
+	if err != nil || user == nil {
+		s.logger.Error("failed to get autopost user", "user_id", settings.UserID, "error", err)
+		return
+	}
+
+	playerResp, err := s.publisher.FetchPlayback(ctx, user)
+	if err != nil {
+		s.logger.Warn("failed to fetch playback", "user_id", user.ID, "error", err)
+		return
+	}
+
+	if playerResp == nil || !playerResp.IsPlaying || playerResp.Item.URI == "" {
+		return
+	}
+	if settings.LastItemURI.Valid && settings.LastItemURI.String == playerResp.Item.URI {
+		return
+	}
+
+	target, err := handler.ParsePostTarget(settings.Target)
+	if err != nil {
+		s.logger.Error("invalid autopost target", "user_id", user.ID, "target", settings.Target, "error", err)
+		return
+	}
+
+	// The claim outlives the poll timeout so that it does not expire while posting
+	itemURI := playerResp.Item.URI
+	claimed, err := s.store.ClaimAutoPostItem(ctx, user.ID, itemURI, 2*s.config.Interval)
+	if err != nil {
+		s.logger.Error("failed to claim autopost item", "user_id", user.ID, "error", err)
+		return
+	}
+	if !claimed {
+		// Another poll (or replica) already posted or is posting this item
+		return
+	}
+
+	resp := s.publisher.PublishPlayback(ctx, user, playerResp, handler.PublishOptions{Target: target})
+	s.logger.Info("autopost published", "user_id", user.ID, "item_uri", itemURI, "success", resp.Success, "results", resp.Results)
+
+	// Record the outcome even if the poll timed out while posting
+	ctx = context.WithoutCancel(ctx)
+	switch {
+	case delivered(resp):
+		if err := s.store.CompleteAutoPostItem(ctx, user.ID, itemURI); err != nil {
+			s.logger.Error("failed to complete autopost item", "user_id", user.ID, "error", err)
+		}
+	case retryNotQueued(resp):
+		// A transient failure could not be queued: try the item again on the next poll
+		if err := s.store.ReleaseAutoPostItem(ctx, user.ID, itemURI); err != nil {
+			s.logger.Error("failed to release autopost item", "user_id", user.ID, "error", err)
+		}
+	default:
+		// Permanent failures would fail again, and a timed out post may already exist
+		if err := s.store.SkipAutoPostItem(ctx, user.ID, itemURI); err != nil {
+			s.logger.Error("failed to skip autopost item", "user_id", user.ID, "error", err)
+		}
+	}
+}
+
+// delivered reports whether the item reached at least one platform: it was posted, queued for
+// a retry by the outbox, or had already been posted within the dedupe window
+func delivered(resp handler.PostResponse) bool {
+	for _, result := range resp.Results {
+		if result == "success" || result == "skipped_duplicate" || strings.HasPrefix(result, "retrying") {
+			return true
+		}
+	}
+	return false
+}
+
+// retryNotQueued reports whether a platform failed transiently but the retry could not be queued
+func retryNotQueued(resp handler.PostResponse) bool {
+	for _, result := range resp.Results {
+		if strings.HasPrefix(result, "retry not queued") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package autopost
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/handler"
+	"github.com/Soli0222/spotify-nowplaying/internal/spotify"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore はテスト用のインメモリストア
+type fakeStore struct {
+	mu       sync.Mutex
+	settings map[uuid.UUID]*store.AutoPostSettings
+	// pending は投稿中として確保されたアイテム（ユーザーごと）
+	pending map[uuid.UUID]string
+	// skipped は失敗として記録したアイテムの数
+	skipped int
+}
+
+func newFakeStore(settings ...store.AutoPostSettings) *fakeStore {
+	s := &fakeStore{settings: make(map[uuid.UUID]*store.AutoPostSettings), pending: make(map[uuid.UUID]string)}
+	for i := range settings {
+		st := settings[i]
+		s.settings[st.UserID] = &st
+	}
+	return s
+}
+
+func (s *fakeStore) ListEnabledAutoPostSettings(ctx context.Context) ([]store.AutoPostSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []store.AutoPostSettings
+	for _, st := range s.settings {
+		if st.Enabled {
+			list = append(list, *st)
+		}
+	}
+	return list, nil
+}
+
+func (s *fakeStore) GetUserByID(ctx context.Context, id uuid.UUID) (*store.User, error) {
+	return &store.User{ID: id}, nil
+}
+
+func (s *fakeStore) ClaimAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string, lease time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.settings[userID]
+	if !ok || !st.Enabled || (st.LastItemURI.Valid && st.LastItemURI.String == itemURI) || s.pending[userID] != "" {
+		return false, nil
+	}
+	s.pending[userID] = itemURI
+	return true, nil
+}
+
+func (s *fakeStore) CompleteAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[userID] == itemURI {
+		delete(s.pending, userID)
+		s.settings[userID].LastItemURI = sql.NullString{String: itemURI, Valid: true}
+	}
+	return nil
+}
+
+func (s *fakeStore) SkipAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[userID] == itemURI {
+		delete(s.pending, userID)
+		s.settings[userID].LastItemURI = sql.NullString{String: itemURI, Valid: true}
+		s.skipped++
+	}
+	return nil
+}
+
+func (s *fakeStore) ReleaseAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[userID] == itemURI {
+		delete(s.pending, userID)
+	}
+	return nil
+}
+
+// fakePublisher はテスト用のパブリッシャー
+type fakePublisher struct {
+	mu        sync.Mutex
+	playback  *spotify.PlayerResponse
+	published []handler.PostTarget
+	// results はプラットフォームごとの投稿結果（nilの場合は成功）
+	results map[string]string
+}
+
+func (p *fakePublisher) FetchPlayback(ctx context.Context, user *store.User) (*spotify.PlayerResponse, error) {
+	return p.playback, nil
+}
+
+func (p *fakePublisher) PublishPlayback(ctx context.Context, user *store.User, playerResp *spotify.PlayerResponse, opts handler.PublishOptions) handler.PostResponse {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published = append(p.published, opts.Target)
+	if p.results != nil {
+		return handler.PostResponse{Results: p.results}
+	}
+	return handler.PostResponse{Success: true, Results: map[string]string{"misskey": "success"}}
+}
+
+func playing(uri string) *spotify.PlayerResponse {
+	return &spotify.PlayerResponse{
+		IsPlaying:            true,
+		CurrentlyPlayingType: "track",
+		Item:                 spotify.Item{Name: "Test Song", URI: uri},
+	}
+}
+
+func TestScheduler_PostsOnTrackChange(t *testing.T) {
+	userID := uuid.New()
+	s := newFakeStore(store.AutoPostSettings{UserID: userID, Enabled: true, Target: "misskey"})
+	p := &fakePublisher{playback: playing("spotify:track:1")}
+	scheduler := NewScheduler(Config{Interval: time.Second}, s, p)
+
+	scheduler.Poll(context.Background())
+	scheduler.Poll(context.Background())
+
+	require.Len(t, p.published, 1)
+	assert.Equal(t, handler.PostTargetMisskey, p.published[0])
+
+	p.playback = playing("spotify:track:2")
+	scheduler.Poll(context.Background())
+
+	assert.Len(t, p.published, 2)
+}
+
+func TestScheduler_RecordsOutcome(t *testing.T) {
+	tests := []struct {
+		name        string
+		results     map[string]string
+		wantRetried bool
+		wantSkipped bool
+	}{
+		{
+			name:        "全プラットフォームで恒久的に失敗",
+			results:     map[string]string{"misskey": "error: misskey api error: 400 - bad request", "twitter": "reconnect required"},
+			wantSkipped: true,
+		},
+		{
+			name:        "タイムアウトは投稿済みの可能性があるので再投稿しない",
+			results:     map[string]string{"misskey": "timeout"},
+			wantSkipped: true,
+		},
+		{
+			name:        "未連携",
+			results:     map[string]string{"misskey": "not connected", "twitter": "not connected"},
+			wantSkipped: true,
+		},
+		{
+			name:        "再試行キューに入れられなかった",
+			results:     map[string]string{"misskey": "retry not queued: misskey api error: 502 - bad gateway", "twitter": "error: boom"},
+			wantRetried: true,
+		},
+		{
+			name:    "一部のプラットフォームで成功",
+			results: map[string]string{"misskey": "error: boom", "twitter": "success"},
+		},
+		{
+			name:    "再試行キューに入った",
+			results: map[string]string{"misskey": "retrying: misskey api error: 502 - bad gateway"},
+		},
+		{
+			name:    "重複としてスキップされた",
+			results: map[string]string{"misskey": "skipped_duplicate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			s := newFakeStore(store.AutoPostSettings{UserID: userID, Enabled: true, Target: "both"})
+			p := &fakePublisher{playback: playing("spotify:track:1"), results: tt.results}
+			scheduler := NewScheduler(Config{Interval: time.Second}, s, p)
+
+			scheduler.Poll(context.Background())
+			scheduler.Poll(context.Background())
+
+			if tt.wantRetried {
+				assert.Len(t, p.published, 2)
+				assert.False(t, s.settings[userID].LastItemURI.Valid)
+			} else {
+				assert.Len(t, p.published, 1)
+				assert.Equal(t, "spotify:track:1", s.settings[userID].LastItemURI.String)
+			}
+			if tt.wantSkipped {
+				assert.Equal(t, 1, s.skipped)
+			} else {
+				assert.Zero(t, s.skipped)
+			}
+			assert.Empty(t, s.pending)
+		})
+	}
+}
+
+func TestScheduler_SkipsPausedPlayback(t *testing.T) {
+	userID := uuid.New()
+	s := newFakeStore(store.AutoPostSettings{UserID: userID, Enabled: true, Target: "both"})
+	paused := playing("spotify:track:1")
+	paused.IsPlaying = false
+	p := &fakePublisher{playback: paused}
+	scheduler := NewScheduler(Config{Interval: time.Second}, s, p)
+
+	scheduler.Poll(context.Background())
+
+	assert.Empty(t, p.published)
+}
+
+func TestScheduler_SkipsDisabledUsers(t *testing.T) {
+	s := newFakeStore(store.AutoPostSettings{UserID: uuid.New(), Enabled: false, Target: "both"})
+	p := &fakePublisher{playback: playing("spotify:track:1")}
+	scheduler := NewScheduler(Config{Interval: time.Second}, s, p)
+
+	scheduler.Poll(context.Background())
+
+	assert.Empty(t, p.published)
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("AUTOPOST_ENABLED", "")
+	t.Setenv("AUTOPOST_INTERVAL", "")
+	t.Setenv("AUTOPOST_CONCURRENCY", "")
+
+	config := LoadConfig()
+	assert.False(t, config.Enabled)
+	assert.Equal(t, defaultInterval, config.Interval)
+	assert.Equal(t, defaultConcurrency, config.Concurrency)
+
+	t.Setenv("AUTOPOST_ENABLED", "true")
+	t.Setenv("AUTOPOST_INTERVAL", "1m")
+	t.Setenv("AUTOPOST_CONCURRENCY", "8")
+
+	config = LoadConfig()
+	assert.True(t, config.Enabled)
+	assert.Equal(t, time.Minute, config.Interval)
+	assert.Equal(t, 8, config.Concurrency)
+}
+
+func TestLoadConfig_InvalidValues(t *testing.T) {
+	t.Setenv("AUTOPOST_INTERVAL", "100ms")
+	t.Setenv("AUTOPOST_CONCURRENCY", "-1")
+
+	config := LoadConfig()
+	assert.Equal(t, defaultInterval, config.Interval)
+	assert.Equal(t, defaultConcurrency, config.Concurrency)
+}
@@ -1,32 +1,68 @@
 package handler
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Soli0222/spotify-nowplaying/internal/auth"
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
 	"github.com/Soli0222/spotify-nowplaying/internal/spotify"
 	"github.com/Soli0222/spotify-nowplaying/internal/store"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
+// PostStore is the subset of store.Store used by APIPostHandler
+type PostStore interface {
+	GetUserByID(ctx context.Context, id uuid.UUID) (*store.User, error)
+	GetUserByAPIToken(ctx context.Context, apiToken uuid.UUID) (*store.User, error)
+	GetUserByAPIHeaderTokenHash(ctx context.Context, tokenHash string) (*store.User, error)
+	GetPostTemplates(ctx context.Context, userID uuid.UUID) (map[string]string, error)
+	GetPostSettings(ctx context.Context, userID uuid.UUID) (*store.PostSettings, error)
+	CreatePost(ctx context.Context, post *store.Post) error
+	EnqueuePost(ctx context.Context, post *store.Post, payload []byte, nextAttemptAt time.Time) error
+	HasRecentPost(ctx context.Context, userID uuid.UUID, platform, itemURI string, since time.Time) (bool, error)
+	GetLatestPost(ctx context.Context, userID uuid.UUID, platform string, since time.Time) (*store.Post, error)
+	ReserveIdempotencyKey(ctx context.Context, userID uuid.UUID, key, requestHash string, ttl, lease time.Duration) (*store.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, statusCode int, responseBody []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, userID uuid.UUID, key string) error
+}
+
+// defaultPostTimeout bounds how long posting to a single platform may take
+const defaultPostTimeout = 20 * time.Second
+
 // APIPostHandler handles API-based posting
 type APIPostHandler struct {
-	store         *store.Store
+	store         PostStore
 	spotifyClient spotify.Client
+	spotifyTokens *spotify.TokenSource
+	posters       *PosterRegistry
+	// postTimeout is the per-platform deadline, derived from the request context
+	postTimeout time.Duration
+	// fetchArtwork downloads the artwork attached to posts
+	fetchArtwork func(ctx context.Context, imageURL string) (*Artwork, error)
+	logger       *slog.Logger
 }
 
 // NewAPIPostHandler creates a new APIPostHandler
-func NewAPIPostHandler(s *store.Store, client spotify.Client) *APIPostHandler {
+func NewAPIPostHandler(s PostStore, client spotify.Client, tokens *spotify.TokenSource, posters *PosterRegistry) *APIPostHandler {
 	return &APIPostHandler{
 		store:         s,
 		spotifyClient: client,
+		spotifyTokens: tokens,
+		posters:       posters,
+		postTimeout:   defaultPostTimeout,
+		fetchArtwork:  downloadArtwork,
+		logger:        slog.Default(),
 	}
 }
 
@@ -34,28 +70,144 @@ func NewAPIPostHandler(s *store.Store, client spotify.Client) *APIPostHandler {
 type PostTarget string
 
 const (
-	PostTargetMisskey PostTarget = "misskey"
-	PostTargetTwitter PostTarget = "twitter"
-	PostTargetBoth    PostTarget = "both"
+	PostTargetMisskey  PostTarget = "misskey"
+	PostTargetTwitter  PostTarget = "twitter"
+	PostTargetMastodon PostTarget = "mastodon"
+	PostTargetBluesky  PostTarget = "bluesky"
+	PostTargetBoth     PostTarget = "both" // misskey and twitter
 )
 
+// postPlatforms lists the individual platforms in canonical order
+var postPlatforms = []PostTarget{PostTargetMisskey, PostTargetTwitter, PostTargetMastodon, PostTargetBluesky}
+
+// Platforms returns the individual platforms of the target in canonical order
+func (t PostTarget) Platforms() []string {
+	var platforms []string
+	for _, platform := range postPlatforms {
+		if t.Includes(platform) {
+			platforms = append(platforms, string(platform))
+		}
+	}
+	return platforms
+}
+
+// Includes reports whether the target posts to the given platform
+func (t PostTarget) Includes(platform PostTarget) bool {
+	for _, p := range strings.Split(string(t), ",") {
+		if PostTarget(p) == platform {
+			return true
+		}
+		if PostTarget(p) == PostTargetBoth && (platform == PostTargetMisskey || platform == PostTargetTwitter) {
+			return true
+		}
+	}
+	return false
+}
+
 // PostResponse represents the response from posting
 type PostResponse struct {
-	Success bool              `json:"success"`
-	Message string            `json:"message,omitempty"`
-	Results map[string]string `json:"results,omitempty"`
+	Success  bool                   `json:"success"`
+	Message  string                 `json:"message,omitempty"`
+	Results  map[string]string      `json:"results,omitempty"`
+	Previews map[string]PostPreview `json:"previews,omitempty"`
+	// JustPlayed is set when nothing was playing and the last played track was posted instead
+	JustPlayed bool `json:"just_played,omitempty"`
 }
 
-// MisskeyNoteRequest represents the request body for creating a Misskey note
-type MisskeyNoteRequest struct {
-	I          string `json:"i"`
-	Text       string `json:"text"`
-	Visibility string `json:"visibility,omitempty"`
+// PostPreview is what would be posted to a platform in a dry run
+type PostPreview struct {
+	Text      string `json:"text"`
+	Length    int    `json:"length"`
+	MaxLength int    `json:"max_length"`
+	// Truncated reports whether the track, artist or show name was shortened to fit
+	Truncated bool `json:"truncated"`
+	// Media is the URL of the artwork that would be attached
+	Media string `json:"media,omitempty"`
+	// ReplyTo is the remote ID of the post this would reply to in a session
+	ReplyTo string `json:"reply_to,omitempty"`
+	// Duplicate reports whether the post would be skipped as a duplicate without force
+	Duplicate bool `json:"duplicate"`
 }
 
-// TwitterTweetRequest represents the request body for creating a Twitter tweet
-type TwitterTweetRequest struct {
-	Text string `json:"text"`
+// maxPostCommentLength is the maximum length of the comment added to a post
+const maxPostCommentLength = 500
+
+// PublishOptions holds per-request posting options
+type PublishOptions struct {
+	Target PostTarget
+	// Media overrides the user's attach-media setting when set
+	Media *bool
+	// Force posts even if the item was already posted within the dedupe window
+	Force bool
+	// Visibility overrides the platform default visibility
+	Visibility Visibility
+	// Template overrides the user's templates for all platforms when set
+	Template string
+	// Comment is added above the rendered text when set
+	Comment string
+	// DryRun renders the posts without sending them
+	DryRun bool
+	// Misskey overrides the user's Misskey note options
+	Misskey *MisskeyNoteOptions
+	// JustPlayed marks the playback as the last played track rather than the current one
+	JustPlayed bool
+}
+
+// APIPostRequest is the JSON body of POST /api/post
+type APIPostRequest struct {
+	Targets    []string `json:"targets"`
+	Visibility string   `json:"visibility"`
+	Template   string   `json:"template"`
+	Comment    string   `json:"comment"`
+	Media      *bool    `json:"media"`
+	Force      bool     `json:"force"`
+	DryRun     bool     `json:"dry_run"`
+	// Misskey overrides the user's Misskey note options for this post
+	Misskey *MisskeyNoteOptions `json:"misskey"`
+}
+
+// PublishOptions validates the request and converts it to PublishOptions
+func (r *APIPostRequest) PublishOptions() (PublishOptions, error) {
+	target, err := ParsePostTargetList(strings.Join(r.Targets, ","))
+	if err != nil {
+		return PublishOptions{}, err
+	}
+	if target == "" {
+		target = PostTargetBoth
+	}
+
+	visibility, err := ParseVisibility(r.Visibility)
+	if err != nil {
+		return PublishOptions{}, err
+	}
+
+	if strings.TrimSpace(r.Template) != "" {
+		if err := posttemplate.Validate(r.Template); err != nil {
+			return PublishOptions{}, fmt.Errorf("invalid template: %w", err)
+		}
+	}
+
+	if r.Misskey != nil {
+		if err := r.Misskey.Validate(); err != nil {
+			return PublishOptions{}, fmt.Errorf("invalid misskey options: %w", err)
+		}
+	}
+
+	comment := strings.TrimSpace(r.Comment)
+	if utf8.RuneCountInString(comment) > maxPostCommentLength {
+		return PublishOptions{}, fmt.Errorf("comment must be at most %d characters", maxPostCommentLength)
+	}
+
+	return PublishOptions{
+		Target:     target,
+		Media:      r.Media,
+		Force:      r.Force,
+		Visibility: visibility,
+		Template:   strings.TrimSpace(r.Template),
+		Comment:    comment,
+		DryRun:     r.DryRun,
+		Misskey:    r.Misskey,
+	}, nil
 }
 
 // PostNowPlaying posts the currently playing track to configured platforms
@@ -86,214 +238,583 @@ func (h *APIPostHandler) PostNowPlaying(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, PostResponse{Success: false, Message: "header token is required"})
 	}
 
+	providedToken, err := bearerToken(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, PostResponse{Success: false, Message: err.Error()})
+	}
+
+	if auth.HashToken(providedToken) != user.APIHeaderTokenHash.String {
+		return c.JSON(http.StatusUnauthorized, PostResponse{Success: false, Message: "invalid token"})
+	}
+
+	target, err := ParsePostTarget(c.QueryParam("target"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, PostResponse{Success: false, Message: err.Error()})
+	}
+	opts := PublishOptions{Target: target}
+	if val := c.QueryParam("media"); val != "" {
+		media, err := strconv.ParseBool(val)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, PostResponse{Success: false, Message: "invalid media parameter"})
+		}
+		opts.Media = &media
+	}
+	if val := c.QueryParam("force"); val != "" {
+		force, err := strconv.ParseBool(val)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, PostResponse{Success: false, Message: "invalid force parameter"})
+		}
+		opts.Force = force
+	}
+	if val := c.QueryParam("dry_run"); val != "" {
+		dryRun, err := strconv.ParseBool(val)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, PostResponse{Success: false, Message: "invalid dry_run parameter"})
+		}
+		opts.DryRun = dryRun
+	}
+
+	return h.withIdempotency(c, user, "GET /api/post", opts, func() (int, PostResponse) {
+		playback, err := h.fetchPostPlayback(ctx, user)
+		if err != nil {
+			return postErrorStatus(err), PostResponse{Success: false, Message: err.Error()}
+		}
+		opts.JustPlayed = playback.justPlayed
+		return http.StatusOK, playback.withHint(h.PublishPlayback(ctx, user, playback.resp, opts))
+	})
+}
+
+// CreatePost posts the currently playing track with the options in the JSON body
+// POST /api/post
+func (h *APIPostHandler) CreatePost(c echo.Context) error {
+	providedToken, err := bearerToken(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, PostResponse{Success: false, Message: err.Error()})
+	}
+
+	ctx := c.Request().Context()
+
+	user, err := h.store.GetUserByAPIHeaderTokenHash(ctx, auth.HashToken(providedToken))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, PostResponse{Success: false, Message: "database error"})
+	}
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, PostResponse{Success: false, Message: "invalid token"})
+	}
+
+	var req APIPostRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, PostResponse{Success: false, Message: "invalid request body"})
+	}
+	opts, err := req.PublishOptions()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, PostResponse{Success: false, Message: err.Error()})
+	}
+
+	return h.withIdempotency(c, user, "POST /api/post", opts, func() (int, PostResponse) {
+		playback, err := h.fetchPostPlayback(ctx, user)
+		if err != nil {
+			return postErrorStatus(err), PostResponse{Success: false, Message: err.Error()}
+		}
+		opts.JustPlayed = playback.justPlayed
+		return http.StatusOK, playback.withHint(h.PublishPlayback(ctx, user, playback.resp, opts))
+	})
+}
+
+// PreviewPost renders what would be posted for the current user's playback without posting
+// POST /api/post/preview
+func (h *APIPostHandler) PreviewPost(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req APIPostRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+	opts, err := req.PublishOptions()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	opts.DryRun = true
+
+	ctx := c.Request().Context()
+	user, err := h.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get user"})
+	}
+	if user == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+
+	playback, err := h.fetchPostPlayback(ctx, user)
+	if err != nil {
+		return c.JSON(postErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	opts.JustPlayed = playback.justPlayed
+	return c.JSON(http.StatusOK, playback.withHint(h.PublishPlayback(ctx, user, playback.resp, opts)))
+}
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" header
+func bearerToken(c echo.Context) (string, error) {
 	authHeader := c.Request().Header.Get("Authorization")
 	if authHeader == "" {
-		return c.JSON(http.StatusUnauthorized, PostResponse{Success: false, Message: "authorization header required"})
+		return "", errors.New("authorization header required")
 	}
 
 	// Expect "Bearer <token>"
 	parts := strings.SplitN(authHeader, " ", 2)
-	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-		return c.JSON(http.StatusUnauthorized, PostResponse{Success: false, Message: "invalid authorization header format"})
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" || parts[1] == "" {
+		return "", errors.New("invalid authorization header format")
 	}
+	return parts[1], nil
+}
 
-	providedToken := parts[1]
-	providedHash := auth.HashToken(providedToken)
+// ParsePostTarget parses a target query value (default: both). A single unknown
+// value falls back to both for compatibility with older clients, but a list
+// containing an unknown entry is rejected.
+func ParsePostTarget(value string) (PostTarget, error) {
+	target, err := ParsePostTargetList(value)
+	if err != nil {
+		if strings.Contains(value, ",") {
+			return "", err
+		}
+		return PostTargetBoth, nil
+	}
+	if target == "" {
+		return PostTargetBoth, nil
+	}
+	return target, nil
+}
 
-	if providedHash != user.APIHeaderTokenHash.String {
-		return c.JSON(http.StatusUnauthorized, PostResponse{Success: false, Message: "invalid token"})
+// ParsePostTargetList parses a single target or a comma-separated list such as
+// "misskey,mastodon" into its canonical form. It returns "" for an empty value.
+func ParsePostTargetList(value string) (PostTarget, error) {
+	var requested PostTarget
+	for _, part := range strings.Split(strings.ToLower(value), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		switch PostTarget(part) {
+		case PostTargetMisskey, PostTargetTwitter, PostTargetMastodon, PostTargetBluesky, PostTargetBoth:
+			requested += "," + PostTarget(part)
+		default:
+			return "", fmt.Errorf("unknown target: %s", part)
+		}
 	}
 
-	// Get target from query param (default: both)
-	targetStr := c.QueryParam("target")
-	var target PostTarget
-	switch strings.ToLower(targetStr) {
-	case "misskey":
-		target = PostTargetMisskey
-	case "twitter":
-		target = PostTargetTwitter
-	default:
-		target = PostTargetBoth
+	target := PostTarget(strings.Join(requested.Platforms(), ","))
+	if target == PostTargetMisskey+","+PostTargetTwitter {
+		return PostTargetBoth, nil
 	}
+	return target, nil
+}
 
-	// Check if Spotify token is available
-	if !user.SpotifyAccessToken.Valid || user.SpotifyAccessToken.String == "" {
-		return c.JSON(http.StatusBadRequest, PostResponse{Success: false, Message: "spotify not connected"})
+// postError is an error with the HTTP status to return from the post API
+type postError struct {
+	status  int
+	message string
+}
+
+func (e *postError) Error() string {
+	return e.message
+}
+
+// postErrorStatus returns the HTTP status for an error returned by FetchPlayback
+func postErrorStatus(err error) int {
+	if pe, ok := err.(*postError); ok {
+		return pe.status
+	}
+	return http.StatusInternalServerError
+}
+
+// FetchPlayback gets the user's current playback from Spotify. The access token is
+// refreshed ahead of its stored expiry, and once more if Spotify still rejects it.
+func (h *APIPostHandler) FetchPlayback(ctx context.Context, user *store.User) (*spotify.PlayerResponse, error) {
+	accessToken, err := h.spotifyTokens.AccessToken(ctx, user)
+	if err != nil {
+		return nil, spotifyTokenError(err)
 	}
 
 	// Get currently playing from Spotify
-	accessToken := user.SpotifyAccessToken.String
-	playerResp, _, err := h.spotifyClient.GetPlayerData(accessToken)
+	playerResp, _, err := h.spotifyClient.GetPlayerData(ctx, accessToken)
 	if err != nil {
-		if apiErr, ok := spotify.IsAPIError(err); ok {
-			if apiErr.StatusCode == 401 {
-				// Token expired, try to refresh
-				if !user.SpotifyRefreshToken.Valid || user.SpotifyRefreshToken.String == "" {
-					return c.JSON(http.StatusUnauthorized, PostResponse{Success: false, Message: "spotify token expired and no refresh token available"})
-				}
+		apiErr, ok := spotify.IsAPIError(err)
+		if !ok {
+			return nil, &postError{status: http.StatusInternalServerError, message: "failed to get player data"}
+		}
+		if apiErr.StatusCode != 401 {
+			return nil, &postError{status: http.StatusBadRequest, message: fmt.Sprintf("spotify api error: %d", apiErr.StatusCode)}
+		}
 
-				newTokens, refreshErr := h.spotifyClient.RefreshToken(user.SpotifyRefreshToken.String)
-				if refreshErr != nil {
-					return c.JSON(http.StatusUnauthorized, PostResponse{Success: false, Message: "failed to refresh spotify token"})
-				}
+		// Token revoked before its stored expiry, try to refresh
+		accessToken, err = h.spotifyTokens.Refresh(ctx, user)
+		if err != nil {
+			return nil, spotifyTokenError(err)
+		}
 
-				// Update tokens in database
-				expiresAt := time.Now().Add(time.Duration(newTokens.ExpiresIn) * time.Second)
-				if updateErr := h.store.UpdateSpotifyToken(ctx, user.ID, newTokens.AccessToken, newTokens.RefreshToken, expiresAt); updateErr != nil {
-					return c.JSON(http.StatusInternalServerError, PostResponse{Success: false, Message: "failed to update spotify token"})
-				}
+		// Retry with new access token
+		playerResp, _, err = h.spotifyClient.GetPlayerData(ctx, accessToken)
+		if err != nil {
+			return nil, &postError{status: http.StatusInternalServerError, message: "failed to get player data after token refresh"}
+		}
+	}
 
-				// Retry with new access token
-				accessToken = newTokens.AccessToken
-				playerResp, _, err = h.spotifyClient.GetPlayerData(accessToken)
-				if err != nil {
-					return c.JSON(http.StatusInternalServerError, PostResponse{Success: false, Message: "failed to get player data after token refresh"})
-				}
-			} else {
-				return c.JSON(http.StatusBadRequest, PostResponse{Success: false, Message: fmt.Sprintf("spotify api error: %d", apiErr.StatusCode)})
-			}
-		} else {
-			return c.JSON(http.StatusInternalServerError, PostResponse{Success: false, Message: "failed to get player data"})
+	return playerResp, nil
+}
+
+// postPlayback is the playback selected for a post by fetchPostPlayback
+type postPlayback struct {
+	resp *spotify.PlayerResponse
+	// justPlayed reports that resp is the last played track rather than the current one
+	justPlayed bool
+	// hint explains why the recently played track could not be checked, if the user can fix it
+	hint string
+}
+
+// recentlyPlayedScopeHint is shown when Spotify rejects the recently played request, typically
+// because the user logged in before the user-read-recently-played scope was requested
+const recentlyPlayedScopeHint = "reconnect Spotify to enable recently played"
+
+// fetchPostPlayback fetches the playback to post. When nothing is playing and the user enabled
+// the recently played fallback, it returns the last played track instead if it was played within
+// the window, reporting justPlayed.
+func (h *APIPostHandler) fetchPostPlayback(ctx context.Context, user *store.User) (postPlayback, error) {
+	playerResp, err := h.FetchPlayback(ctx, user)
+	if err != nil || playerResp.State() != spotify.StateIdle {
+		return postPlayback{resp: playerResp}, err
+	}
+
+	settings, err := h.store.GetPostSettings(ctx, user.ID)
+	if err != nil || settings.RecentlyPlayedWindowMinutes == 0 {
+		return postPlayback{resp: playerResp}, nil
+	}
+
+	// Report "nothing is playing" if the history cannot be fetched
+	accessToken, err := h.spotifyTokens.AccessToken(ctx, user)
+	if err != nil {
+		h.logger.Warn("failed to get spotify token for recently played", "user_id", user.ID, "error", err)
+		return postPlayback{resp: playerResp}, nil
+	}
+	history, err := h.spotifyClient.GetRecentlyPlayed(ctx, accessToken, 1)
+	if err != nil {
+		h.logger.Warn("failed to get recently played", "user_id", user.ID, "error", err)
+		if apiErr, ok := spotify.IsAPIError(err); ok && apiErr.StatusCode == http.StatusForbidden {
+			return postPlayback{resp: playerResp, hint: recentlyPlayedScopeHint}, nil
 		}
+		return postPlayback{resp: playerResp}, nil
+	}
+	if len(history) == 0 || time.Since(history[0].PlayedAt) > settings.RecentlyPlayedWindow() {
+		return postPlayback{resp: playerResp}, nil
+	}
+	return postPlayback{resp: history[0].PlayerResponse(), justPlayed: true}, nil
+}
+
+// withHint adds the playback hint to a response that failed because nothing is playing
+func (p postPlayback) withHint(resp PostResponse) PostResponse {
+	if p.hint != "" && !resp.Success && resp.Message == "nothing is playing" {
+		resp.Message = fmt.Sprintf("%s (%s)", resp.Message, p.hint)
+	}
+	return resp
+}
+
+// spotifyTokenError converts a TokenSource error into a postError
+func spotifyTokenError(err error) *postError {
+	switch {
+	case errors.Is(err, spotify.ErrNotConnected):
+		return &postError{status: http.StatusBadRequest, message: "spotify not connected"}
+	case errors.Is(err, spotify.ErrNoRefreshToken):
+		return &postError{status: http.StatusUnauthorized, message: err.Error()}
+	default:
+		return &postError{status: http.StatusUnauthorized, message: "failed to refresh spotify token"}
+	}
+}
+
+// PublishPlayback posts the playback to the target platforms and returns the per-platform results
+func (h *APIPostHandler) PublishPlayback(ctx context.Context, user *store.User, playerResp *spotify.PlayerResponse, opts PublishOptions) PostResponse {
+	target := opts.Target
+
+	settings, err := h.store.GetPostSettings(ctx, user.ID)
+	if err != nil {
+		settings = store.DefaultPostSettings(user.ID)
 	}
 
 	// Parse player response to get track data
 	trackData, contentType := spotify.ParsePlayerResponse(playerResp)
-	if contentType == "unknown" {
-		return c.JSON(http.StatusOK, PostResponse{Success: false, Message: "nothing is playing"})
+	if reason := unpostablePlayback(playerResp, contentType, settings, opts.JustPlayed); reason != "" {
+		return PostResponse{Success: false, Message: reason}
 	}
 
-	// Build the post text (same format as existing implementation)
-	var postText string
-	switch contentType {
-	case "track":
-		postText = fmt.Sprintf("%s / %s\n#NowPlaying #PsrPlaying\n%s", trackData.TrackName, trackData.ArtistName, trackData.TrackURL)
-	case "episode":
-		postText = fmt.Sprintf("%s / %s\n#NowPlaying\n%s", trackData.TrackName, trackData.ArtistName, trackData.TrackURL)
+	// Build the post text from the user's templates
+	templates, err := h.store.GetPostTemplates(ctx, user.ID)
+	if err != nil {
+		// Fall back to the built-in template
+		templates = nil
 	}
+	templateData := trackData.TemplateData(contentType)
+	templateData.JustPlayed = opts.JustPlayed
+	postText := composePostText(templates, templatePlatformDefault, templateData, opts)
 
-	results := make(map[string]string)
+	attachMedia := settings.AttachMedia
+	if opts.Media != nil {
+		attachMedia = *opts.Media
+	}
 
-	// Post to Misskey
-	if target == PostTargetMisskey || target == PostTargetBoth {
-		if user.MisskeyAccessToken.Valid && user.MisskeyAccessToken.String != "" {
-			err := h.postToMisskey(user.MisskeyInstanceURL.String, user.MisskeyAccessToken.String, postText)
-			if err != nil {
-				results["misskey"] = fmt.Sprintf("error: %s", err.Error())
-			} else {
-				results["misskey"] = "success"
-			}
-		} else {
-			results["misskey"] = "not connected"
-		}
+	job := publishJob{
+		opts:         opts,
+		templates:    templates,
+		templateData: templateData,
+		settings:     settings,
+		itemURI:      playerResp.Item.URI,
+	}
+	if attachMedia {
+		job.media = trackData.ImageURL
 	}
+	if attachMedia && trackData.ImageURL != "" && !opts.DryRun {
+		// Download the artwork once for all platforms, and only when one of them actually posts
+		imageURL := trackData.ImageURL
+		job.artwork = sync.OnceValue(func() *Artwork {
+			// Post without media if the artwork cannot be downloaded
+			art, _ := h.fetchArtwork(ctx, imageURL)
+			return art
+		})
+	}
+
+	// Post to all platforms concurrently so that a slow instance does not delay the others.
+	// Each platform writes only its own slot; results and history are collected in canonical order.
+	platforms := target.Platforms()
+	outcomes := make([]publishOutcome, len(platforms))
+	var wg sync.WaitGroup
+	for i, platform := range platforms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			platformCtx, cancel := context.WithTimeout(ctx, h.postTimeout)
+			defer cancel()
+			outcomes[i] = h.publishTo(platformCtx, user, platform, job)
+		}()
+	}
+	wg.Wait()
 
-	// Post to Twitter
-	if target == PostTargetTwitter || target == PostTargetBoth {
-		if user.TwitterAccessToken.Valid && user.TwitterAccessToken.String != "" {
-			err := h.postToTwitter(user.TwitterAccessToken.String, postText)
-			if err != nil {
-				results["twitter"] = fmt.Sprintf("error: %s", err.Error())
+	results := make(map[string]string)
+	var previews map[string]PostPreview
+	for i, platform := range platforms {
+		results[platform] = outcomes[i].result
+		if attempt := outcomes[i].attempt; attempt != nil {
+			// Record the attempt even if the request was canceled while posting
+			if attempt.retry != nil {
+				if err := h.enqueueRetry(context.WithoutCancel(ctx), user.ID, platform, job.itemURI, attempt); err != nil {
+					results[platform] = fmt.Sprintf("retry not queued: %s", attempt.err.Error())
+				}
 			} else {
-				results["twitter"] = "success"
+				h.recordPost(context.WithoutCancel(ctx), user.ID, platform, job.itemURI, attempt.text, attempt.remoteID, attempt.err)
+			}
+		}
+		if outcomes[i].preview != nil {
+			if previews == nil {
+				previews = make(map[string]PostPreview)
 			}
-		} else {
-			results["twitter"] = "not connected"
+			previews[platform] = *outcomes[i].preview
 		}
 	}
 
 	// Check if any succeeded
 	anySuccess := false
 	for _, v := range results {
-		if v == "success" {
+		if v == "success" || v == "dry_run" {
 			anySuccess = true
 			break
 		}
 	}
 
-	return c.JSON(http.StatusOK, PostResponse{
-		Success: anySuccess,
-		Message: postText,
-		Results: results,
-	})
+	return PostResponse{
+		Success:    anySuccess,
+		Message:    postText,
+		Results:    results,
+		Previews:   previews,
+		JustPlayed: opts.JustPlayed,
+	}
 }
 
-// postToMisskey posts a note to Misskey
-func (h *APIPostHandler) postToMisskey(instanceURL, accessToken, text string) error {
-	// Ensure instance URL has protocol
-	if !strings.HasPrefix(instanceURL, "http://") && !strings.HasPrefix(instanceURL, "https://") {
-		instanceURL = "https://" + instanceURL
+// unpostablePlayback returns why the playback cannot be posted under the user's settings,
+// or "" if it can be posted. A just played track is not playing but is not treated as paused.
+func unpostablePlayback(playerResp *spotify.PlayerResponse, contentType string, settings *store.PostSettings, justPlayed bool) string {
+	switch playerResp.ContentState() {
+	case spotify.StateIdle:
+		return "nothing is playing"
+	case spotify.StateAd:
+		return "an ad is playing"
+	case spotify.StateLocalFile:
+		if !settings.PostLocalFiles {
+			return "posting local files is disabled"
+		}
 	}
-	var err error
-	instanceURL, err = validatePublicHTTPSURL(strings.TrimSuffix(instanceURL, "/"))
-	if err != nil {
-		return fmt.Errorf("invalid misskey instance URL: %w", err)
+	if contentType == "unknown" {
+		return "nothing is playing"
 	}
-
-	reqBody := MisskeyNoteRequest{
-		I:          accessToken,
-		Text:       text,
-		Visibility: "public",
+	if !playerResp.IsPlaying && !justPlayed && !settings.PostPaused {
+		return "playback is paused"
 	}
+	return ""
+}
 
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
+// publishJob is the platform-independent input of a publish, shared by all platforms
+type publishJob struct {
+	opts         PublishOptions
+	templates    map[string]string
+	templateData posttemplate.Data
+	settings     *store.PostSettings
+	itemURI      string
+	// media is the URL of the artwork to attach ("" if disabled)
+	media string
+	// artwork returns the downloaded artwork, fetching it on first use (nil if not attached)
+	artwork func() *Artwork
+}
 
-	url := fmt.Sprintf("%s/api/notes/create", instanceURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
+// publishOutcome is the result of publishing to a single platform
+type publishOutcome struct {
+	result  string
+	preview *PostPreview
+	// attempt is set when the post was sent, to be recorded in the post history
+	attempt *postAttempt
+}
 
-	req.Header.Set("Content-Type", "application/json")
+// postAttempt is a post sent to a platform
+type postAttempt struct {
+	text     string
+	remoteID string
+	err      error
+	// retry is set when the post failed transiently, to be queued in the outbox
+	retry      *queuedPost
+	retryAfter time.Duration
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+// publishTo posts the job to a single platform, or renders its preview in a dry run
+func (h *APIPostHandler) publishTo(ctx context.Context, user *store.User, platform string, job publishJob) publishOutcome {
+	poster, ok := h.posters.Get(platform)
+	if !ok {
+		return publishOutcome{result: "unsupported"}
+	}
+	if !poster.Connected(user) {
+		return publishOutcome{result: "not connected"}
+	}
+	// A dry run still renders the preview so the caller can see what a forced post would send
+	duplicate := !job.opts.Force && h.isDuplicate(ctx, user.ID, platform, job.itemURI, job.settings.DedupeWindow())
+	if duplicate && !job.opts.DryRun {
+		return publishOutcome{result: "skipped_duplicate"}
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("misskey api error: %d - %s", resp.StatusCode, string(body))
+	replyTo := h.sessionReplyTo(ctx, user.ID, poster, job.settings.SessionGap())
+	limits := postLimits(ctx, poster, user)
+	text, truncated := fitPostText(func(data posttemplate.Data) string {
+		return composePostText(job.templates, platform, data, job.opts)
+	}, job.templateData, limits)
+	if job.opts.DryRun {
+		return publishOutcome{result: "dry_run", preview: &PostPreview{
+			Text:      text,
+			Length:    limits.Length(text),
+			MaxLength: limits.MaxLength,
+			Truncated: truncated,
+			Media:     job.media,
+			ReplyTo:   replyTo,
+			Duplicate: duplicate,
+		}}
 	}
 
-	return nil
+	req := PostRequest{
+		Text:       text,
+		Visibility: job.opts.Visibility,
+		Misskey:    job.opts.Misskey,
+		ReplyTo:    replyTo,
+		Data:       job.templateData,
+	}
+	if job.artwork != nil {
+		req.Artwork = job.artwork()
+	}
+	remoteID, err := poster.Post(ctx, user, req)
+	outcome := publishOutcome{attempt: &postAttempt{text: text, remoteID: remoteID, err: err}}
+	retryable, retryAfter := ClassifyPostError(err)
+	switch {
+	case errors.Is(err, ErrReconnectRequired):
+		outcome.result = "reconnect required"
+	case errors.Is(err, context.DeadlineExceeded):
+		outcome.result = "timeout"
+	case retryable:
+		outcome.result = fmt.Sprintf("retrying: %s", err.Error())
+		outcome.attempt.retry = &queuedPost{
+			Text:       req.Text,
+			Visibility: req.Visibility,
+			Misskey:    req.Misskey,
+			ReplyTo:    req.ReplyTo,
+			Data:       req.Data,
+		}
+		if req.Artwork != nil {
+			outcome.attempt.retry.Media = job.media
+		}
+		outcome.attempt.retryAfter = retryAfter
+	case err != nil:
+		outcome.result = fmt.Sprintf("error: %s", err.Error())
+	default:
+		outcome.result = "success"
+	}
+	return outcome
 }
 
-// postToTwitter posts a tweet to Twitter
-func (h *APIPostHandler) postToTwitter(accessToken, text string) error {
-	reqBody := TwitterTweetRequest{
-		Text: text,
+// composePostText renders the text for a platform, applying the per-request template
+// override and comment
+func composePostText(templates map[string]string, platform string, data posttemplate.Data, opts PublishOptions) string {
+	if opts.Template != "" {
+		templates = map[string]string{templatePlatformDefault: opts.Template}
 	}
-
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+	text := renderPostText(templates, platform, data)
+	if opts.Comment != "" {
+		text = opts.Comment + "\n" + text
 	}
+	return text
+}
 
-	req, err := http.NewRequest("POST", "https://api.twitter.com/2/tweets", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+// isDuplicate reports whether the item was already posted to the platform within the window.
+// Lookup errors are treated as "not a duplicate" so that posting is never blocked by history.
+func (h *APIPostHandler) isDuplicate(ctx context.Context, userID uuid.UUID, platform, itemURI string, window time.Duration) bool {
+	if window <= 0 || itemURI == "" {
+		return false
 	}
+	duplicate, err := h.store.HasRecentPost(ctx, userID, platform, itemURI, time.Now().Add(-window))
+	return err == nil && duplicate
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+// sessionReplyTo returns the remote ID of the user's previous post to the platform when it was
+// made within the session gap, so that the new post continues the listening session as a reply.
+// It returns "" when threading is disabled, the platform cannot reply, or a new session starts.
+func (h *APIPostHandler) sessionReplyTo(ctx context.Context, userID uuid.UUID, poster Poster, gap time.Duration) string {
+	if _, ok := poster.(replyPoster); !ok || gap <= 0 {
+		return ""
 	}
-	defer func() { _ = resp.Body.Close() }()
+	// Lookup errors start a new session rather than failing the post
+	latest, err := h.store.GetLatestPost(ctx, userID, poster.Platform(), time.Now().Add(-gap))
+	if err != nil || latest == nil {
+		return ""
+	}
+	return latest.RemoteID.String
+}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("twitter api error: %d - %s", resp.StatusCode, string(body))
+// recordPost stores a posting attempt in the post history (best effort)
+func (h *APIPostHandler) recordPost(ctx context.Context, userID uuid.UUID, platform, itemURI, text, remoteID string, postErr error) {
+	post := &store.Post{
+		UserID:   userID,
+		Platform: platform,
+		ItemURI:  sql.NullString{String: itemURI, Valid: itemURI != ""},
+		Text:     text,
+		RemoteID: sql.NullString{String: remoteID, Valid: remoteID != ""},
+		Status:   store.PostStatusSuccess,
+	}
+	if postErr != nil {
+		post.Status = store.PostStatusFailed
+		post.Error = sql.NullString{String: postErr.Error(), Valid: true}
 	}
 
-	return nil
+	// Ignore error - history must not affect the post result
+	_ = h.store.CreatePost(ctx, post)
 }
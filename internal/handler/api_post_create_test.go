@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/spotify"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createPost はPOST /api/postをJSONボディで呼び出す
+func createPost(t *testing.T, h *APIPostHandler, headerToken, body string) *httptest.ResponseRecorder {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/post", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if headerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+headerToken)
+	}
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, h.CreatePost(e.NewContext(req, rec)))
+	return rec
+}
+
+func TestCreatePost_AppliesOverrides(t *testing.T) {
+	f := newIdempotencyFixture()
+	mastodon := &fakePoster{platform: "mastodon", connected: true}
+	f.h.posters = NewPosterRegistry(f.poster, mastodon)
+
+	rec := createPost(t, f.h, idempotencyTestHeaderToken, `{
+		"targets": ["mastodon"],
+		"visibility": "unlisted",
+		"template": "{{.Track}} / {{.Artists}}",
+		"comment": "作業用"
+	}`)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, f.poster.posted)
+	require.Len(t, mastodon.posted, 1)
+	assert.Equal(t, "作業用\nあとがき / 来栖夏芽", mastodon.posted[0].Text)
+	assert.Equal(t, VisibilityUnlisted, mastodon.posted[0].Visibility)
+}
+
+func TestCreatePost_DryRun(t *testing.T) {
+	f := newIdempotencyFixture()
+	s := f.h.store.(*fakePostStore)
+
+	rec := createPost(t, f.h, idempotencyTestHeaderToken, `{"targets": ["misskey"], "template": "{{.Track}}", "dry_run": true}`)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp PostResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, "dry_run", resp.Results["misskey"])
+	assert.Equal(t, "あとがき", resp.Previews["misskey"].Text)
+	assert.Empty(t, f.poster.posted)
+	assert.Empty(t, s.posts)
+}
+
+func TestCreatePost_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		headerToken string
+		body        string
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "ヘッダートークンなし",
+			body:        `{}`,
+			wantStatus:  http.StatusUnauthorized,
+			wantMessage: "authorization header required",
+		},
+		{
+			name:        "ヘッダートークンが違う",
+			headerToken: "wrong-token",
+			body:        `{}`,
+			wantStatus:  http.StatusUnauthorized,
+			wantMessage: "invalid token",
+		},
+		{
+			name:        "不正なJSON",
+			headerToken: idempotencyTestHeaderToken,
+			body:        `{`,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "invalid request body",
+		},
+		{
+			name:        "不明な投稿先",
+			headerToken: idempotencyTestHeaderToken,
+			body:        `{"targets": ["threads"]}`,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "unknown target: threads",
+		},
+		{
+			name:        "不明な公開範囲",
+			headerToken: idempotencyTestHeaderToken,
+			body:        `{"visibility": "secret"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "invalid visibility: secret",
+		},
+		{
+			name:        "不正なテンプレート",
+			headerToken: idempotencyTestHeaderToken,
+			body:        `{"template": "{{.Unknown}}"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "invalid template",
+		},
+		{
+			name:        "不正なMisskeyオプション",
+			headerToken: idempotencyTestHeaderToken,
+			body:        `{"misskey": {"visibility": "unlisted"}}`,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "invalid misskey options",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newIdempotencyFixture()
+
+			rec := createPost(t, f.h, tt.headerToken, tt.body)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantMessage)
+			assert.Empty(t, f.poster.posted)
+		})
+	}
+}
+
+func TestMisskeyAndMastodonVisibility(t *testing.T) {
+	assert.Equal(t, "public", misskeyVisibility(VisibilityDefault))
+	assert.Equal(t, "home", misskeyVisibility(VisibilityUnlisted))
+	assert.Equal(t, "specified", misskeyVisibility(VisibilityDirect))
+	assert.Equal(t, "public", mastodonVisibility(VisibilityDefault))
+	assert.Equal(t, "private", mastodonVisibility(VisibilityFollowers))
+}
+
+func TestPreviewPost(t *testing.T) {
+	f := newIdempotencyFixture()
+	twitter := &fakePoster{platform: "twitter", connected: true}
+	f.h.posters = NewPosterRegistry(f.poster, twitter)
+	f.h.spotifyClient = &MockSpotifyClient{
+		GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+			track := playingTrack()
+			track.Item.Album.Images = []spotify.Image{{URL: "https://i.scdn.co/image/artwork", Width: 640, Height: 640}}
+			return track, 0, nil
+		},
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/post/preview", strings.NewReader(`{"targets": ["both"], "media": true}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", f.user.ID)
+
+	require.NoError(t, f.h.PreviewPost(c))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp PostResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, map[string]string{"misskey": "dry_run", "twitter": "dry_run"}, resp.Results)
+	preview := resp.Previews["twitter"]
+	assert.Contains(t, preview.Text, "あとがき / 来栖夏芽")
+	assert.Equal(t, 500, preview.MaxLength)
+	assert.False(t, preview.Truncated)
+	assert.Equal(t, "https://i.scdn.co/image/artwork", preview.Media)
+	assert.Empty(t, f.poster.posted)
+	assert.Empty(t, twitter.posted)
+}
@@ -0,0 +1,726 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/auth"
+	"github.com/Soli0222/spotify-nowplaying/internal/spotify"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePostStore はテスト用のインメモリストア
+type fakePostStore struct {
+	mu        sync.Mutex
+	user      *store.User
+	templates map[string]string
+	settings  *store.PostSettings
+	posts     []store.Post
+	keys      map[string]*store.IdempotencyRecord
+	// queued は再試行キューに入れられた投稿内容（投稿IDごと）
+	queued map[uuid.UUID][]byte
+	// enqueueErr はEnqueuePostが返すエラー
+	enqueueErr error
+}
+
+func (s *fakePostStore) GetUserByID(ctx context.Context, id uuid.UUID) (*store.User, error) {
+	if s.user != nil && s.user.ID == id {
+		return s.user, nil
+	}
+	return nil, nil
+}
+
+func (s *fakePostStore) GetUserByAPIToken(ctx context.Context, apiToken uuid.UUID) (*store.User, error) {
+	if s.user != nil && s.user.APIURLToken == apiToken {
+		return s.user, nil
+	}
+	return nil, nil
+}
+
+func (s *fakePostStore) GetUserByAPIHeaderTokenHash(ctx context.Context, tokenHash string) (*store.User, error) {
+	if s.user != nil && s.user.APIHeaderTokenEnabled && s.user.APIHeaderTokenHash.String == tokenHash {
+		return s.user, nil
+	}
+	return nil, nil
+}
+
+func (s *fakePostStore) UpdateSpotifyToken(ctx context.Context, userID uuid.UUID, accessToken, refreshToken string, expiresAt time.Time) error {
+	return nil
+}
+
+func (s *fakePostStore) GetPostTemplates(ctx context.Context, userID uuid.UUID) (map[string]string, error) {
+	return s.templates, nil
+}
+
+func (s *fakePostStore) GetPostSettings(ctx context.Context, userID uuid.UUID) (*store.PostSettings, error) {
+	if s.settings != nil {
+		return s.settings, nil
+	}
+	return store.DefaultPostSettings(userID), nil
+}
+
+func (s *fakePostStore) CreatePost(ctx context.Context, post *store.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post.ID = uuid.New()
+	post.CreatedAt = time.Now()
+	s.posts = append(s.posts, *post)
+	return nil
+}
+
+func (s *fakePostStore) EnqueuePost(ctx context.Context, post *store.Post, payload []byte, nextAttemptAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.enqueueErr != nil {
+		return s.enqueueErr
+	}
+	post.ID = uuid.New()
+	post.CreatedAt = time.Now()
+	post.Status = store.PostStatusRetrying
+	post.Attempts = 1
+	post.NextRetryAt = sql.NullTime{Time: nextAttemptAt, Valid: true}
+	s.posts = append(s.posts, *post)
+	if s.queued == nil {
+		s.queued = make(map[uuid.UUID][]byte)
+	}
+	s.queued[post.ID] = payload
+	return nil
+}
+
+func (s *fakePostStore) HasRecentPost(ctx context.Context, userID uuid.UUID, platform, itemURI string, since time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, post := range s.posts {
+		if post.UserID == userID && post.Platform == platform && post.ItemURI.String == itemURI &&
+			(post.Status == store.PostStatusSuccess || post.Status == store.PostStatusRetrying) && !post.CreatedAt.Before(since) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *fakePostStore) GetLatestPost(ctx context.Context, userID uuid.UUID, platform string, since time.Time) (*store.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.posts) - 1; i >= 0; i-- {
+		post := s.posts[i]
+		if post.UserID == userID && post.Platform == platform && post.RemoteID.Valid &&
+			post.Status == store.PostStatusSuccess && !post.CreatedAt.Before(since) {
+			return &post, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakePostStore) ReserveIdempotencyKey(ctx context.Context, userID uuid.UUID, key, requestHash string, ttl, lease time.Duration) (*store.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lockedUntil := sql.NullTime{Time: time.Now().Add(lease), Valid: true}
+	if record, ok := s.keys[userID.String()+key]; ok {
+		// 期限切れの処理中の予約は同じリクエストが引き継ぐ
+		if !record.Completed() && record.RequestHash == requestHash && record.LockedUntil.Time.Before(time.Now()) {
+			record.LockedUntil = lockedUntil
+			return nil, nil
+		}
+		copied := *record
+		return &copied, nil
+	}
+	if s.keys == nil {
+		s.keys = make(map[string]*store.IdempotencyRecord)
+	}
+	s.keys[userID.String()+key] = &store.IdempotencyRecord{UserID: userID, Key: key, RequestHash: requestHash, LockedUntil: lockedUntil}
+	return nil, nil
+}
+
+func (s *fakePostStore) CompleteIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, statusCode int, responseBody []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.keys[userID.String()+key]
+	record.StatusCode = sql.NullInt32{Int32: int32(statusCode), Valid: true}
+	record.ResponseBody = responseBody
+	return nil
+}
+
+func (s *fakePostStore) ReleaseIdempotencyKey(ctx context.Context, userID uuid.UUID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, userID.String()+key)
+	return nil
+}
+
+// fakePoster はテスト用のPoster
+type fakePoster struct {
+	mu        sync.Mutex
+	platform  string
+	connected bool
+	err       error
+	// delay は投稿にかかる時間（コンテキストがキャンセルされると中断する）
+	delay   time.Duration
+	posted  []PostRequest
+	deleted []string
+}
+
+func (p *fakePoster) Platform() string                { return p.platform }
+func (p *fakePoster) Connected(user *store.User) bool { return p.connected }
+func (p *fakePoster) Limits() PostLimits              { return PostLimits{MaxLength: 500} }
+func (p *fakePoster) Delete(ctx context.Context, user *store.User, remoteID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deleted = append(p.deleted, remoteID)
+	return p.err
+}
+
+func (p *fakePoster) Post(ctx context.Context, user *store.User, req PostRequest) (string, error) {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.posted = append(p.posted, req)
+	if p.err != nil {
+		return "", p.err
+	}
+	return p.platform + "-id", nil
+}
+
+func playingTrack() *spotify.PlayerResponse {
+	return &spotify.PlayerResponse{
+		IsPlaying:            true,
+		CurrentlyPlayingType: "track",
+		Item: spotify.Item{
+			Name:         "あとがき",
+			Artists:      []spotify.Artist{{Name: "来栖夏芽"}},
+			URI:          "spotify:track:1",
+			ExternalUrls: spotify.ExternalUrls{Spotify: "https://open.spotify.com/track/1"},
+		},
+	}
+}
+
+func TestPublishPlayback_PostsToRequestedTargets(t *testing.T) {
+	s := &fakePostStore{templates: map[string]string{"mastodon": "{{.Track}} on mastodon"}}
+	misskey := &fakePoster{platform: "misskey", connected: true}
+	twitter := &fakePoster{platform: "twitter", connected: true}
+	mastodon := &fakePoster{platform: "mastodon", connected: true}
+	h := NewAPIPostHandler(s, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey, twitter, mastodon))
+	user := &store.User{ID: uuid.New()}
+
+	resp := h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: "misskey,mastodon"})
+
+	assert.True(t, resp.Success)
+	assert.Equal(t, map[string]string{"misskey": "success", "mastodon": "success"}, resp.Results)
+	require.Len(t, misskey.posted, 1)
+	assert.Equal(t, "あとがき / 来栖夏芽\n#NowPlaying #PsrPlaying\nhttps://open.spotify.com/track/1", misskey.posted[0].Text)
+	require.Len(t, mastodon.posted, 1)
+	assert.Equal(t, "あとがき on mastodon", mastodon.posted[0].Text)
+	assert.Empty(t, twitter.posted)
+
+	require.Len(t, s.posts, 2)
+	assert.Equal(t, "misskey", s.posts[0].Platform)
+	assert.Equal(t, "misskey-id", s.posts[0].RemoteID.String)
+	assert.Equal(t, store.PostStatusSuccess, s.posts[0].Status)
+}
+
+func TestPublishPlayback_ReportsErrorsPerPlatform(t *testing.T) {
+	s := &fakePostStore{}
+	misskey := &fakePoster{platform: "misskey", connected: true, err: errors.New("boom")}
+	twitter := &fakePoster{platform: "twitter", connected: false}
+	h := NewAPIPostHandler(s, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey, twitter))
+	user := &store.User{ID: uuid.New()}
+
+	resp := h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: "misskey,twitter,bluesky"})
+
+	assert.False(t, resp.Success)
+	assert.Equal(t, map[string]string{
+		"misskey": "error: boom",
+		"twitter": "not connected",
+		"bluesky": "unsupported",
+	}, resp.Results)
+
+	require.Len(t, s.posts, 1)
+	assert.Equal(t, store.PostStatusFailed, s.posts[0].Status)
+	assert.Equal(t, sql.NullString{String: "boom", Valid: true}, s.posts[0].Error)
+}
+
+func TestPublishPlayback_NothingPlaying(t *testing.T) {
+	misskey := &fakePoster{platform: "misskey", connected: true}
+	h := NewAPIPostHandler(&fakePostStore{}, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey))
+
+	resp := h.PublishPlayback(context.Background(), &store.User{ID: uuid.New()}, &spotify.PlayerResponse{}, PublishOptions{Target: PostTargetMisskey})
+
+	assert.False(t, resp.Success)
+	assert.Equal(t, "nothing is playing", resp.Message)
+	assert.Empty(t, misskey.posted)
+}
+
+func TestPublishPlayback_PlaybackPolicy(t *testing.T) {
+	paused := playingTrack()
+	paused.IsPlaying = false
+	localFile := playingTrack()
+	localFile.Item.IsLocal = true
+	localFile.Item.URI = "spotify:local:Local+Band:Demo+Tapes:Rehearsal:201"
+	localFile.Item.ExternalUrls = spotify.ExternalUrls{}
+
+	tests := []struct {
+		name        string
+		playerResp  *spotify.PlayerResponse
+		settings    func(settings *store.PostSettings)
+		wantMessage string
+	}{
+		{
+			name:        "広告は投稿しない",
+			playerResp:  &spotify.PlayerResponse{CurrentlyPlayingType: "ad", IsPlaying: true},
+			wantMessage: "an ad is playing",
+		},
+		{
+			name:       "一時停止中はデフォルトで投稿する",
+			playerResp: paused,
+		},
+		{
+			name:        "一時停止中の投稿を無効化",
+			playerResp:  paused,
+			settings:    func(settings *store.PostSettings) { settings.PostPaused = false },
+			wantMessage: "playback is paused",
+		},
+		{
+			name:        "ローカルファイルはデフォルトで投稿しない",
+			playerResp:  localFile,
+			wantMessage: "posting local files is disabled",
+		},
+		{
+			name:       "ローカルファイルの投稿を有効化",
+			playerResp: localFile,
+			settings:   func(settings *store.PostSettings) { settings.PostLocalFiles = true },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &store.User{ID: uuid.New()}
+			settings := store.DefaultPostSettings(user.ID)
+			if tt.settings != nil {
+				tt.settings(settings)
+			}
+			misskey := &fakePoster{platform: "misskey", connected: true}
+			h := NewAPIPostHandler(&fakePostStore{settings: settings}, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey))
+
+			resp := h.PublishPlayback(context.Background(), user, tt.playerResp, PublishOptions{Target: PostTargetMisskey})
+
+			if tt.wantMessage != "" {
+				assert.False(t, resp.Success)
+				assert.Equal(t, tt.wantMessage, resp.Message)
+				assert.Empty(t, misskey.posted)
+				return
+			}
+			assert.True(t, resp.Success)
+			assert.Len(t, misskey.posted, 1)
+		})
+	}
+}
+
+func TestPostNowPlaying_UsesFakePosters(t *testing.T) {
+	headerToken := "header-token"
+	user := &store.User{
+		ID:                    uuid.New(),
+		APIURLToken:           uuid.New(),
+		SpotifyAccessToken:    sql.NullString{String: "spotify-token", Valid: true},
+		APIHeaderTokenEnabled: true,
+		APIHeaderTokenHash:    sql.NullString{String: auth.HashToken(headerToken), Valid: true},
+	}
+	s := &fakePostStore{user: user}
+	client := &MockSpotifyClient{
+		GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+			return playingTrack(), 0, nil
+		},
+	}
+	twitter := &fakePoster{platform: "twitter", connected: true}
+	h := NewAPIPostHandler(s, client, spotify.NewTokenSource(client, s), NewPosterRegistry(&fakePoster{platform: "misskey"}, twitter))
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/post/"+user.APIURLToken.String()+"?target=twitter", nil)
+	req.Header.Set("Authorization", "Bearer "+headerToken)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("token")
+	c.SetParamValues(user.APIURLToken.String())
+
+	err := h.PostNowPlaying(c)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"twitter":"success"`)
+	assert.Len(t, twitter.posted, 1)
+}
+
+func TestPostNowPlaying_RejectsUnknownTargetInList(t *testing.T) {
+	headerToken := "header-token"
+	user := &store.User{
+		ID:                    uuid.New(),
+		APIURLToken:           uuid.New(),
+		SpotifyAccessToken:    sql.NullString{String: "spotify-token", Valid: true},
+		APIHeaderTokenEnabled: true,
+		APIHeaderTokenHash:    sql.NullString{String: auth.HashToken(headerToken), Valid: true},
+	}
+	misskey := &fakePoster{platform: "misskey"}
+	h := NewAPIPostHandler(&fakePostStore{user: user}, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey))
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/post/"+user.APIURLToken.String()+"?target=misskey,unknown", nil)
+	req.Header.Set("Authorization", "Bearer "+headerToken)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("token")
+	c.SetParamValues(user.APIURLToken.String())
+
+	err := h.PostNowPlaying(c)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "unknown target: unknown")
+	assert.Empty(t, misskey.posted)
+}
+
+func TestPublishPlayback_ReconnectRequired(t *testing.T) {
+	twitter := &fakePoster{platform: "twitter", connected: true, err: ErrReconnectRequired}
+	h := NewAPIPostHandler(&fakePostStore{}, &MockSpotifyClient{}, nil, NewPosterRegistry(twitter))
+
+	resp := h.PublishPlayback(context.Background(), &store.User{ID: uuid.New()}, playingTrack(), PublishOptions{Target: PostTargetTwitter})
+
+	assert.False(t, resp.Success)
+	assert.Equal(t, map[string]string{"twitter": "reconnect required"}, resp.Results)
+}
+
+func TestFetchPlayback_RefreshesExpiringToken(t *testing.T) {
+	user := &store.User{
+		ID:                    uuid.New(),
+		SpotifyAccessToken:    sql.NullString{String: "old-token", Valid: true},
+		SpotifyRefreshToken:   sql.NullString{String: "refresh-token", Valid: true},
+		SpotifyTokenExpiresAt: sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true},
+	}
+	client := &MockSpotifyClient{
+		RefreshTokenFunc: func(ctx context.Context, refreshToken string) (*spotify.Tokens, error) {
+			return &spotify.Tokens{AccessToken: "new-token", RefreshToken: refreshToken, ExpiresIn: 3600}, nil
+		},
+		GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+			assert.Equal(t, "new-token", accessToken)
+			return playingTrack(), 0, nil
+		},
+	}
+	s := &fakePostStore{}
+	h := NewAPIPostHandler(s, client, spotify.NewTokenSource(client, s), NewPosterRegistry())
+
+	playerResp, err := h.FetchPlayback(context.Background(), user)
+
+	require.NoError(t, err)
+	assert.Equal(t, "あとがき", playerResp.Item.Name)
+	assert.Equal(t, "new-token", user.SpotifyAccessToken.String)
+}
+
+func TestFetchPostPlayback_RecentlyPlayedFallback(t *testing.T) {
+	history := []spotify.PlayHistory{{
+		Track:    playingTrack().Item,
+		PlayedAt: time.Now().Add(-3 * time.Minute),
+	}}
+	tests := []struct {
+		name           string
+		playerResp     *spotify.PlayerResponse
+		windowMinutes  int
+		history        []spotify.PlayHistory
+		historyErr     error
+		wantJustPlayed bool
+		wantState      spotify.PlaybackState
+		wantHint       string
+	}{
+		{
+			name:          "再生中の場合は履歴を見ない",
+			playerResp:    playingTrack(),
+			windowMinutes: 10,
+			wantState:     spotify.StateTrack,
+		},
+		{
+			name:       "フォールバックが無効",
+			playerResp: &spotify.PlayerResponse{},
+			history:    history,
+			wantState:  spotify.StateIdle,
+		},
+		{
+			name:           "時間内に再生したトラックを返す",
+			playerResp:     &spotify.PlayerResponse{},
+			windowMinutes:  10,
+			history:        history,
+			wantJustPlayed: true,
+			wantState:      spotify.StatePaused,
+		},
+		{
+			name:          "時間外の再生履歴は使わない",
+			playerResp:    &spotify.PlayerResponse{},
+			windowMinutes: 2,
+			history:       history,
+			wantState:     spotify.StateIdle,
+		},
+		{
+			name:          "スコープがなく再生履歴を取得できない",
+			playerResp:    &spotify.PlayerResponse{},
+			windowMinutes: 10,
+			historyErr:    &spotify.APIError{StatusCode: http.StatusForbidden},
+			wantState:     spotify.StateIdle,
+			wantHint:      recentlyPlayedScopeHint,
+		},
+		{
+			name:          "再生履歴の取得に失敗",
+			playerResp:    &spotify.PlayerResponse{},
+			windowMinutes: 10,
+			historyErr:    &spotify.APIError{StatusCode: http.StatusBadGateway},
+			wantState:     spotify.StateIdle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &store.User{
+				ID:                    uuid.New(),
+				SpotifyAccessToken:    sql.NullString{String: "spotify-token", Valid: true},
+				SpotifyTokenExpiresAt: sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+			}
+			settings := store.DefaultPostSettings(user.ID)
+			settings.RecentlyPlayedWindowMinutes = tt.windowMinutes
+			s := &fakePostStore{settings: settings}
+			client := &MockSpotifyClient{
+				GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+					return tt.playerResp, 0, nil
+				},
+				GetRecentlyPlayedFunc: func(ctx context.Context, accessToken string, limit int) ([]spotify.PlayHistory, error) {
+					assert.Equal(t, 1, limit)
+					return tt.history, tt.historyErr
+				},
+			}
+			h := NewAPIPostHandler(s, client, spotify.NewTokenSource(client, s), NewPosterRegistry())
+
+			playback, err := h.fetchPostPlayback(context.Background(), user)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantJustPlayed, playback.justPlayed)
+			assert.Equal(t, tt.wantState, playback.resp.State())
+			assert.Equal(t, tt.wantHint, playback.hint)
+		})
+	}
+}
+
+func TestPostPlayback_WithHint(t *testing.T) {
+	playback := postPlayback{hint: recentlyPlayedScopeHint}
+
+	resp := playback.withHint(PostResponse{Success: false, Message: "nothing is playing"})
+	assert.Equal(t, "nothing is playing (reconnect Spotify to enable recently played)", resp.Message)
+
+	// 何も再生していない以外の失敗には付けない
+	resp = playback.withHint(PostResponse{Success: false, Message: "an ad is playing"})
+	assert.Equal(t, "an ad is playing", resp.Message)
+}
+
+func TestPublishPlayback_JustPlayed(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	settings := store.DefaultPostSettings(user.ID)
+	// 一時停止中の投稿が無効でも直前に再生したトラックは投稿する
+	settings.PostPaused = false
+	misskey := &fakePoster{platform: "misskey", connected: true}
+	h := NewAPIPostHandler(&fakePostStore{settings: settings}, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey))
+	history := spotify.PlayHistory{Track: playingTrack().Item, PlayedAt: time.Now().Add(-time.Minute)}
+
+	resp := h.PublishPlayback(context.Background(), user, history.PlayerResponse(), PublishOptions{Target: PostTargetMisskey, JustPlayed: true})
+
+	assert.True(t, resp.Success)
+	assert.True(t, resp.JustPlayed)
+	require.Len(t, misskey.posted, 1)
+	assert.Equal(t, "あとがき / 来栖夏芽\n#JustPlayed #PsrPlaying\nhttps://open.spotify.com/track/1", misskey.posted[0].Text)
+}
+
+func TestPublishPlayback_SkipsDuplicates(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	s := &fakePostStore{settings: &store.PostSettings{UserID: user.ID, DedupeWindowMinutes: 30}}
+	misskey := &fakePoster{platform: "misskey", connected: true}
+	twitter := &fakePoster{platform: "twitter", connected: true}
+	h := NewAPIPostHandler(s, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey, twitter))
+
+	resp := h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey})
+	require.True(t, resp.Success)
+
+	// 同じ曲を再度投稿するとMisskeyはスキップされる
+	resp = h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetBoth})
+
+	assert.True(t, resp.Success)
+	assert.Equal(t, map[string]string{"misskey": "skipped_duplicate", "twitter": "success"}, resp.Results)
+	assert.Len(t, misskey.posted, 1)
+
+	// forceで重複チェックを無視する
+	resp = h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey, Force: true})
+
+	assert.Equal(t, map[string]string{"misskey": "success"}, resp.Results)
+	assert.Len(t, misskey.posted, 2)
+}
+
+func TestPublishPlayback_DownloadsArtworkOnlyWhenPosting(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	s := &fakePostStore{settings: &store.PostSettings{UserID: user.ID, AttachMedia: true, DedupeWindowMinutes: 30}}
+	misskey := &fakePoster{platform: "misskey", connected: true}
+	twitter := &fakePoster{platform: "twitter", connected: false}
+	h := NewAPIPostHandler(s, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey, twitter))
+	art := &Artwork{Data: []byte("image-data"), ContentType: "image/jpeg", Filename: "artwork.jpg"}
+	downloads := 0
+	h.fetchArtwork = func(ctx context.Context, imageURL string) (*Artwork, error) {
+		downloads++
+		return art, nil
+	}
+	track := playingTrack()
+	track.Item.Album.Images = []spotify.Image{{URL: "https://i.scdn.co/image/artwork", Width: 640, Height: 640}}
+
+	// 連携されていないプラットフォームしかない場合はダウンロードしない
+	resp := h.PublishPlayback(context.Background(), user, track, PublishOptions{Target: PostTargetTwitter})
+	assert.Equal(t, map[string]string{"twitter": "not connected"}, resp.Results)
+	assert.Equal(t, 0, downloads)
+
+	resp = h.PublishPlayback(context.Background(), user, track, PublishOptions{Target: PostTargetBoth})
+	assert.Equal(t, "success", resp.Results["misskey"])
+	assert.Equal(t, 1, downloads)
+	require.Len(t, misskey.posted, 1)
+	assert.Same(t, art, misskey.posted[0].Artwork)
+
+	// 重複としてスキップされる場合もダウンロードしない
+	resp = h.PublishPlayback(context.Background(), user, track, PublishOptions{Target: PostTargetMisskey})
+	assert.Equal(t, map[string]string{"misskey": "skipped_duplicate"}, resp.Results)
+	assert.Equal(t, 1, downloads)
+}
+
+func TestPublishPlayback_DryRunReportsDuplicate(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	s := &fakePostStore{settings: &store.PostSettings{UserID: user.ID, DedupeWindowMinutes: 30}}
+	misskey := &fakePoster{platform: "misskey", connected: true}
+	h := NewAPIPostHandler(s, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey))
+
+	resp := h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey, DryRun: true})
+	require.Contains(t, resp.Previews, "misskey")
+	assert.False(t, resp.Previews["misskey"].Duplicate)
+
+	h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey})
+
+	// 重複でもプレビューを返し、重複であることを示す
+	resp = h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey, DryRun: true})
+
+	assert.Equal(t, map[string]string{"misskey": "dry_run"}, resp.Results)
+	require.Contains(t, resp.Previews, "misskey")
+	assert.NotEmpty(t, resp.Previews["misskey"].Text)
+	assert.True(t, resp.Previews["misskey"].Duplicate)
+	assert.Len(t, misskey.posted, 1)
+
+	// forceを指定したプレビューは重複扱いしない
+	resp = h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey, DryRun: true, Force: true})
+
+	assert.False(t, resp.Previews["misskey"].Duplicate)
+}
+
+func TestPublishPlayback_DedupeDisabled(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	s := &fakePostStore{}
+	misskey := &fakePoster{platform: "misskey", connected: true}
+	h := NewAPIPostHandler(s, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey))
+
+	h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey})
+	resp := h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey})
+
+	assert.Equal(t, map[string]string{"misskey": "success"}, resp.Results)
+	assert.Len(t, misskey.posted, 2)
+}
+
+// fakeReplyPoster は返信に対応するテスト用のPoster
+type fakeReplyPoster struct {
+	*fakePoster
+}
+
+func (p *fakeReplyPoster) postsReplies() {}
+
+func TestPublishPlayback_ThreadsSession(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	s := &fakePostStore{settings: &store.PostSettings{UserID: user.ID, SessionGapMinutes: 30}}
+	misskey := &fakeReplyPoster{&fakePoster{platform: "misskey", connected: true}}
+	bluesky := &fakePoster{platform: "bluesky", connected: true}
+	h := NewAPIPostHandler(s, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey, bluesky))
+	target := PostTarget("misskey,bluesky")
+
+	h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: target})
+	h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: target})
+
+	// セッションの最初の投稿は通常の投稿、以降は直前の投稿への返信になる
+	require.Len(t, misskey.posted, 2)
+	assert.Empty(t, misskey.posted[0].ReplyTo)
+	assert.Equal(t, "misskey-id", misskey.posted[1].ReplyTo)
+	// 返信に対応しないプラットフォームは常に通常の投稿
+	require.Len(t, bluesky.posted, 2)
+	assert.Empty(t, bluesky.posted[1].ReplyTo)
+
+	// 間隔が空くと新しいセッションになる
+	for i := range s.posts {
+		s.posts[i].CreatedAt = time.Now().Add(-time.Hour)
+	}
+	resp := h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey, DryRun: true})
+
+	assert.Empty(t, resp.Previews["misskey"].ReplyTo)
+}
+
+func TestPublishPlayback_SessionThreadingDisabled(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	misskey := &fakeReplyPoster{&fakePoster{platform: "misskey", connected: true}}
+	h := NewAPIPostHandler(&fakePostStore{}, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey))
+
+	h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey})
+	h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey})
+
+	require.Len(t, misskey.posted, 2)
+	assert.Empty(t, misskey.posted[1].ReplyTo)
+}
+
+func TestPublishPlayback_PostsConcurrently(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	s := &fakePostStore{}
+	misskey := &fakePoster{platform: "misskey", connected: true, delay: 100 * time.Millisecond}
+	twitter := &fakePoster{platform: "twitter", connected: true, delay: 100 * time.Millisecond}
+	mastodon := &fakePoster{platform: "mastodon", connected: true, delay: 100 * time.Millisecond}
+	h := NewAPIPostHandler(s, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey, twitter, mastodon))
+
+	started := time.Now()
+	resp := h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: "misskey,twitter,mastodon"})
+
+	assert.Less(t, time.Since(started), 250*time.Millisecond)
+	assert.Equal(t, map[string]string{"misskey": "success", "twitter": "success", "mastodon": "success"}, resp.Results)
+	// 履歴は投稿の完了順ではなくプラットフォームの順に記録される
+	require.Len(t, s.posts, 3)
+	assert.Equal(t, []string{"misskey", "twitter", "mastodon"}, []string{s.posts[0].Platform, s.posts[1].Platform, s.posts[2].Platform})
+}
+
+func TestPublishPlayback_PlatformTimeout(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	s := &fakePostStore{}
+	misskey := &fakePoster{platform: "misskey", connected: true, delay: time.Second}
+	twitter := &fakePoster{platform: "twitter", connected: true}
+	h := NewAPIPostHandler(s, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey, twitter))
+	h.postTimeout = 50 * time.Millisecond
+
+	started := time.Now()
+	resp := h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetBoth})
+
+	assert.Less(t, time.Since(started), 500*time.Millisecond)
+	assert.True(t, resp.Success)
+	assert.Equal(t, map[string]string{"misskey": "timeout", "twitter": "success"}, resp.Results)
+	require.Len(t, s.posts, 2)
+	assert.Equal(t, store.PostStatusFailed, s.posts[0].Status)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/auth"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+// AutoPostHandler handles auto-post settings
+type AutoPostHandler struct {
+	store     *store.Store
+	available bool
+}
+
+// NewAutoPostHandler creates a new AutoPostHandler.
+// available reports whether the server runs the auto-post scheduler.
+func NewAutoPostHandler(s *store.Store, available bool) *AutoPostHandler {
+	return &AutoPostHandler{
+		store:     s,
+		available: available,
+	}
+}
+
+// AutoPostSettingsRequest is the request body for updating auto-post settings
+type AutoPostSettingsRequest struct {
+	Enabled bool   `json:"enabled"`
+	Target  string `json:"target"`
+}
+
+// AutoPostSettingsResponse represents the auto-post settings response
+type AutoPostSettingsResponse struct {
+	Available    bool   `json:"available"`
+	Enabled      bool   `json:"enabled"`
+	Target       string `json:"target"`
+	LastItemURI  string `json:"last_item_uri,omitempty"`
+	LastPostedAt string `json:"last_posted_at,omitempty"`
+}
+
+// GetAutoPostSettings returns the current user's auto-post settings
+// GET /api/settings/autopost
+func (h *AutoPostHandler) GetAutoPostSettings(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	settings, err := h.store.GetAutoPostSettings(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get autopost settings"})
+	}
+
+	return c.JSON(http.StatusOK, h.newAutoPostSettingsResponse(settings))
+}
+
+// UpdateAutoPostSettings updates the current user's auto-post settings
+// PUT /api/settings/autopost
+func (h *AutoPostHandler) UpdateAutoPostSettings(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req AutoPostSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+
+	target, err := ParsePostTargetList(req.Target)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid target"})
+	}
+	if target == "" {
+		target = PostTargetBoth
+	}
+
+	ctx := c.Request().Context()
+	settings, err := h.store.UpsertAutoPostSettings(ctx, userID, req.Enabled, string(target))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to save autopost settings"})
+	}
+
+	return c.JSON(http.StatusOK, h.newAutoPostSettingsResponse(settings))
+}
+
+// newAutoPostSettingsResponse converts stored settings (nil if never saved) to a response
+func (h *AutoPostHandler) newAutoPostSettingsResponse(settings *store.AutoPostSettings) AutoPostSettingsResponse {
+	resp := AutoPostSettingsResponse{
+		Available: h.available,
+		Target:    string(PostTargetBoth),
+	}
+	if settings == nil {
+		return resp
+	}
+
+	resp.Enabled = settings.Enabled
+	resp.Target = settings.Target
+	if settings.LastItemURI.Valid {
+		resp.LastItemURI = settings.LastItemURI.String
+	}
+	if settings.LastPostedAt.Valid {
+		resp.LastPostedAt = settings.LastPostedAt.Time.Format(time.RFC3339)
+	}
+	return resp
+}
@@ -0,0 +1,394 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+)
+
+// defaultBlueskyPDSURL is used when the user does not specify a PDS
+const defaultBlueskyPDSURL = "https://bsky.social"
+
+// BlueskyAPIError represents an error returned by an XRPC endpoint
+type BlueskyAPIError struct {
+	StatusCode int
+	ErrorName  string `json:"error"`
+	Message    string `json:"message"`
+	// RetryAfter is the delay requested by the server, if any
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *BlueskyAPIError) Error() string {
+	return fmt.Sprintf("bluesky api error: %d - %s: %s", e.StatusCode, e.ErrorName, e.Message)
+}
+
+// expired reports whether the error means the access token must be refreshed
+func (e *BlueskyAPIError) expired() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.ErrorName == "ExpiredToken" || e.ErrorName == "InvalidToken"
+}
+
+// BlueskySession represents the response from createSession / refreshSession
+type BlueskySession struct {
+	DID        string          `json:"did"`
+	Handle     string          `json:"handle"`
+	AccessJwt  string          `json:"accessJwt"`
+	RefreshJwt string          `json:"refreshJwt"`
+	DIDDoc     json.RawMessage `json:"didDoc,omitempty"`
+}
+
+// BlueskyCreateSessionRequest represents the request body for com.atproto.server.createSession
+type BlueskyCreateSessionRequest struct {
+	Identifier string `json:"identifier"`
+	Password   string `json:"password"`
+}
+
+// BlueskyPostRecord represents an app.bsky.feed.post record
+type BlueskyPostRecord struct {
+	Type      string                `json:"$type"`
+	Text      string                `json:"text"`
+	CreatedAt string                `json:"createdAt"`
+	Facets    []BlueskyFacet        `json:"facets,omitempty"`
+	Embed     *BlueskyExternalEmbed `json:"embed,omitempty"`
+}
+
+// BlueskyFacet annotates a byte range of the post text
+type BlueskyFacet struct {
+	Index    BlueskyByteSlice      `json:"index"`
+	Features []BlueskyFacetFeature `json:"features"`
+}
+
+// BlueskyByteSlice is a UTF-8 byte range in the post text
+type BlueskyByteSlice struct {
+	ByteStart int `json:"byteStart"`
+	ByteEnd   int `json:"byteEnd"`
+}
+
+// BlueskyFacetFeature is a link or tag facet feature
+type BlueskyFacetFeature struct {
+	Type string `json:"$type"`
+	URI  string `json:"uri,omitempty"`
+	Tag  string `json:"tag,omitempty"`
+}
+
+// BlueskyExternalEmbed represents an app.bsky.embed.external link card
+type BlueskyExternalEmbed struct {
+	Type     string          `json:"$type"`
+	External BlueskyExternal `json:"external"`
+}
+
+// BlueskyExternal is the link card content
+type BlueskyExternal struct {
+	URI         string          `json:"uri"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Thumb       json.RawMessage `json:"thumb,omitempty"`
+}
+
+// BlueskyCreateRecordRequest represents the request body for com.atproto.repo.createRecord
+type BlueskyCreateRecordRequest struct {
+	Repo       string            `json:"repo"`
+	Collection string            `json:"collection"`
+	Record     BlueskyPostRecord `json:"record"`
+}
+
+// BlueskyCreateRecordResponse represents the response from com.atproto.repo.createRecord
+type BlueskyCreateRecordResponse struct {
+	URI string `json:"uri"`
+	CID string `json:"cid"`
+}
+
+// BlueskyDeleteRecordRequest represents the request body for com.atproto.repo.deleteRecord
+type BlueskyDeleteRecordRequest struct {
+	Repo       string `json:"repo"`
+	Collection string `json:"collection"`
+	RKey       string `json:"rkey"`
+}
+
+// BlueskyUploadBlobResponse represents the response from com.atproto.repo.uploadBlob
+type BlueskyUploadBlobResponse struct {
+	Blob json.RawMessage `json:"blob"`
+}
+
+var (
+	blueskyLinkPattern = regexp.MustCompile(`https?://[^\s]+`)
+	blueskyTagPattern  = regexp.MustCompile(`(?:^|\s)(#[^\s#]+)`)
+)
+
+// normalizeBlueskyPDSURL adds the scheme to a PDS URL and validates it (default: bsky.social)
+func normalizeBlueskyPDSURL(pdsURL string) (string, error) {
+	if pdsURL == "" {
+		return defaultBlueskyPDSURL, nil
+	}
+	if !strings.HasPrefix(pdsURL, "http://") && !strings.HasPrefix(pdsURL, "https://") {
+		pdsURL = "https://" + pdsURL
+	}
+	normalized, err := validatePublicHTTPSURL(strings.TrimSuffix(pdsURL, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid bluesky PDS URL: %w", err)
+	}
+	return normalized, nil
+}
+
+// blueskyXRPC calls an XRPC procedure on the PDS and decodes the JSON response into out (if non-nil)
+func blueskyXRPC(ctx context.Context, pdsURL, bearer, nsid, contentType string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", pdsURL+"/xrpc/"+nsid, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &BlueskyAPIError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.StatusCode, resp.Header, time.Now())}
+		respBody, _ := io.ReadAll(resp.Body)
+		_ = json.Unmarshal(respBody, apiErr)
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// blueskyJSON calls an XRPC procedure with a JSON body
+func blueskyJSON(ctx context.Context, pdsURL, bearer, nsid string, in, out any) error {
+	jsonBody, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return blueskyXRPC(ctx, pdsURL, bearer, nsid, "application/json", bytes.NewBuffer(jsonBody), out)
+}
+
+// createBlueskySession logs in with a handle (or DID) and app password
+func createBlueskySession(ctx context.Context, pdsURL, identifier, appPassword string) (*BlueskySession, error) {
+	var session BlueskySession
+	req := BlueskyCreateSessionRequest{Identifier: identifier, Password: appPassword}
+	if err := blueskyJSON(ctx, pdsURL, "", "com.atproto.server.createSession", req, &session); err != nil {
+		return nil, err
+	}
+	if session.DID == "" || session.AccessJwt == "" {
+		return nil, errors.New("bluesky returned no session")
+	}
+	return &session, nil
+}
+
+// refreshBlueskySession exchanges a refresh JWT for a new session
+func refreshBlueskySession(ctx context.Context, pdsURL, refreshJwt string) (*BlueskySession, error) {
+	var session BlueskySession
+	if err := blueskyXRPC(ctx, pdsURL, refreshJwt, "com.atproto.server.refreshSession", "", nil, &session); err != nil {
+		return nil, err
+	}
+	if session.AccessJwt == "" {
+		return nil, errors.New("bluesky returned no session")
+	}
+	return &session, nil
+}
+
+// blueskyPDSFromDIDDoc returns the PDS endpoint declared in a DID document, if any
+func blueskyPDSFromDIDDoc(didDoc json.RawMessage) string {
+	var doc struct {
+		Service []struct {
+			ID              string `json:"id"`
+			ServiceEndpoint string `json:"serviceEndpoint"`
+		} `json:"service"`
+	}
+	if len(didDoc) == 0 || json.Unmarshal(didDoc, &doc) != nil {
+		return ""
+	}
+	for _, svc := range doc.Service {
+		if svc.ID == "#atproto_pds" {
+			return svc.ServiceEndpoint
+		}
+	}
+	return ""
+}
+
+// withBlueskySession runs fn with the user's access JWT. If the token has expired,
+// the session is refreshed (or recreated from the app password), persisted, and fn is retried once.
+func withBlueskySession(ctx context.Context, s *store.Store, user *store.User, fn func(pdsURL, accessJwt string) error) error {
+	if !user.BlueskyAppPassword.Valid || user.BlueskyAppPassword.String == "" {
+		return errors.New("bluesky not connected")
+	}
+
+	pdsURL, err := normalizeBlueskyPDSURL(user.BlueskyPDSURL.String)
+	if err != nil {
+		return err
+	}
+
+	if user.BlueskyAccessJwt.Valid && user.BlueskyAccessJwt.String != "" {
+		err = fn(pdsURL, user.BlueskyAccessJwt.String)
+		var apiErr *BlueskyAPIError
+		if err == nil || !errors.As(err, &apiErr) || !apiErr.expired() {
+			return err
+		}
+	}
+
+	// Refresh the session, falling back to a new login with the app password
+	var session *BlueskySession
+	if user.BlueskyRefreshJwt.Valid && user.BlueskyRefreshJwt.String != "" {
+		session, err = refreshBlueskySession(ctx, pdsURL, user.BlueskyRefreshJwt.String)
+	}
+	if session == nil {
+		identifier := user.BlueskyDID.String
+		if identifier == "" {
+			identifier = user.BlueskyHandle.String
+		}
+		session, err = createBlueskySession(ctx, pdsURL, identifier, user.BlueskyAppPassword.String)
+		if err != nil {
+			return fmt.Errorf("failed to refresh bluesky session: %w", err)
+		}
+	}
+
+	if err := s.UpdateBlueskySession(ctx, user.ID, session.AccessJwt, session.RefreshJwt); err != nil {
+		return err
+	}
+	user.BlueskyAccessJwt = sql.NullString{String: session.AccessJwt, Valid: true}
+	user.BlueskyRefreshJwt = sql.NullString{String: session.RefreshJwt, Valid: true}
+
+	return fn(pdsURL, session.AccessJwt)
+}
+
+// blueskyFacets returns link and hashtag facets for the post text
+func blueskyFacets(text string) []BlueskyFacet {
+	var facets []BlueskyFacet
+	for _, loc := range blueskyLinkPattern.FindAllStringIndex(text, -1) {
+		facets = append(facets, BlueskyFacet{
+			Index:    BlueskyByteSlice{ByteStart: loc[0], ByteEnd: loc[1]},
+			Features: []BlueskyFacetFeature{{Type: "app.bsky.richtext.facet#link", URI: text[loc[0]:loc[1]]}},
+		})
+	}
+	for _, loc := range blueskyTagPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[2], loc[3]
+		facets = append(facets, BlueskyFacet{
+			Index:    BlueskyByteSlice{ByteStart: start, ByteEnd: end},
+			Features: []BlueskyFacetFeature{{Type: "app.bsky.richtext.facet#tag", Tag: text[start+1 : end]}},
+		})
+	}
+	return facets
+}
+
+// newBlueskyPostRecord builds a post with facets and, if the item has a URL, an external link card
+func newBlueskyPostRecord(text string, data posttemplate.Data, thumb json.RawMessage) BlueskyPostRecord {
+	record := BlueskyPostRecord{
+		Type:      "app.bsky.feed.post",
+		Text:      text,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Facets:    blueskyFacets(text),
+	}
+
+	if data.URL != "" {
+		description := data.Artists
+		if data.Type == "episode" {
+			description = data.Show
+		}
+		record.Embed = &BlueskyExternalEmbed{
+			Type: "app.bsky.embed.external",
+			External: BlueskyExternal{
+				URI:         data.URL,
+				Title:       data.Track,
+				Description: description,
+				Thumb:       thumb,
+			},
+		}
+	}
+
+	return record
+}
+
+// BlueskyPoster posts to Bluesky with the user's app password session
+type BlueskyPoster struct {
+	store *store.Store
+}
+
+// Platform returns "bluesky"
+func (p *BlueskyPoster) Platform() string {
+	return string(PostTargetBluesky)
+}
+
+// Connected reports whether the user has connected Bluesky
+func (p *BlueskyPoster) Connected(user *store.User) bool {
+	return user.BlueskyAppPassword.Valid && user.BlueskyAppPassword.String != ""
+}
+
+// Limits returns the Bluesky post length limit
+func (p *BlueskyPoster) Limits() PostLimits {
+	return PostLimits{MaxLength: 300}
+}
+
+// Post posts to Bluesky and returns the AT URI of the created post
+func (p *BlueskyPoster) Post(ctx context.Context, user *store.User, req PostRequest) (string, error) {
+	art, data := req.Artwork, req.Data
+	var uri string
+	err := withBlueskySession(ctx, p.store, user, func(pdsURL, accessJwt string) error {
+		var thumb json.RawMessage
+		if art != nil && data.URL != "" {
+			// Post without a thumbnail if the upload fails
+			var blobResp BlueskyUploadBlobResponse
+			err := blueskyXRPC(ctx, pdsURL, accessJwt, "com.atproto.repo.uploadBlob", art.ContentType, bytes.NewReader(art.Data), &blobResp)
+			var apiErr *BlueskyAPIError
+			if errors.As(err, &apiErr) && apiErr.expired() {
+				return err
+			}
+			if err == nil {
+				thumb = blobResp.Blob
+			}
+		}
+
+		recordReq := BlueskyCreateRecordRequest{
+			Repo:       user.BlueskyDID.String,
+			Collection: "app.bsky.feed.post",
+			Record:     newBlueskyPostRecord(req.Text, data, thumb),
+		}
+		var recordResp BlueskyCreateRecordResponse
+		if err := blueskyJSON(ctx, pdsURL, accessJwt, "com.atproto.repo.createRecord", recordReq, &recordResp); err != nil {
+			return err
+		}
+		uri = recordResp.URI
+		return nil
+	})
+	return uri, err
+}
+
+// Delete deletes a post identified by its AT URI (at://did/app.bsky.feed.post/rkey)
+func (p *BlueskyPoster) Delete(ctx context.Context, user *store.User, postURI string) error {
+	rkey := postURI[strings.LastIndex(postURI, "/")+1:]
+	if rkey == "" {
+		return errors.New("invalid bluesky post uri")
+	}
+
+	return withBlueskySession(ctx, p.store, user, func(pdsURL, accessJwt string) error {
+		req := BlueskyDeleteRecordRequest{
+			Repo:       user.BlueskyDID.String,
+			Collection: "app.bsky.feed.post",
+			RKey:       rkey,
+		}
+		return blueskyJSON(ctx, pdsURL, accessJwt, "com.atproto.repo.deleteRecord", req, nil)
+	})
+}
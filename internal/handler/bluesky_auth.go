@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/auth"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+// BlueskyAuthHandler handles Bluesky app password authentication
+type BlueskyAuthHandler struct {
+	store     *store.Store
+	jwtConfig auth.JWTConfig
+}
+
+// NewBlueskyAuthHandler creates a new BlueskyAuthHandler
+func NewBlueskyAuthHandler(s *store.Store, jwtConfig auth.JWTConfig) *BlueskyAuthHandler {
+	return &BlueskyAuthHandler{
+		store:     s,
+		jwtConfig: jwtConfig,
+	}
+}
+
+// BlueskyConnectRequest is the request body for connecting Bluesky
+type BlueskyConnectRequest struct {
+	Identifier  string `json:"identifier"`
+	AppPassword string `json:"app_password"`
+	PDSURL      string `json:"pds_url"`
+}
+
+// BlueskyConnectResponse is the response for connecting Bluesky
+type BlueskyConnectResponse struct {
+	Handle string `json:"handle"`
+	DID    string `json:"did"`
+	PDSURL string `json:"pds_url"`
+}
+
+// ConnectBluesky verifies the app password by creating a session and stores the account
+// POST /api/bluesky
+func (h *BlueskyAuthHandler) ConnectBluesky(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req BlueskyConnectRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+
+	identifier := strings.TrimPrefix(strings.TrimSpace(req.Identifier), "@")
+	appPassword := strings.TrimSpace(req.AppPassword)
+	if identifier == "" || appPassword == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "identifier and app_password are required"})
+	}
+
+	pdsURL, err := normalizeBlueskyPDSURL(strings.TrimSpace(req.PDSURL))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pds_url"})
+	}
+
+	session, err := createBlueskySession(c.Request().Context(), pdsURL, identifier, appPassword)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "failed to log in to bluesky"})
+	}
+
+	// Prefer the PDS declared in the DID document (e.g. when logging in via bsky.social)
+	if endpoint := blueskyPDSFromDIDDoc(session.DIDDoc); endpoint != "" {
+		if normalized, err := normalizeBlueskyPDSURL(endpoint); err == nil {
+			pdsURL = normalized
+		}
+	}
+
+	ctx := c.Request().Context()
+	if err := h.store.UpdateBlueskyAccount(ctx, userID, pdsURL, session.Handle, session.DID, appPassword, session.AccessJwt, session.RefreshJwt); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to save bluesky account"})
+	}
+
+	return c.JSON(http.StatusOK, BlueskyConnectResponse{
+		Handle: session.Handle,
+		DID:    session.DID,
+		PDSURL: pdsURL,
+	})
+}
+
+// DisconnectBluesky disconnects Bluesky from the user account
+// DELETE /api/bluesky
+func (h *BlueskyAuthHandler) DisconnectBluesky(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	if err := h.store.DisconnectBluesky(ctx, userID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to disconnect"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "bluesky disconnected"})
+}
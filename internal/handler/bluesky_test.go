@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlueskyFacets(t *testing.T) {
+	text := "あとがき / 来栖夏芽\n#NowPlaying #PsrPlaying\nhttps://open.spotify.com/track/abc"
+
+	facets := blueskyFacets(text)
+	require.Len(t, facets, 3)
+
+	link := facets[0]
+	assert.Equal(t, "app.bsky.richtext.facet#link", link.Features[0].Type)
+	assert.Equal(t, "https://open.spotify.com/track/abc", link.Features[0].URI)
+	assert.Equal(t, "https://open.spotify.com/track/abc", text[link.Index.ByteStart:link.Index.ByteEnd])
+
+	tag := facets[1]
+	assert.Equal(t, "app.bsky.richtext.facet#tag", tag.Features[0].Type)
+	assert.Equal(t, "NowPlaying", tag.Features[0].Tag)
+	assert.Equal(t, "#NowPlaying", text[tag.Index.ByteStart:tag.Index.ByteEnd])
+
+	assert.Equal(t, "PsrPlaying", facets[2].Features[0].Tag)
+}
+
+func TestNewBlueskyPostRecord(t *testing.T) {
+	data := posttemplate.Data{
+		Type:    "track",
+		Track:   "あとがき",
+		Artists: "来栖夏芽",
+		URL:     "https://open.spotify.com/track/abc",
+	}
+	thumb := json.RawMessage(`{"$type":"blob"}`)
+
+	record := newBlueskyPostRecord("text https://open.spotify.com/track/abc", data, thumb)
+
+	assert.Equal(t, "app.bsky.feed.post", record.Type)
+	require.NotNil(t, record.Embed)
+	assert.Equal(t, "app.bsky.embed.external", record.Embed.Type)
+	assert.Equal(t, data.URL, record.Embed.External.URI)
+	assert.Equal(t, "あとがき", record.Embed.External.Title)
+	assert.Equal(t, "来栖夏芽", record.Embed.External.Description)
+	assert.JSONEq(t, `{"$type":"blob"}`, string(record.Embed.External.Thumb))
+	assert.Len(t, record.Facets, 1)
+}
+
+func TestNewBlueskyPostRecord_NoURL(t *testing.T) {
+	record := newBlueskyPostRecord("text", posttemplate.Data{Type: "track"}, nil)
+
+	assert.Nil(t, record.Embed)
+	assert.Empty(t, record.Facets)
+}
+
+func TestBlueskyPDSFromDIDDoc(t *testing.T) {
+	didDoc := json.RawMessage(`{"service":[{"id":"#atproto_pds","type":"AtprotoPersonalDataServer","serviceEndpoint":"https://pds.example.com"}]}`)
+
+	assert.Equal(t, "https://pds.example.com", blueskyPDSFromDIDDoc(didDoc))
+	assert.Empty(t, blueskyPDSFromDIDDoc(nil))
+	assert.Empty(t, blueskyPDSFromDIDDoc(json.RawMessage(`{"service":[]}`)))
+}
+
+func TestBlueskyXRPC_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/xrpc/com.atproto.repo.createRecord", r.URL.Path)
+		assert.Equal(t, "Bearer expired-token", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"ExpiredToken","message":"Token has expired"}`))
+	}))
+	defer server.Close()
+
+	err := blueskyJSON(context.Background(), server.URL, "expired-token", "com.atproto.repo.createRecord", map[string]string{}, nil)
+
+	var apiErr *BlueskyAPIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "ExpiredToken", apiErr.ErrorName)
+	assert.True(t, apiErr.expired())
+}
+
+func TestBlueskyXRPC_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		_, _ = w.Write([]byte(`{"did":"did:plc:abc","handle":"alice.bsky.social","accessJwt":"access","refreshJwt":"refresh"}`))
+	}))
+	defer server.Close()
+
+	session, err := createBlueskySession(context.Background(), server.URL, "alice.bsky.social", "app-password")
+
+	require.NoError(t, err)
+	assert.Equal(t, "did:plc:abc", session.DID)
+	assert.Equal(t, "access", session.AccessJwt)
+	assert.Equal(t, "refresh", session.RefreshJwt)
+}
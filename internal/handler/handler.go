@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -9,7 +10,10 @@ import (
 
 	"github.com/Soli0222/spotify-nowplaying/internal/auth"
 	"github.com/Soli0222/spotify-nowplaying/internal/metrics"
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
 	"github.com/Soli0222/spotify-nowplaying/internal/spotify"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
 
 	"github.com/labstack/echo/v4"
 )
@@ -24,9 +28,17 @@ const (
 	PlatformTwitter Platform = "Twitter"
 )
 
+// ShareTemplateStore はシェアリンクに使うユーザーのテンプレートを取得するストア
+type ShareTemplateStore interface {
+	GetUserBySpotifyID(ctx context.Context, spotifyUserID string) (*store.User, error)
+	GetPostTemplates(ctx context.Context, userID uuid.UUID) (map[string]string, error)
+}
+
 // Handler はHTTPハンドラーを管理する構造体
 type Handler struct {
 	spotifyClient spotify.Client
+	// templates はDBが有効な場合のみ設定される（nilなら常に既定のテンプレート）
+	templates ShareTemplateStore
 }
 
 // NewHandler は新しいHandlerを作成する
@@ -36,6 +48,36 @@ func NewHandler(client spotify.Client) *Handler {
 	}
 }
 
+// SetTemplateStore は登録済みユーザーのテンプレートをシェアリンクに使うよう設定する
+func (h *Handler) SetTemplateStore(s ShareTemplateStore) {
+	h.templates = s
+}
+
+// shareRenderer はplatform（テンプレートのキー）のシェアテキストを生成する関数を返す
+// Spotifyアカウントが登録済みユーザーのものならそのユーザーのテンプレート、それ以外は既定のテンプレートを使う
+func (h *Handler) shareRenderer(ctx context.Context, accessToken, platform string) func(posttemplate.Data) string {
+	if h.templates == nil {
+		return posttemplate.RenderDefault
+	}
+
+	spotifyUserID, err := h.spotifyClient.GetCurrentUserID(ctx, accessToken)
+	if err != nil {
+		return posttemplate.RenderDefault
+	}
+	user, err := h.templates.GetUserBySpotifyID(ctx, spotifyUserID)
+	if err != nil || user == nil {
+		return posttemplate.RenderDefault
+	}
+	templates, err := h.templates.GetPostTemplates(ctx, user.ID)
+	if err != nil {
+		return posttemplate.RenderDefault
+	}
+
+	return func(data posttemplate.Data) string {
+		return renderPostText(templates, platform, data)
+	}
+}
+
 // homeHandler は共通のホームハンドラー処理
 func (h *Handler) homeHandler(c echo.Context, platform Platform, platformLabel string) error {
 	cookie, err := c.Cookie("access_token")
@@ -45,7 +87,7 @@ func (h *Handler) homeHandler(c echo.Context, platform Platform, platformLabel s
 
 	accessToken := cookie.Value
 
-	playerResp, duration, err := h.spotifyClient.GetPlayerData(accessToken)
+	playerResp, duration, err := h.spotifyClient.GetPlayerData(c.Request().Context(), accessToken)
 	if err != nil {
 		if apiErr, ok := spotify.IsAPIError(err); ok {
 			metrics.SpotifyAPIRequestDuration.WithLabelValues("player").Observe(duration.Seconds())
@@ -58,9 +100,19 @@ func (h *Handler) homeHandler(c echo.Context, platform Platform, platformLabel s
 	}
 
 	metrics.SpotifyAPIRequestDuration.WithLabelValues("player").Observe(duration.Seconds())
+
+	switch playerResp.ContentState() {
+	case spotify.StateIdle:
+		metrics.SpotifyAPIRequestsTotal.WithLabelValues("player", "204").Inc()
+		return c.String(http.StatusOK, "Nothing is playing.")
+	case spotify.StateAd:
+		metrics.SpotifyAPIRequestsTotal.WithLabelValues("player", "200").Inc()
+		return c.String(http.StatusOK, "An ad is playing.")
+	}
 	metrics.SpotifyAPIRequestsTotal.WithLabelValues("player", "200").Inc()
 
-	shareURL, contentType := spotify.GetShareInfo(playerResp, string(platform))
+	render := h.shareRenderer(c.Request().Context(), accessToken, platformLabel)
+	shareURL, contentType := spotify.GetShareInfoWithTemplate(playerResp, string(platform), render)
 
 	metrics.ShareRedirectsTotal.WithLabelValues(platformLabel, contentType).Inc()
 	return c.Redirect(http.StatusFound, shareURL)
@@ -116,7 +168,7 @@ func (h *Handler) callbackHandler(c echo.Context, platform Platform, platformLab
 		redirectURI = baseURL + "/tweet/callback"
 	}
 
-	tokens, err := h.spotifyClient.ExchangeToken(code, redirectURI)
+	tokens, err := h.spotifyClient.ExchangeToken(c.Request().Context(), code, redirectURI)
 	if err != nil {
 		metrics.OAuthCallbacksTotal.WithLabelValues(platformLabel, "error").Inc()
 		return c.String(http.StatusInternalServerError, "OAuth callback failed")
@@ -1,15 +1,19 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Soli0222/spotify-nowplaying/internal/spotify"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,32 +21,49 @@ import (
 
 // MockSpotifyClient はテスト用のモッククライアント
 type MockSpotifyClient struct {
-	GetPlayerDataFunc func(accessToken string) (*spotify.PlayerResponse, time.Duration, error)
-	ExchangeTokenFunc func(code, redirectURI string) (*spotify.Tokens, error)
-	RefreshTokenFunc  func(refreshToken string) (*spotify.Tokens, error)
+	GetPlayerDataFunc func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error)
+	ExchangeTokenFunc func(ctx context.Context, code, redirectURI string) (*spotify.Tokens, error)
+	RefreshTokenFunc  func(ctx context.Context, refreshToken string) (*spotify.Tokens, error)
+
+	GetRecentlyPlayedFunc func(ctx context.Context, accessToken string, limit int) ([]spotify.PlayHistory, error)
+	GetCurrentUserIDFunc  func(ctx context.Context, accessToken string) (string, error)
 }
 
-func (m *MockSpotifyClient) GetPlayerData(accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+func (m *MockSpotifyClient) GetPlayerData(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
 	if m.GetPlayerDataFunc != nil {
-		return m.GetPlayerDataFunc(accessToken)
+		return m.GetPlayerDataFunc(ctx, accessToken)
 	}
 	return nil, 0, errors.New("not implemented")
 }
 
-func (m *MockSpotifyClient) ExchangeToken(code, redirectURI string) (*spotify.Tokens, error) {
+func (m *MockSpotifyClient) ExchangeToken(ctx context.Context, code, redirectURI string) (*spotify.Tokens, error) {
 	if m.ExchangeTokenFunc != nil {
-		return m.ExchangeTokenFunc(code, redirectURI)
+		return m.ExchangeTokenFunc(ctx, code, redirectURI)
 	}
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockSpotifyClient) RefreshToken(refreshToken string) (*spotify.Tokens, error) {
+func (m *MockSpotifyClient) RefreshToken(ctx context.Context, refreshToken string) (*spotify.Tokens, error) {
 	if m.RefreshTokenFunc != nil {
-		return m.RefreshTokenFunc(refreshToken)
+		return m.RefreshTokenFunc(ctx, refreshToken)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockSpotifyClient) GetRecentlyPlayed(ctx context.Context, accessToken string, limit int) ([]spotify.PlayHistory, error) {
+	if m.GetRecentlyPlayedFunc != nil {
+		return m.GetRecentlyPlayedFunc(ctx, accessToken, limit)
 	}
 	return nil, errors.New("not implemented")
 }
 
+func (m *MockSpotifyClient) GetCurrentUserID(ctx context.Context, accessToken string) (string, error) {
+	if m.GetCurrentUserIDFunc != nil {
+		return m.GetCurrentUserIDFunc(ctx, accessToken)
+	}
+	return "", errors.New("not implemented")
+}
+
 func TestStatusHandler(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/status", nil)
@@ -145,7 +166,7 @@ func TestNoteCallbackHandler_Success(t *testing.T) {
 	t.Setenv("BASE_URL", "http://localhost")
 
 	mockClient := &MockSpotifyClient{
-		ExchangeTokenFunc: func(code, redirectURI string) (*spotify.Tokens, error) {
+		ExchangeTokenFunc: func(ctx context.Context, code, redirectURI string) (*spotify.Tokens, error) {
 			assert.Equal(t, "test-code", code)
 			assert.Equal(t, "http://localhost/note/callback", redirectURI)
 			return &spotify.Tokens{
@@ -184,7 +205,7 @@ func TestTweetCallbackHandler_Success(t *testing.T) {
 	t.Setenv("BASE_URL", "http://localhost")
 
 	mockClient := &MockSpotifyClient{
-		ExchangeTokenFunc: func(code, redirectURI string) (*spotify.Tokens, error) {
+		ExchangeTokenFunc: func(ctx context.Context, code, redirectURI string) (*spotify.Tokens, error) {
 			assert.Equal(t, "test-code", code)
 			return &spotify.Tokens{
 				AccessToken:  "test-access-token",
@@ -243,7 +264,7 @@ func TestNoteHomeHandler_Success(t *testing.T) {
 	t.Setenv("SERVER_URI", "misskey.tld")
 
 	mockClient := &MockSpotifyClient{
-		GetPlayerDataFunc: func(accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+		GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
 			assert.Equal(t, "test-access-token", accessToken)
 			return &spotify.PlayerResponse{
 				CurrentlyPlayingType: "track",
@@ -275,7 +296,7 @@ func TestNoteHomeHandler_Success(t *testing.T) {
 
 func TestTweetHomeHandler_Success(t *testing.T) {
 	mockClient := &MockSpotifyClient{
-		GetPlayerDataFunc: func(accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+		GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
 			return &spotify.PlayerResponse{
 				CurrentlyPlayingType: "track",
 				Item: spotify.Item{
@@ -304,9 +325,103 @@ func TestTweetHomeHandler_Success(t *testing.T) {
 	assert.Contains(t, location, "x.com/intent/tweet")
 }
 
+// fakeShareTemplateStore はテスト用のテンプレート取得先
+type fakeShareTemplateStore struct {
+	users     map[string]*store.User
+	templates map[uuid.UUID]map[string]string
+}
+
+func (s *fakeShareTemplateStore) GetUserBySpotifyID(ctx context.Context, spotifyUserID string) (*store.User, error) {
+	return s.users[spotifyUserID], nil
+}
+
+func (s *fakeShareTemplateStore) GetPostTemplates(ctx context.Context, userID uuid.UUID) (map[string]string, error) {
+	return s.templates[userID], nil
+}
+
+func TestTweetHomeHandler_UsesUserTemplate(t *testing.T) {
+	user := &store.User{ID: uuid.New(), SpotifyUserID: "registered"}
+	templates := &fakeShareTemplateStore{
+		users: map[string]*store.User{"registered": user},
+		templates: map[uuid.UUID]map[string]string{user.ID: {
+			"default": "{{.Track}} (default)",
+			"twitter": "🎧 {{.Track}} / {{.Artists}}",
+		}},
+	}
+
+	tests := []struct {
+		name          string
+		spotifyUserID string
+		spotifyErr    error
+		wantText      string
+	}{
+		{name: "登録済みユーザーのテンプレート", spotifyUserID: "registered", wantText: "🎧 Test Song / Test Artist"},
+		{name: "未登録のユーザー", spotifyUserID: "unknown", wantText: "Test Song / Test Artist\n#NowPlaying"},
+		{name: "ユーザーIDを取得できない", spotifyErr: errors.New("unauthorized"), wantText: "Test Song / Test Artist\n#NowPlaying"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := &MockSpotifyClient{
+				GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+					return &spotify.PlayerResponse{
+						CurrentlyPlayingType: "track",
+						Item: spotify.Item{
+							Name:         "Test Song",
+							Artists:      []spotify.Artist{{Name: "Test Artist"}},
+							ExternalUrls: spotify.ExternalUrls{Spotify: "https://open.spotify.com/track/123"},
+						},
+					}, 0, nil
+				},
+				GetCurrentUserIDFunc: func(ctx context.Context, accessToken string) (string, error) {
+					assert.Equal(t, "test-access-token", accessToken)
+					return tt.spotifyUserID, tt.spotifyErr
+				},
+			}
+			h := NewHandler(mockClient)
+			h.SetTemplateStore(templates)
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/tweet/home", nil)
+			req.AddCookie(&http.Cookie{Name: "access_token", Value: "test-access-token"})
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := h.TweetHomeHandler(c)
+
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusFound, rec.Code)
+			location, err := url.Parse(rec.Header().Get("Location"))
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(location.Query().Get("text"), tt.wantText), location.Query().Get("text"))
+		})
+	}
+}
+
+func TestHomeHandler_NothingPlaying(t *testing.T) {
+	mockClient := &MockSpotifyClient{
+		GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+			return &spotify.PlayerResponse{}, 50 * time.Millisecond, nil
+		},
+	}
+	h := NewHandler(mockClient)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tweet/home", nil)
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: "test-access-token"})
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := h.TweetHomeHandler(c)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Nothing is playing.", rec.Body.String())
+}
+
 func TestHomeHandler_APIError(t *testing.T) {
 	mockClient := &MockSpotifyClient{
-		GetPlayerDataFunc: func(accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+		GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
 			return &spotify.PlayerResponse{}, 50 * time.Millisecond, &spotify.APIError{StatusCode: 401}
 		},
 	}
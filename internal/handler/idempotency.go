@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// idempotencyKeyHeader is the request header carrying the client-chosen key
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks a response replayed from a previous request
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// idempotencyKeyTTL is how long a stored response is replayed
+	idempotencyKeyTTL = 24 * time.Hour
+	// maxIdempotencyKeyLength is the maximum accepted key length
+	maxIdempotencyKeyLength = 255
+	// idempotencyLeaseMargin is added to the post timeout to cover fetching playback and storing
+	// the response; a reservation older than that is assumed to be abandoned and may be taken over
+	idempotencyLeaseMargin = time.Minute
+)
+
+// idempotencyRequestHash fingerprints the request parameters so that a key reused
+// for a different request can be detected
+func idempotencyRequestHash(route string, params any) (string, error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(route+"\n"), body...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// withIdempotency runs publish at most once per Idempotency-Key header value. Retries with the
+// same key and parameters replay the stored response; reusing the key with different parameters
+// is rejected. Requests without the header run publish directly.
+func (h *APIPostHandler) withIdempotency(c echo.Context, user *store.User, route string, params any, publish func() (int, PostResponse)) error {
+	key := strings.TrimSpace(c.Request().Header.Get(idempotencyKeyHeader))
+	if key == "" {
+		status, resp := publish()
+		return c.JSON(status, resp)
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return c.JSON(http.StatusBadRequest, PostResponse{Success: false, Message: "idempotency key is too long"})
+	}
+
+	requestHash, err := idempotencyRequestHash(route, params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, PostResponse{Success: false, Message: "failed to hash request"})
+	}
+
+	ctx := c.Request().Context()
+	record, err := h.store.ReserveIdempotencyKey(ctx, user.ID, key, requestHash, idempotencyKeyTTL, h.postTimeout+idempotencyLeaseMargin)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, PostResponse{Success: false, Message: "database error"})
+	}
+	if record != nil {
+		if record.RequestHash != requestHash {
+			return c.JSON(http.StatusUnprocessableEntity, PostResponse{Success: false, Message: "idempotency key was already used with different parameters"})
+		}
+		if !record.Completed() {
+			return c.JSON(http.StatusConflict, PostResponse{Success: false, Message: "a request with this idempotency key is in progress"})
+		}
+		c.Response().Header().Set(idempotentReplayedHeader, "true")
+		return c.JSONBlob(int(record.StatusCode.Int32), record.ResponseBody)
+	}
+
+	status, resp := publish()
+
+	// Store the outcome even if the client has gone away, so that its retry is replayed
+	storeCtx := context.WithoutCancel(ctx)
+	body, err := json.Marshal(resp)
+	if err != nil || status >= http.StatusInternalServerError {
+		// Nothing was posted (or the response cannot be stored), so let the client retry
+		_ = h.store.ReleaseIdempotencyKey(storeCtx, user.ID, key)
+	} else {
+		_ = h.store.CompleteIdempotencyKey(storeCtx, user.ID, key, status, body)
+	}
+
+	return c.JSON(status, resp)
+}
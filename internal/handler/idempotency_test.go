@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/auth"
+	"github.com/Soli0222/spotify-nowplaying/internal/spotify"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// idempotencyFixture は冪等性キーのテスト用にハンドラーとユーザーを用意する
+type idempotencyFixture struct {
+	h       *APIPostHandler
+	user    *store.User
+	poster  *fakePoster
+	fetches int
+}
+
+const idempotencyTestHeaderToken = "header-token"
+
+func newIdempotencyFixture() *idempotencyFixture {
+	f := &idempotencyFixture{
+		user: &store.User{
+			ID:                    uuid.New(),
+			APIURLToken:           uuid.New(),
+			SpotifyAccessToken:    sql.NullString{String: "spotify-token", Valid: true},
+			APIHeaderTokenEnabled: true,
+			APIHeaderTokenHash:    sql.NullString{String: auth.HashToken(idempotencyTestHeaderToken), Valid: true},
+		},
+		poster: &fakePoster{platform: "misskey", connected: true},
+	}
+	s := &fakePostStore{user: f.user}
+	client := &MockSpotifyClient{
+		GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+			f.fetches++
+			return playingTrack(), 0, nil
+		},
+	}
+	f.h = NewAPIPostHandler(s, client, spotify.NewTokenSource(client, s), NewPosterRegistry(f.poster))
+	return f
+}
+
+func (f *idempotencyFixture) post(t *testing.T, query, key string) *httptest.ResponseRecorder {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/post/"+f.user.APIURLToken.String()+query, nil)
+	req.Header.Set("Authorization", "Bearer "+idempotencyTestHeaderToken)
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("token")
+	c.SetParamValues(f.user.APIURLToken.String())
+
+	require.NoError(t, f.h.PostNowPlaying(c))
+	return rec
+}
+
+func TestPostNowPlaying_IdempotencyKeyReplaysResponse(t *testing.T) {
+	f := newIdempotencyFixture()
+
+	first := f.post(t, "?target=misskey", "retry-1")
+	second := f.post(t, "?target=misskey", "retry-1")
+
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.JSONEq(t, first.Body.String(), second.Body.String())
+	assert.Empty(t, first.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, "true", second.Header().Get(idempotentReplayedHeader))
+	assert.Len(t, f.poster.posted, 1)
+	assert.Equal(t, 1, f.fetches)
+}
+
+func TestPostNowPlaying_IdempotencyKeyConflict(t *testing.T) {
+	f := newIdempotencyFixture()
+
+	f.post(t, "?target=misskey", "retry-1")
+	rec := f.post(t, "?target=misskey&media=true", "retry-1")
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "different parameters")
+	assert.Len(t, f.poster.posted, 1)
+}
+
+func TestPostNowPlaying_WithoutIdempotencyKey(t *testing.T) {
+	f := newIdempotencyFixture()
+
+	f.post(t, "?target=misskey", "")
+	f.post(t, "?target=misskey", "")
+
+	assert.Len(t, f.poster.posted, 2)
+}
+
+func TestPostNowPlaying_IdempotencyKeyReleasedOnServerError(t *testing.T) {
+	f := newIdempotencyFixture()
+	f.h.spotifyClient = &MockSpotifyClient{
+		GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+			return nil, 0, assert.AnError
+		},
+	}
+
+	rec := f.post(t, "?target=misskey", "retry-1")
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	// 失敗した要求は保存されず、同じキーで再試行できる
+	f.h.spotifyClient = &MockSpotifyClient{
+		GetPlayerDataFunc: func(ctx context.Context, accessToken string) (*spotify.PlayerResponse, time.Duration, error) {
+			return playingTrack(), 0, nil
+		},
+	}
+	rec = f.post(t, "?target=misskey", "retry-1")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(idempotentReplayedHeader))
+	assert.Len(t, f.poster.posted, 1)
+}
+
+func TestPostNowPlaying_IdempotencyKeyAbandonedReservation(t *testing.T) {
+	f := newIdempotencyFixture()
+	s := f.h.store.(*fakePostStore)
+	requestHash, err := idempotencyRequestHash("GET /api/post", PublishOptions{Target: PostTargetMisskey})
+	require.NoError(t, err)
+
+	// 投稿中にプロセスが停止し、完了しなかった予約
+	s.keys = map[string]*store.IdempotencyRecord{
+		f.user.ID.String() + "retry-1": {
+			UserID:      f.user.ID,
+			Key:         "retry-1",
+			RequestHash: requestHash,
+			LockedUntil: sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true},
+		},
+	}
+
+	rec := f.post(t, "?target=misskey", "retry-1")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Empty(t, f.poster.posted)
+
+	// 予約の期限が過ぎると再試行が引き継ぐ
+	s.keys[f.user.ID.String()+"retry-1"].LockedUntil.Time = time.Now().Add(-time.Second)
+	rec = f.post(t, "?target=misskey", "retry-1")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, f.poster.posted, 1)
+	assert.True(t, s.keys[f.user.ID.String()+"retry-1"].Completed())
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+)
+
+// MastodonStatusRequest represents the request body for creating a Mastodon status
+type MastodonStatusRequest struct {
+	Status      string   `json:"status"`
+	Visibility  string   `json:"visibility,omitempty"`
+	InReplyToID string   `json:"in_reply_to_id,omitempty"`
+	MediaIDs    []string `json:"media_ids,omitempty"`
+}
+
+// MastodonStatusResponse represents the response from Mastodon POST /api/v1/statuses
+type MastodonStatusResponse struct {
+	ID string `json:"id"`
+}
+
+// normalizeMastodonInstanceURL adds the scheme to an instance URL and validates it
+func normalizeMastodonInstanceURL(instanceURL string) (string, error) {
+	if !strings.HasPrefix(instanceURL, "http://") && !strings.HasPrefix(instanceURL, "https://") {
+		instanceURL = "https://" + instanceURL
+	}
+	normalized, err := validatePublicHTTPSURL(strings.TrimSuffix(instanceURL, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid mastodon instance URL: %w", err)
+	}
+	return normalized, nil
+}
+
+// mastodonVisibility maps a Visibility to the Mastodon status visibility
+func mastodonVisibility(v Visibility) string {
+	switch v {
+	case VisibilityUnlisted:
+		return "unlisted"
+	case VisibilityFollowers:
+		return "private"
+	case VisibilityDirect:
+		return "direct"
+	default:
+		return "public"
+	}
+}
+
+// MastodonPoster posts statuses to the user's Mastodon-compatible instance
+type MastodonPoster struct{}
+
+// Platform returns "mastodon"
+func (p *MastodonPoster) Platform() string {
+	return string(PostTargetMastodon)
+}
+
+// Connected reports whether the user has connected Mastodon
+func (p *MastodonPoster) Connected(user *store.User) bool {
+	return user.MastodonAccessToken.Valid && user.MastodonAccessToken.String != ""
+}
+
+func (p *MastodonPoster) postsReplies() {}
+
+// Limits returns the default Mastodon status length limit. Mastodon counts every URL as 23 characters.
+func (p *MastodonPoster) Limits() PostLimits {
+	return PostLimits{MaxLength: 500, URLLength: 23}
+}
+
+// Post posts a status to Mastodon and returns the created status ID
+func (p *MastodonPoster) Post(ctx context.Context, user *store.User, req PostRequest) (string, error) {
+	instanceURL, err := normalizeMastodonInstanceURL(user.MastodonInstanceURL.String)
+	if err != nil {
+		return "", err
+	}
+	accessToken := user.MastodonAccessToken.String
+
+	reqBody := newMastodonStatusRequest(req)
+	if req.Artwork != nil {
+		// Post without media if the upload fails (e.g. token lacks write:media)
+		if mediaID, err := uploadToMastodonMedia(ctx, instanceURL, accessToken, req.Artwork); err == nil {
+			reqBody.MediaIDs = []string{mediaID}
+		}
+	}
+
+	return createMastodonStatus(ctx, instanceURL, accessToken, reqBody)
+}
+
+// newMastodonStatusRequest builds the status request body for a post
+func newMastodonStatusRequest(req PostRequest) MastodonStatusRequest {
+	return MastodonStatusRequest{
+		Status:      req.Text,
+		Visibility:  mastodonVisibility(req.Visibility),
+		InReplyToID: req.ReplyTo,
+	}
+}
+
+// createMastodonStatus sends POST /api/v1/statuses and returns the created status ID
+func createMastodonStatus(ctx context.Context, instanceURL, accessToken string, reqBody MastodonStatusRequest) (string, error) {
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", instanceURL+"/api/v1/statuses", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", newPlatformAPIError("mastodon", resp, body)
+	}
+
+	var statusResp MastodonStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&statusResp); err != nil {
+		// The status was created; only the ID is unknown
+		return "", nil
+	}
+
+	return statusResp.ID, nil
+}
+
+// Delete deletes a status from Mastodon
+func (p *MastodonPoster) Delete(ctx context.Context, user *store.User, statusID string) error {
+	instanceURL, err := normalizeMastodonInstanceURL(user.MastodonInstanceURL.String)
+	if err != nil {
+		return err
+	}
+	accessToken := user.MastodonAccessToken.String
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", instanceURL+"/api/v1/statuses/"+url.PathEscape(statusID), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newPlatformAPIError("mastodon", resp, body)
+	}
+
+	return nil
+}
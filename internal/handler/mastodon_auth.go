@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/auth"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+// mastodonScopes are the OAuth scopes requested from Mastodon-compatible instances
+const mastodonScopes = "read:accounts write:statuses write:media"
+
+// MastodonAuthHandler handles Mastodon OAuth 2.0 authentication
+// (also works with compatible servers such as GoToSocial)
+type MastodonAuthHandler struct {
+	store     *store.Store
+	jwtConfig auth.JWTConfig
+}
+
+// NewMastodonAuthHandler creates a new MastodonAuthHandler
+func NewMastodonAuthHandler(s *store.Store, jwtConfig auth.JWTConfig) *MastodonAuthHandler {
+	return &MastodonAuthHandler{
+		store:     s,
+		jwtConfig: jwtConfig,
+	}
+}
+
+// MastodonAuthStartRequest is the request body for starting Mastodon OAuth
+type MastodonAuthStartRequest struct {
+	InstanceURL string `json:"instance_url"`
+}
+
+// MastodonAuthStartResponse is the response for starting Mastodon OAuth
+type MastodonAuthStartResponse struct {
+	AuthURL string `json:"auth_url"`
+}
+
+// MastodonAppResponse is the response from POST /api/v1/apps
+type MastodonAppResponse struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// MastodonTokenResponse is the response from POST /oauth/token
+type MastodonTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	Scope       string `json:"scope"`
+}
+
+// MastodonAccount represents the response from GET /api/v1/accounts/verify_credentials
+type MastodonAccount struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar"`
+}
+
+// StartMastodonAuth registers the app on the instance (once) and starts the OAuth flow
+// POST /api/mastodon/start
+func (h *MastodonAuthHandler) StartMastodonAuth(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req MastodonAuthStartRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+
+	if strings.TrimSpace(req.InstanceURL) == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "instance_url is required"})
+	}
+
+	instanceURL, err := normalizeMastodonInstanceURL(strings.TrimSpace(req.InstanceURL))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid instance_url"})
+	}
+
+	ctx := c.Request().Context()
+	redirectURI := os.Getenv("BASE_URL") + "/api/mastodon/callback"
+
+	// Reuse the app registered on this instance unless BASE_URL has changed
+	app, err := h.store.GetMastodonApp(ctx, instanceURL)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get app"})
+	}
+	if app == nil || app.RedirectURI != redirectURI {
+		registered, err := registerMastodonApp(ctx, instanceURL, redirectURI)
+		if err != nil {
+			return c.JSON(http.StatusBadGateway, map[string]string{"error": "failed to register app on instance"})
+		}
+		// A concurrent request may have stored its own registration first;
+		// always continue with the stored app so the callback can find it
+		app, err = h.store.SaveMastodonApp(ctx, registered)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to save app"})
+		}
+	}
+
+	// Generate state
+	state, err := auth.GenerateRandomToken(16)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to generate state"})
+	}
+
+	// Store the session
+	if err := h.store.CreateMastodonOAuthSession(ctx, userID, state, instanceURL); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to create session"})
+	}
+
+	authURL := fmt.Sprintf("%s/oauth/authorize?response_type=code&client_id=%s&redirect_uri=%s&scope=%s&state=%s",
+		instanceURL,
+		url.QueryEscape(app.ClientID),
+		url.QueryEscape(redirectURI),
+		url.QueryEscape(mastodonScopes),
+		url.QueryEscape(state),
+	)
+
+	return c.JSON(http.StatusOK, MastodonAuthStartResponse{AuthURL: authURL})
+}
+
+// CallbackMastodonAuth handles the Mastodon OAuth callback
+// GET /api/mastodon/callback
+func (h *MastodonAuthHandler) CallbackMastodonAuth(c echo.Context) error {
+	code := c.QueryParam("code")
+	state := c.QueryParam("state")
+
+	if c.QueryParam("error") != "" {
+		return c.Redirect(http.StatusFound, "/dashboard?error=mastodon_auth_denied")
+	}
+
+	if code == "" || state == "" {
+		return c.Redirect(http.StatusFound, "/dashboard?error=missing_params")
+	}
+
+	ctx := c.Request().Context()
+
+	// Get the session
+	session, err := h.store.GetMastodonOAuthSession(ctx, state)
+	if err != nil {
+		return c.Redirect(http.StatusFound, "/dashboard?error=session_error")
+	}
+	if session == nil {
+		return c.Redirect(http.StatusFound, "/dashboard?error=session_not_found")
+	}
+
+	instanceURL, err := normalizeMastodonInstanceURL(session.InstanceURL)
+	if err != nil {
+		return c.Redirect(http.StatusFound, "/dashboard?error=invalid_instance")
+	}
+
+	app, err := h.store.GetMastodonApp(ctx, instanceURL)
+	if err != nil || app == nil {
+		return c.Redirect(http.StatusFound, "/dashboard?error=app_not_found")
+	}
+
+	// Exchange code for token
+	tokenResp, err := exchangeMastodonCode(ctx, instanceURL, app, code)
+	if err != nil {
+		if errors.Is(err, errMastodonTokenResponse) {
+			return c.Redirect(http.StatusFound, "/dashboard?error=parse_failed")
+		}
+		return c.Redirect(http.StatusFound, "/dashboard?error=token_exchange_failed")
+	}
+
+	// Fetch Mastodon account info (ignore error - just don't show profile info)
+	account, err := getMastodonAccount(ctx, instanceURL, tokenResp.AccessToken)
+	if err != nil {
+		account = &MastodonAccount{}
+	}
+
+	// Extract host from instance URL
+	host := instanceURL
+	if parsedURL, err := url.Parse(instanceURL); err == nil {
+		host = parsedURL.Host
+	}
+
+	if err := h.store.UpdateMastodonToken(ctx, session.UserID, instanceURL, tokenResp.AccessToken, account.ID, account.Username, account.Avatar, host); err != nil {
+		return c.Redirect(http.StatusFound, "/dashboard?error=save_failed")
+	}
+
+	// Delete the session (ignore error - session cleanup is best effort)
+	_ = h.store.DeleteMastodonOAuthSession(ctx, state)
+
+	return c.Redirect(http.StatusFound, "/dashboard?success=mastodon_connected")
+}
+
+// DisconnectMastodon disconnects Mastodon from the user account
+// DELETE /api/mastodon
+func (h *MastodonAuthHandler) DisconnectMastodon(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	if err := h.store.DisconnectMastodon(ctx, userID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to disconnect"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "mastodon disconnected"})
+}
+
+// errMastodonTokenResponse is returned when the token endpoint answers 200 without a usable token
+var errMastodonTokenResponse = errors.New("mastodon returned no access token")
+
+// exchangeMastodonCode exchanges an authorization code for an access token
+func exchangeMastodonCode(ctx context.Context, instanceURL string, app *store.MastodonApp, code string) (*MastodonTokenResponse, error) {
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("code", code)
+	data.Set("client_id", app.ClientID)
+	data.Set("client_secret", app.ClientSecret)
+	data.Set("redirect_uri", app.RedirectURI)
+	data.Set("scope", mastodonScopes)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", instanceURL+"/oauth/token", strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("mastodon api error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp MastodonTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil || tokenResp.AccessToken == "" {
+		return nil, errMastodonTokenResponse
+	}
+
+	return &tokenResp, nil
+}
+
+// registerMastodonApp registers this service as an OAuth app on the instance
+func registerMastodonApp(ctx context.Context, instanceURL, redirectURI string) (*store.MastodonApp, error) {
+	appName := os.Getenv("APP_NAME")
+	if appName == "" {
+		appName = "Spotify NowPlaying"
+	}
+
+	data := url.Values{}
+	data.Set("client_name", appName)
+	data.Set("redirect_uris", redirectURI)
+	data.Set("scopes", mastodonScopes)
+	data.Set("website", os.Getenv("BASE_URL"))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", instanceURL+"/api/v1/apps", strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("mastodon api error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var appResp MastodonAppResponse
+	if err := json.NewDecoder(resp.Body).Decode(&appResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if appResp.ClientID == "" || appResp.ClientSecret == "" {
+		return nil, errors.New("mastodon returned no client credentials")
+	}
+
+	return &store.MastodonApp{
+		InstanceURL:  instanceURL,
+		ClientID:     appResp.ClientID,
+		ClientSecret: appResp.ClientSecret,
+		RedirectURI:  redirectURI,
+	}, nil
+}
+
+// getMastodonAccount fetches the authenticated Mastodon account
+func getMastodonAccount(ctx context.Context, instanceURL, accessToken string) (*MastodonAccount, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", instanceURL+"/api/v1/accounts/verify_credentials", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("mastodon api error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var account MastodonAccount
+	if err := json.NewDecoder(resp.Body).Decode(&account); err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
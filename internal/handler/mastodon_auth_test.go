@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/auth"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackMastodonAuth_ErrorRedirects(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		location string
+	}{
+		{name: "認可拒否", query: "?error=access_denied&state=abc", location: "/dashboard?error=mastodon_auth_denied"},
+		{name: "codeなし", query: "?state=abc", location: "/dashboard?error=missing_params"},
+		{name: "stateなし", query: "?code=xyz", location: "/dashboard?error=missing_params"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewMastodonAuthHandler(nil, auth.JWTConfig{})
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/mastodon/callback"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := h.CallbackMastodonAuth(c)
+
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusFound, rec.Code)
+			assert.Equal(t, tt.location, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestExchangeMastodonCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/oauth/token", r.URL.Path)
+		assert.Equal(t, "authorization_code", r.FormValue("grant_type"))
+		assert.Equal(t, "auth-code", r.FormValue("code"))
+		assert.Equal(t, "client-id", r.FormValue("client_id"))
+		assert.Equal(t, "client-secret", r.FormValue("client_secret"))
+		assert.Equal(t, "https://nowplaying.example/api/mastodon/callback", r.FormValue("redirect_uri"))
+		assert.Equal(t, mastodonScopes, r.FormValue("scope"))
+		_, _ = w.Write([]byte(`{"access_token":"access-token","token_type":"Bearer","scope":"read:accounts write:statuses write:media"}`))
+	}))
+	defer server.Close()
+
+	app := &store.MastodonApp{ClientID: "client-id", ClientSecret: "client-secret", RedirectURI: "https://nowplaying.example/api/mastodon/callback"}
+
+	token, err := exchangeMastodonCode(context.Background(), server.URL, app, "auth-code")
+
+	require.NoError(t, err)
+	assert.Equal(t, "access-token", token.AccessToken)
+}
+
+func TestExchangeMastodonCode_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		tokenResponse bool
+	}{
+		{name: "無効なコード", status: http.StatusBadRequest, body: `{"error":"invalid_grant"}`},
+		{name: "トークンなし", status: http.StatusOK, body: `{}`, tokenResponse: true},
+		{name: "不正なJSON", status: http.StatusOK, body: `not json`, tokenResponse: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := exchangeMastodonCode(context.Background(), server.URL, &store.MastodonApp{}, "auth-code")
+
+			require.Error(t, err)
+			assert.Equal(t, tt.tokenResponse, errors.Is(err, errMastodonTokenResponse))
+		})
+	}
+}
+
+func TestRegisterMastodonApp(t *testing.T) {
+	t.Setenv("APP_NAME", "")
+	t.Setenv("BASE_URL", "https://nowplaying.example")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/apps", r.URL.Path)
+		assert.Equal(t, "Spotify NowPlaying", r.FormValue("client_name"))
+		assert.Equal(t, "https://nowplaying.example/api/mastodon/callback", r.FormValue("redirect_uris"))
+		assert.Equal(t, mastodonScopes, r.FormValue("scopes"))
+		assert.Equal(t, "https://nowplaying.example", r.FormValue("website"))
+		_, _ = w.Write([]byte(`{"client_id":"client-id","client_secret":"client-secret"}`))
+	}))
+	defer server.Close()
+
+	app, err := registerMastodonApp(context.Background(), server.URL, "https://nowplaying.example/api/mastodon/callback")
+
+	require.NoError(t, err)
+	assert.Equal(t, &store.MastodonApp{
+		InstanceURL:  server.URL,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURI:  "https://nowplaying.example/api/mastodon/callback",
+	}, app)
+}
+
+func TestRegisterMastodonApp_MissingCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"client_id":"client-id"}`))
+	}))
+	defer server.Close()
+
+	_, err := registerMastodonApp(context.Background(), server.URL, "https://nowplaying.example/api/mastodon/callback")
+
+	assert.Error(t, err)
+}
+
+func TestGetMastodonAccount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/accounts/verify_credentials", r.URL.Path)
+		assert.Equal(t, "Bearer access-token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"id":"1","username":"alice","avatar":"https://files.example/avatar.png"}`))
+	}))
+	defer server.Close()
+
+	account, err := getMastodonAccount(context.Background(), server.URL, "access-token")
+
+	require.NoError(t, err)
+	assert.Equal(t, &MastodonAccount{ID: "1", Username: "alice", Avatar: "https://files.example/avatar.png"}, account)
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMastodonStatusRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		req  PostRequest
+		want MastodonStatusRequest
+	}{
+		{
+			name: "デフォルトは公開",
+			want: MastodonStatusRequest{Visibility: "public"},
+		},
+		{
+			name: "未収載",
+			req:  PostRequest{Visibility: VisibilityUnlisted},
+			want: MastodonStatusRequest{Visibility: "unlisted"},
+		},
+		{
+			name: "フォロワー限定",
+			req:  PostRequest{Visibility: VisibilityFollowers},
+			want: MastodonStatusRequest{Visibility: "private"},
+		},
+		{
+			name: "ダイレクト",
+			req:  PostRequest{Visibility: VisibilityDirect},
+			want: MastodonStatusRequest{Visibility: "direct"},
+		},
+		{
+			name: "セッションの返信",
+			req:  PostRequest{ReplyTo: "109876543210"},
+			want: MastodonStatusRequest{Visibility: "public", InReplyToID: "109876543210"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Text = "hello"
+			tt.want.Status = "hello"
+
+			assert.Equal(t, tt.want, newMastodonStatusRequest(tt.req))
+		})
+	}
+}
+
+func TestCreateMastodonStatus_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v1/statuses", r.URL.Path)
+		assert.Equal(t, "Bearer access-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]any{
+			"status":         "#NowPlaying",
+			"visibility":     "private",
+			"in_reply_to_id": "109876543210",
+			"media_ids":      []any{"media-1"},
+		}, body)
+
+		_, _ = w.Write([]byte(`{"id":"110000000000"}`))
+	}))
+	defer server.Close()
+
+	reqBody := newMastodonStatusRequest(PostRequest{Text: "#NowPlaying", Visibility: VisibilityFollowers, ReplyTo: "109876543210"})
+	reqBody.MediaIDs = []string{"media-1"}
+
+	id, err := createMastodonStatus(context.Background(), server.URL, "access-token", reqBody)
+
+	require.NoError(t, err)
+	assert.Equal(t, "110000000000", id)
+}
+
+func TestCreateMastodonStatus_OmitsEmptyFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]any{"status": "#NowPlaying", "visibility": "public"}, body)
+
+		_, _ = w.Write([]byte(`{"id":"110000000001"}`))
+	}))
+	defer server.Close()
+
+	_, err := createMastodonStatus(context.Background(), server.URL, "access-token", newMastodonStatusRequest(PostRequest{Text: "#NowPlaying"}))
+
+	require.NoError(t, err)
+}
+
+func TestCreateMastodonStatus_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"error":"Validation failed: Text character limit of 500 exceeded"}`))
+	}))
+	defer server.Close()
+
+	_, err := createMastodonStatus(context.Background(), server.URL, "access-token", newMastodonStatusRequest(PostRequest{Text: "#NowPlaying"}))
+
+	var apiErr *PlatformAPIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "mastodon", apiErr.Platform)
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.StatusCode)
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxArtworkSize is the maximum size of downloaded artwork in bytes
+const maxArtworkSize = 5 << 20
+
+var errArtworkHostNotAllowed = errors.New("artwork host is not a Spotify image CDN")
+
+// Artwork is a downloaded image ready to be uploaded to a platform
+type Artwork struct {
+	Data        []byte
+	ContentType string
+	Filename    string
+}
+
+// isSpotifyImageHost reports whether host serves Spotify artwork
+func isSpotifyImageHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "i.scdn.co" ||
+		strings.HasSuffix(host, ".scdn.co") ||
+		strings.HasSuffix(host, ".spotifycdn.com")
+}
+
+// downloadArtwork downloads album or show artwork from the Spotify CDN
+func downloadArtwork(ctx context.Context, imageURL string) (*Artwork, error) {
+	parsed, err := url.Parse(imageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artwork URL: %w", err)
+	}
+	if parsed.Scheme != "https" || !isSpotifyImageHost(parsed.Hostname()) {
+		return nil, errArtworkHostNotAllowed
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", parsed.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download artwork: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download artwork: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxArtworkSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artwork: %w", err)
+	}
+	if len(data) > maxArtworkSize {
+		return nil, errors.New("artwork is too large")
+	}
+
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("artwork has unexpected content type %q", contentType)
+	}
+
+	ext := ".jpg"
+	switch contentType {
+	case "image/png":
+		ext = ".png"
+	case "image/webp":
+		ext = ".webp"
+	}
+
+	return &Artwork{Data: data, ContentType: contentType, Filename: "artwork" + ext}, nil
+}
+
+// newMultipartBody builds a multipart body with the given fields and file part
+func newMultipartBody(fields map[string]string, fileField string, art *Artwork) (*bytes.Buffer, string, error) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			return nil, "", err
+		}
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, fileField, art.Filename))
+	header.Set("Content-Type", art.ContentType)
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(art.Data); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, w.FormDataContentType(), nil
+}
+
+// MisskeyDriveFileResponse represents the response from Misskey drive/files/create
+type MisskeyDriveFileResponse struct {
+	ID string `json:"id"`
+}
+
+// uploadToMisskeyDrive uploads artwork to Misskey Drive and returns the file ID
+func uploadToMisskeyDrive(ctx context.Context, instanceURL, accessToken string, art *Artwork) (string, error) {
+	instanceURL, err := normalizeMisskeyInstanceURL(instanceURL)
+	if err != nil {
+		return "", err
+	}
+
+	body, contentType, err := newMultipartBody(map[string]string{"i": accessToken}, "file", art)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", instanceURL+"/api/drive/files/create", body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("misskey drive error: %d - %s", resp.StatusCode, string(respBody))
+	}
+
+	var fileResp MisskeyDriveFileResponse
+	if err := json.NewDecoder(resp.Body).Decode(&fileResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if fileResp.ID == "" {
+		return "", errors.New("misskey drive returned no file id")
+	}
+	return fileResp.ID, nil
+}
+
+// TwitterMediaUploadResponse represents the response from Twitter POST /2/media/upload
+type TwitterMediaUploadResponse struct {
+	Data struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// uploadToTwitterMedia uploads artwork to Twitter and returns the media ID
+func uploadToTwitterMedia(ctx context.Context, accessToken string, art *Artwork) (string, error) {
+	fields := map[string]string{
+		"media_category": "tweet_image",
+		"media_type":     art.ContentType,
+	}
+	body, contentType, err := newMultipartBody(fields, "media", art)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", twitterAPIBaseURL+"/2/media/upload", body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("twitter media upload error: %d - %s", resp.StatusCode, string(respBody))
+	}
+
+	var mediaResp TwitterMediaUploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mediaResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if mediaResp.Data.ID == "" {
+		return "", errors.New("twitter returned no media id")
+	}
+	return mediaResp.Data.ID, nil
+}
+
+// MastodonMediaResponse represents the response from Mastodon POST /api/v2/media
+type MastodonMediaResponse struct {
+	ID string `json:"id"`
+}
+
+// uploadToMastodonMedia uploads artwork to Mastodon and returns the media ID
+func uploadToMastodonMedia(ctx context.Context, instanceURL, accessToken string, art *Artwork) (string, error) {
+	body, contentType, err := newMultipartBody(nil, "file", art)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", instanceURL+"/api/v2/media", body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// 202 means the media is still being processed; images are usually ready by the time we post
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("mastodon media upload error: %d - %s", resp.StatusCode, string(respBody))
+	}
+
+	var mediaResp MastodonMediaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mediaResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if mediaResp.ID == "" {
+		return "", errors.New("mastodon returned no media id")
+	}
+	return mediaResp.ID, nil
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSpotifyImageHost(t *testing.T) {
+	tests := map[string]bool{
+		"i.scdn.co":                   true,
+		"mosaic.scdn.co":              true,
+		"image-cdn-ak.spotifycdn.com": true,
+		"I.SCDN.CO":                   true,
+		"example.com":                 false,
+		"scdn.co.example.com":         false,
+		"evilscdn.co":                 false,
+	}
+
+	for host, want := range tests {
+		t.Run(host, func(t *testing.T) {
+			assert.Equal(t, want, isSpotifyImageHost(host))
+		})
+	}
+}
+
+func TestDownloadArtwork_RejectsUnsafeURLs(t *testing.T) {
+	tests := []string{
+		"http://i.scdn.co/image/abc",
+		"https://example.com/image/abc",
+		"https://169.254.169.254/latest",
+		"://invalid",
+	}
+
+	for _, rawURL := range tests {
+		t.Run(rawURL, func(t *testing.T) {
+			_, err := downloadArtwork(context.Background(), rawURL)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewMultipartBody(t *testing.T) {
+	art := &Artwork{Data: []byte("image-data"), ContentType: "image/jpeg", Filename: "artwork.jpg"}
+
+	body, contentType, err := newMultipartBody(map[string]string{"i": "token"}, "file", art)
+	require.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/form-data", mediaType)
+
+	form, err := multipart.NewReader(strings.NewReader(body.String()), params["boundary"]).ReadForm(1 << 20)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"token"}, form.Value["i"])
+	require.Len(t, form.File["file"], 1)
+	file := form.File["file"][0]
+	assert.Equal(t, "artwork.jpg", file.Filename)
+	assert.Equal(t, "image/jpeg", file.Header.Get("Content-Type"))
+
+	f, err := file.Open()
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, art.Data, data)
+}
@@ -97,8 +97,8 @@ func (h *MiAuthHandler) StartMiAuth(c echo.Context) error {
 	}
 	callbackURL := os.Getenv("BASE_URL") + "/api/miauth/callback"
 
-	// Permissions: write:notes (to post notes), read:account (to get user info)
-	permission := "write:notes,read:account"
+	// Permissions: write:notes (to post notes), write:drive (to attach artwork), read:account (to get user info)
+	permission := "write:notes,write:drive,read:account"
 
 	authURL := fmt.Sprintf("%s/miauth/%s?name=%s&callback=%s&permission=%s",
 		instanceURL,
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+)
+
+// MisskeyNoteRequest represents the request body for creating a Misskey note
+type MisskeyNoteRequest struct {
+	I                  string   `json:"i"`
+	Text               string   `json:"text"`
+	Visibility         string   `json:"visibility,omitempty"`
+	CW                 string   `json:"cw,omitempty"`
+	LocalOnly          bool     `json:"localOnly,omitempty"`
+	ChannelID          string   `json:"channelId,omitempty"`
+	ReactionAcceptance string   `json:"reactionAcceptance,omitempty"`
+	ReplyID            string   `json:"replyId,omitempty"`
+	FileIDs            []string `json:"fileIds,omitempty"`
+}
+
+// MisskeyNoteResponse represents the response from Misskey notes/create
+type MisskeyNoteResponse struct {
+	CreatedNote struct {
+		ID string `json:"id"`
+	} `json:"createdNote"`
+}
+
+// MisskeyNoteDeleteRequest represents the request body for deleting a Misskey note
+type MisskeyNoteDeleteRequest struct {
+	I      string `json:"i"`
+	NoteID string `json:"noteId"`
+}
+
+// maxMisskeyCWLength is the maximum content warning length accepted by Misskey
+const maxMisskeyCWLength = 100
+
+// misskeyVisibilities lists the Misskey note visibilities
+var misskeyVisibilities = []string{"public", "home", "followers", "specified"}
+
+// misskeyReactionAcceptances lists the Misskey reaction restrictions ("" accepts all reactions)
+var misskeyReactionAcceptances = []string{"", "likeOnly", "likeOnlyForRemote", "nonSensitiveOnly", "nonSensitiveOnlyForLocalLikeOnlyForRemote"}
+
+// misskeyChannelIDPattern matches Misskey IDs
+var misskeyChannelIDPattern = regexp.MustCompile(`^[0-9a-zA-Z]{1,32}$`)
+
+// misskeyVisibility maps a Visibility to the Misskey note visibility
+func misskeyVisibility(v Visibility) string {
+	switch v {
+	case VisibilityUnlisted:
+		return "home"
+	case VisibilityFollowers:
+		return "followers"
+	case VisibilityDirect:
+		return "specified"
+	default:
+		return "public"
+	}
+}
+
+// MisskeyNoteOptions are Misskey note options. Used both to update the user's defaults and
+// to override them per request; nil fields keep the current value and empty strings clear it.
+type MisskeyNoteOptions struct {
+	Visibility         *string `json:"visibility,omitempty"`
+	CW                 *string `json:"cw,omitempty"`
+	LocalOnly          *bool   `json:"local_only,omitempty"`
+	ChannelID          *string `json:"channel_id,omitempty"`
+	ReactionAcceptance *string `json:"reaction_acceptance,omitempty"`
+}
+
+// Validate checks the options against the values Misskey accepts
+func (o *MisskeyNoteOptions) Validate() error {
+	if o.Visibility != nil && !slices.Contains(misskeyVisibilities, *o.Visibility) {
+		return fmt.Errorf("visibility must be one of %s", strings.Join(misskeyVisibilities, ", "))
+	}
+	if o.CW != nil && utf8.RuneCountInString(*o.CW) > maxMisskeyCWLength {
+		return fmt.Errorf("cw must be at most %d characters", maxMisskeyCWLength)
+	}
+	if o.ChannelID != nil && *o.ChannelID != "" && !misskeyChannelIDPattern.MatchString(*o.ChannelID) {
+		return errors.New("invalid channel_id")
+	}
+	if o.ReactionAcceptance != nil && !slices.Contains(misskeyReactionAcceptances, *o.ReactionAcceptance) {
+		return fmt.Errorf("reaction_acceptance must be empty or one of %s", strings.Join(misskeyReactionAcceptances[1:], ", "))
+	}
+	return nil
+}
+
+// Apply returns the settings with the options applied
+func (o *MisskeyNoteOptions) Apply(settings store.MisskeyNoteSettings) store.MisskeyNoteSettings {
+	if o == nil {
+		return settings
+	}
+	if o.Visibility != nil {
+		settings.Visibility = *o.Visibility
+	}
+	if o.CW != nil {
+		settings.CW = sql.NullString{String: *o.CW, Valid: *o.CW != ""}
+	}
+	if o.LocalOnly != nil {
+		settings.LocalOnly = *o.LocalOnly
+	}
+	if o.ChannelID != nil {
+		settings.ChannelID = sql.NullString{String: *o.ChannelID, Valid: *o.ChannelID != ""}
+	}
+	if o.ReactionAcceptance != nil {
+		settings.ReactionAcceptance = sql.NullString{String: *o.ReactionAcceptance, Valid: *o.ReactionAcceptance != ""}
+	}
+	return settings
+}
+
+// newMisskeyNoteRequest builds the note request from the user's defaults and the per-request options.
+// The generic visibility of the request overrides the default, and Misskey options override both.
+func newMisskeyNoteRequest(user *store.User, req PostRequest) MisskeyNoteRequest {
+	settings := user.MisskeyNote
+	if req.Visibility != VisibilityDefault || settings.Visibility == "" {
+		settings.Visibility = misskeyVisibility(req.Visibility)
+	}
+	settings = req.Misskey.Apply(settings)
+
+	return MisskeyNoteRequest{
+		I:                  user.MisskeyAccessToken.String,
+		Text:               req.Text,
+		Visibility:         settings.Visibility,
+		CW:                 settings.CW.String,
+		LocalOnly:          settings.LocalOnly,
+		ChannelID:          settings.ChannelID.String,
+		ReactionAcceptance: settings.ReactionAcceptance.String,
+		ReplyID:            req.ReplyTo,
+	}
+}
+
+// MisskeyPoster posts notes to the user's Misskey instance
+type MisskeyPoster struct{}
+
+// Platform returns "misskey"
+func (p *MisskeyPoster) Platform() string {
+	return string(PostTargetMisskey)
+}
+
+// Connected reports whether the user has connected Misskey
+func (p *MisskeyPoster) Connected(user *store.User) bool {
+	return user.MisskeyAccessToken.Valid && user.MisskeyAccessToken.String != ""
+}
+
+func (p *MisskeyPoster) postsReplies() {}
+
+// Limits returns the default Misskey note length limit
+func (p *MisskeyPoster) Limits() PostLimits {
+	return PostLimits{MaxLength: defaultMisskeyMaxNoteTextLength}
+}
+
+// LimitsFor returns the note length limit of the user's instance, falling back to the default
+func (p *MisskeyPoster) LimitsFor(ctx context.Context, user *store.User) PostLimits {
+	instanceURL, err := normalizeMisskeyInstanceURL(user.MisskeyInstanceURL.String)
+	if err != nil {
+		return p.Limits()
+	}
+	return PostLimits{MaxLength: misskeyNoteLimits.MaxNoteTextLength(ctx, instanceURL)}
+}
+
+// Post posts a note to Misskey and returns the created note ID
+func (p *MisskeyPoster) Post(ctx context.Context, user *store.User, req PostRequest) (string, error) {
+	instanceURL, err := normalizeMisskeyInstanceURL(user.MisskeyInstanceURL.String)
+	if err != nil {
+		return "", err
+	}
+	accessToken := user.MisskeyAccessToken.String
+
+	reqBody := newMisskeyNoteRequest(user, req)
+
+	if req.Artwork != nil {
+		// Post without media if the upload fails (e.g. token lacks write:drive)
+		if fileID, err := uploadToMisskeyDrive(ctx, instanceURL, accessToken, req.Artwork); err == nil {
+			reqBody.FileIDs = []string{fileID}
+		}
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/notes/create", instanceURL)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return "", newPlatformAPIError("misskey", resp, body)
+	}
+
+	var noteResp MisskeyNoteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&noteResp); err != nil {
+		// The note was created; only the ID is unknown
+		return "", nil
+	}
+
+	return noteResp.CreatedNote.ID, nil
+}
+
+// Delete deletes a note from Misskey
+func (p *MisskeyPoster) Delete(ctx context.Context, user *store.User, noteID string) error {
+	instanceURL, err := normalizeMisskeyInstanceURL(user.MisskeyInstanceURL.String)
+	if err != nil {
+		return err
+	}
+	accessToken := user.MisskeyAccessToken.String
+
+	jsonBody, err := json.Marshal(MisskeyNoteDeleteRequest{I: accessToken, NoteID: noteID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", instanceURL+"/api/notes/delete", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return newPlatformAPIError("misskey", resp, body)
+	}
+
+	return nil
+}
+
+// normalizeMisskeyInstanceURL adds the scheme to an instance URL and validates it
+func normalizeMisskeyInstanceURL(instanceURL string) (string, error) {
+	if !strings.HasPrefix(instanceURL, "http://") && !strings.HasPrefix(instanceURL, "https://") {
+		instanceURL = "https://" + instanceURL
+	}
+	normalized, err := validatePublicHTTPSURL(strings.TrimSuffix(instanceURL, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid misskey instance URL: %w", err)
+	}
+	return normalized, nil
+}
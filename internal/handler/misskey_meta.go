@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultMisskeyMaxNoteTextLength is the note length limit of a default Misskey instance
+	defaultMisskeyMaxNoteTextLength = 3000
+	// misskeyMetaTTL is how long an instance's limit is cached
+	misskeyMetaTTL = 24 * time.Hour
+	// misskeyMetaRetryInterval is how long the default is used after the meta request fails
+	misskeyMetaRetryInterval = 5 * time.Minute
+)
+
+// MisskeyMetaResponse is the subset of the Misskey /api/meta response that is used
+type MisskeyMetaResponse struct {
+	MaxNoteTextLength int `json:"maxNoteTextLength"`
+}
+
+// misskeyNoteLimits caches the note length limit of each Misskey instance
+var misskeyNoteLimits = newMisskeyLimitCache(fetchMisskeyMaxNoteTextLength)
+
+// misskeyLimitEntry is a cached instance limit
+type misskeyLimitEntry struct {
+	maxLength int
+	expiresAt time.Time
+}
+
+// misskeyLimitCache caches maxNoteTextLength per instance URL
+type misskeyLimitCache struct {
+	mu      sync.Mutex
+	entries map[string]misskeyLimitEntry
+	flights singleflight.Group
+	fetch   func(ctx context.Context, instanceURL string) (int, error)
+	now     func() time.Time
+}
+
+func newMisskeyLimitCache(fetch func(ctx context.Context, instanceURL string) (int, error)) *misskeyLimitCache {
+	return &misskeyLimitCache{
+		entries: make(map[string]misskeyLimitEntry),
+		fetch:   fetch,
+		now:     time.Now,
+	}
+}
+
+// MaxNoteTextLength returns the instance's note length limit, fetching it from the instance's
+// meta endpoint when it is not cached. The default limit is used while the instance is unreachable.
+func (c *misskeyLimitCache) MaxNoteTextLength(ctx context.Context, instanceURL string) int {
+	c.mu.Lock()
+	entry, ok := c.entries[instanceURL]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiresAt) {
+		return entry.maxLength
+	}
+
+	// Fetch detached from the first caller's cancellation so that it does not cache the default for others
+	maxLength, _, _ := c.flights.Do(instanceURL, func() (any, error) {
+		entry := misskeyLimitEntry{maxLength: defaultMisskeyMaxNoteTextLength, expiresAt: c.now().Add(misskeyMetaRetryInterval)}
+		if maxLength, err := c.fetch(context.WithoutCancel(ctx), instanceURL); err == nil && maxLength > 0 {
+			entry = misskeyLimitEntry{maxLength: maxLength, expiresAt: c.now().Add(misskeyMetaTTL)}
+		}
+
+		c.mu.Lock()
+		c.entries[instanceURL] = entry
+		c.mu.Unlock()
+		return entry.maxLength, nil
+	})
+	return maxLength.(int)
+}
+
+// fetchMisskeyMaxNoteTextLength gets maxNoteTextLength from the instance's /api/meta
+func fetchMisskeyMaxNoteTextLength(ctx context.Context, instanceURL string) (int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", instanceURL+"/api/meta", bytes.NewBufferString(`{"detail":false}`))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("misskey api error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var meta MisskeyMetaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return 0, fmt.Errorf("failed to decode meta: %w", err)
+	}
+	return meta.MaxNoteTextLength, nil
+}
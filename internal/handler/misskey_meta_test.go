@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchMisskeyMaxNoteTextLength(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/meta", r.URL.Path)
+		assert.Equal(t, http.MethodPost, r.Method)
+		_, _ = w.Write([]byte(`{"name":"example","maxNoteTextLength":5000}`))
+	}))
+	defer server.Close()
+
+	maxLength, err := fetchMisskeyMaxNoteTextLength(context.Background(), server.URL)
+
+	require.NoError(t, err)
+	assert.Equal(t, 5000, maxLength)
+}
+
+func TestMisskeyLimitCache(t *testing.T) {
+	fetches := 0
+	fetchErr := errors.New("unreachable")
+	var err error
+	cache := newMisskeyLimitCache(func(ctx context.Context, instanceURL string) (int, error) {
+		fetches++
+		return 5000, err
+	})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	// 取得に失敗した場合はデフォルトの上限を使い、しばらくしてから再取得する
+	err = fetchErr
+	assert.Equal(t, defaultMisskeyMaxNoteTextLength, cache.MaxNoteTextLength(context.Background(), "https://misskey.example"))
+	assert.Equal(t, defaultMisskeyMaxNoteTextLength, cache.MaxNoteTextLength(context.Background(), "https://misskey.example"))
+	assert.Equal(t, 1, fetches)
+
+	err = nil
+	now = now.Add(misskeyMetaRetryInterval)
+	assert.Equal(t, 5000, cache.MaxNoteTextLength(context.Background(), "https://misskey.example"))
+	assert.Equal(t, 5000, cache.MaxNoteTextLength(context.Background(), "https://misskey.example"))
+	assert.Equal(t, 2, fetches)
+
+	// インスタンスごとにキャッシュする
+	cache.MaxNoteTextLength(context.Background(), "https://other.example")
+	assert.Equal(t, 3, fetches)
+}
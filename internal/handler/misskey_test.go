@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestNewMisskeyNoteRequest(t *testing.T) {
+	defaults := store.MisskeyNoteSettings{
+		Visibility: "home",
+		CW:         sql.NullString{String: "NowPlaying", Valid: true},
+		LocalOnly:  true,
+	}
+
+	tests := []struct {
+		name     string
+		settings store.MisskeyNoteSettings
+		req      PostRequest
+		want     MisskeyNoteRequest
+	}{
+		{
+			name: "設定なし",
+			want: MisskeyNoteRequest{Visibility: "public"},
+		},
+		{
+			name:     "ユーザーのデフォルト",
+			settings: defaults,
+			want:     MisskeyNoteRequest{Visibility: "home", CW: "NowPlaying", LocalOnly: true},
+		},
+		{
+			name:     "共通の公開範囲で上書き",
+			settings: defaults,
+			req:      PostRequest{Visibility: VisibilityFollowers},
+			want:     MisskeyNoteRequest{Visibility: "followers", CW: "NowPlaying", LocalOnly: true},
+		},
+		{
+			name:     "Misskeyのオプションで上書き",
+			settings: defaults,
+			req: PostRequest{
+				Visibility: VisibilityFollowers,
+				Misskey: &MisskeyNoteOptions{
+					Visibility:         ptr("specified"),
+					CW:                 ptr(""),
+					LocalOnly:          ptr(false),
+					ChannelID:          ptr("9abcdef012"),
+					ReactionAcceptance: ptr("likeOnly"),
+				},
+			},
+			want: MisskeyNoteRequest{Visibility: "specified", ChannelID: "9abcdef012", ReactionAcceptance: "likeOnly"},
+		},
+		{
+			name: "セッションの返信",
+			req:  PostRequest{ReplyTo: "9xyz"},
+			want: MisskeyNoteRequest{Visibility: "public", ReplyID: "9xyz"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &store.User{MisskeyNote: tt.settings}
+			tt.req.Text = "hello"
+			tt.want.Text = "hello"
+
+			assert.Equal(t, tt.want, newMisskeyNoteRequest(user, tt.req))
+		})
+	}
+}
+
+func TestMisskeyNoteOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    MisskeyNoteOptions
+		wantErr bool
+	}{
+		{name: "空", opts: MisskeyNoteOptions{}},
+		{name: "有効な値", opts: MisskeyNoteOptions{Visibility: ptr("followers"), CW: ptr("ネタバレ"), ChannelID: ptr("9abcdef012"), ReactionAcceptance: ptr("nonSensitiveOnly")}},
+		{name: "値のクリア", opts: MisskeyNoteOptions{CW: ptr(""), ChannelID: ptr(""), ReactionAcceptance: ptr("")}},
+		{name: "不明な公開範囲", opts: MisskeyNoteOptions{Visibility: ptr("unlisted")}, wantErr: true},
+		{name: "長すぎるCW", opts: MisskeyNoteOptions{CW: ptr(string(make([]rune, maxMisskeyCWLength+1)))}, wantErr: true},
+		{name: "不正なチャンネルID", opts: MisskeyNoteOptions{ChannelID: ptr("../notes")}, wantErr: true},
+		{name: "不明なリアクション制限", opts: MisskeyNoteOptions{ReactionAcceptance: ptr("none")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+)
+
+// queuedPost is the post request stored in the outbox to be sent again
+type queuedPost struct {
+	Text       string              `json:"text"`
+	Visibility Visibility          `json:"visibility,omitempty"`
+	Misskey    *MisskeyNoteOptions `json:"misskey,omitempty"`
+	ReplyTo    string              `json:"reply_to,omitempty"`
+	Data       posttemplate.Data   `json:"data"`
+	// Media is the URL of the artwork to download again and attach ("" for none)
+	Media string `json:"media,omitempty"`
+}
+
+// enqueueRetry queues a post that failed transiently to be retried by the outbox worker.
+// If it cannot be queued, the attempt is recorded as failed instead and the error is returned.
+func (h *APIPostHandler) enqueueRetry(ctx context.Context, userID uuid.UUID, platform, itemURI string, attempt *postAttempt) error {
+	payload, err := json.Marshal(attempt.retry)
+	if err == nil {
+		post := &store.Post{
+			UserID:   userID,
+			Platform: platform,
+			ItemURI:  sql.NullString{String: itemURI, Valid: itemURI != ""},
+			Text:     attempt.text,
+			Error:    sql.NullString{String: attempt.err.Error(), Valid: true},
+		}
+		err = h.store.EnqueuePost(ctx, post, payload, time.Now().Add(RetryDelay(1, attempt.retryAfter)))
+	}
+	if err != nil {
+		h.recordPost(ctx, userID, platform, itemURI, attempt.text, "", attempt.err)
+	}
+	return err
+}
+
+// RetryQueuedPost sends a post from the outbox again and returns the remote ID.
+// The returned error can be classified with ClassifyPostError; a platform that is no longer
+// available or connected is a permanent failure.
+func (h *APIPostHandler) RetryQueuedPost(ctx context.Context, user *store.User, entry *store.OutboxEntry) (string, error) {
+	var queued queuedPost
+	if err := json.Unmarshal(entry.Payload, &queued); err != nil {
+		return "", fmt.Errorf("invalid queued post: %w", err)
+	}
+
+	poster, ok := h.posters.Get(entry.Platform)
+	if !ok {
+		return "", fmt.Errorf("unsupported platform: %s", entry.Platform)
+	}
+	if !poster.Connected(user) {
+		return "", fmt.Errorf("%s not connected", entry.Platform)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.postTimeout)
+	defer cancel()
+
+	var art *Artwork
+	if queued.Media != "" {
+		// Post without media if the artwork cannot be downloaded
+		art, _ = h.fetchArtwork(ctx, queued.Media)
+	}
+
+	return poster.Post(ctx, user, PostRequest{
+		Text:       queued.Text,
+		Visibility: queued.Visibility,
+		Misskey:    queued.Misskey,
+		ReplyTo:    queued.ReplyTo,
+		Data:       queued.Data,
+		Artwork:    art,
+	})
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishPlayback_QueuesTransientFailures(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	s := &fakePostStore{}
+	misskey := &fakePoster{platform: "misskey", connected: true, err: &PlatformAPIError{Platform: "misskey", StatusCode: 503, Body: "unavailable"}}
+	twitter := &fakePoster{platform: "twitter", connected: true, err: &TwitterAPIError{StatusCode: 403, Body: "forbidden"}}
+	h := NewAPIPostHandler(s, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey, twitter))
+
+	resp := h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetBoth, Visibility: VisibilityUnlisted})
+
+	assert.False(t, resp.Success)
+	assert.Equal(t, "retrying: misskey api error: 503 - unavailable", resp.Results["misskey"])
+	assert.Equal(t, "error: twitter api error: 403 - forbidden", resp.Results["twitter"])
+
+	require.Len(t, s.posts, 2)
+	queued := s.posts[0]
+	assert.Equal(t, store.PostStatusRetrying, queued.Status)
+	assert.Equal(t, "misskey api error: 503 - unavailable", queued.Error.String)
+	assert.WithinDuration(t, time.Now().Add(RetryDelay(1, 0)), queued.NextRetryAt.Time, time.Second)
+	// 恒久的なエラーは再試行せずに失敗として記録する
+	assert.Equal(t, store.PostStatusFailed, s.posts[1].Status)
+
+	var payload queuedPost
+	require.NoError(t, json.Unmarshal(s.queued[queued.ID], &payload))
+	assert.Equal(t, misskey.posted[0].Text, payload.Text)
+	assert.Equal(t, VisibilityUnlisted, payload.Visibility)
+	assert.Equal(t, "あとがき", payload.Data.Track)
+}
+
+func TestPublishPlayback_RetryNotQueued(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	s := &fakePostStore{enqueueErr: errors.New("database is down")}
+	misskey := &fakePoster{platform: "misskey", connected: true, err: &PlatformAPIError{Platform: "misskey", StatusCode: 503, Body: "unavailable"}}
+	h := NewAPIPostHandler(s, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey))
+
+	resp := h.PublishPlayback(context.Background(), user, playingTrack(), PublishOptions{Target: PostTargetMisskey})
+
+	// キューに入れられなかった場合は失敗として記録し、結果で区別する
+	assert.Equal(t, "retry not queued: misskey api error: 503 - unavailable", resp.Results["misskey"])
+	require.Len(t, s.posts, 1)
+	assert.Equal(t, store.PostStatusFailed, s.posts[0].Status)
+}
+
+func TestRetryQueuedPost(t *testing.T) {
+	user := &store.User{ID: uuid.New()}
+	misskey := &fakePoster{platform: "misskey", connected: true}
+	h := NewAPIPostHandler(&fakePostStore{}, &MockSpotifyClient{}, nil, NewPosterRegistry(misskey))
+	payload, err := json.Marshal(queuedPost{Text: "♪ あとがき", Visibility: VisibilityFollowers, ReplyTo: "previous-note"})
+	require.NoError(t, err)
+
+	remoteID, err := h.RetryQueuedPost(context.Background(), user, &store.OutboxEntry{Platform: "misskey", Payload: payload})
+
+	require.NoError(t, err)
+	assert.Equal(t, "misskey-id", remoteID)
+	require.Len(t, misskey.posted, 1)
+	assert.Equal(t, "♪ あとがき", misskey.posted[0].Text)
+	assert.Equal(t, VisibilityFollowers, misskey.posted[0].Visibility)
+	assert.Equal(t, "previous-note", misskey.posted[0].ReplyTo)
+
+	// 連携が解除されたプラットフォームには再送しない
+	misskey.connected = false
+	_, err = h.RetryQueuedPost(context.Background(), user, &store.OutboxEntry{Platform: "misskey", Payload: payload})
+	assert.EqualError(t, err, "misskey not connected")
+	retryable, _ := ClassifyPostError(err)
+	assert.False(t, retryable)
+}
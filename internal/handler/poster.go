@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+)
+
+// ErrReconnectRequired is returned by a Poster when the user's credentials were
+// revoked or can no longer be refreshed and the account must be connected again
+var ErrReconnectRequired = errors.New("reconnect required")
+
+// Poster publishes posts to a single platform on behalf of a user
+type Poster interface {
+	// Platform returns the platform name used in targets, results and post history
+	Platform() string
+	// Connected reports whether the user has connected an account for the platform
+	Connected(user *store.User) bool
+	// Limits returns the constraints the rendered text must satisfy
+	Limits() PostLimits
+	// Post publishes the request and returns the remote post ID
+	Post(ctx context.Context, user *store.User, req PostRequest) (string, error)
+	// Delete removes a post previously returned by Post
+	Delete(ctx context.Context, user *store.User, remoteID string) error
+}
+
+// replyPoster is implemented by posters that honour PostRequest.ReplyTo
+type replyPoster interface {
+	Poster
+	postsReplies()
+}
+
+// userLimitsPoster is implemented by posters whose limits depend on the user's account,
+// such as the instance a Misskey user is on
+type userLimitsPoster interface {
+	Poster
+	LimitsFor(ctx context.Context, user *store.User) PostLimits
+}
+
+// postLimits returns the limits of the poster for the user's account
+func postLimits(ctx context.Context, poster Poster, user *store.User) PostLimits {
+	if p, ok := poster.(userLimitsPoster); ok {
+		return p.LimitsFor(ctx, user)
+	}
+	return poster.Limits()
+}
+
+// PostLimits describes the rendering limits of a platform
+type PostLimits struct {
+	// MaxLength is the maximum text length as counted by Length
+	MaxLength int `json:"max_length"`
+	// URLLength is the length every URL counts as regardless of its actual length (0 = as written)
+	URLLength int `json:"url_length,omitempty"`
+	// Weighted counts characters outside Latin and common punctuation (e.g. CJK and emoji) as two
+	Weighted bool `json:"weighted,omitempty"`
+}
+
+// Visibility is the audience of a post. Posters map it to the platform's own values;
+// platforms without visibility controls always post publicly.
+type Visibility string
+
+const (
+	VisibilityDefault   Visibility = "" // platform default (public)
+	VisibilityPublic    Visibility = "public"
+	VisibilityUnlisted  Visibility = "unlisted"  // Misskey "home"
+	VisibilityFollowers Visibility = "followers" // Mastodon "private"
+	VisibilityDirect    Visibility = "direct"    // Misskey "specified"
+)
+
+// ParseVisibility parses a visibility value; an empty value means the platform default
+func ParseVisibility(value string) (Visibility, error) {
+	switch v := Visibility(strings.ToLower(strings.TrimSpace(value))); v {
+	case VisibilityDefault, VisibilityPublic, VisibilityUnlisted, VisibilityFollowers, VisibilityDirect:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid visibility: %s", value)
+	}
+}
+
+// PostRequest is the content of a single post
+type PostRequest struct {
+	Text string
+	// Visibility is the requested audience (VisibilityDefault for the user's default)
+	Visibility Visibility
+	// Misskey overrides the user's Misskey note options
+	Misskey *MisskeyNoteOptions
+	// ReplyTo is the remote ID of the user's earlier post to reply to (session threading)
+	ReplyTo string
+	// Data is the playback the text was rendered from
+	Data posttemplate.Data
+	// Artwork is attached as media when non-nil
+	Artwork *Artwork
+}
+
+// PosterRegistry holds the posters for each platform
+type PosterRegistry struct {
+	posters   map[string]Poster
+	platforms []string
+}
+
+// NewPosterRegistry creates a registry from the given posters (in canonical order)
+func NewPosterRegistry(posters ...Poster) *PosterRegistry {
+	r := &PosterRegistry{posters: make(map[string]Poster, len(posters))}
+	for _, p := range posters {
+		if _, ok := r.posters[p.Platform()]; !ok {
+			r.platforms = append(r.platforms, p.Platform())
+		}
+		r.posters[p.Platform()] = p
+	}
+	return r
+}
+
+// DefaultPosters returns the posters for every supported platform
+func DefaultPosters(s *store.Store) []Poster {
+	return []Poster{
+		&MisskeyPoster{},
+		&TwitterPoster{store: s},
+		&MastodonPoster{},
+		&BlueskyPoster{store: s},
+	}
+}
+
+// Get returns the poster for a platform
+func (r *PosterRegistry) Get(platform string) (Poster, bool) {
+	p, ok := r.posters[platform]
+	return p, ok
+}
+
+// Platforms returns the registered platform names in canonical order
+func (r *PosterRegistry) Platforms() []string {
+	return r.platforms
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/auth"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultPostHistoryLimit = 20
+	maxPostHistoryLimit     = 100
+)
+
+// PostHistoryHandler handles post history
+type PostHistoryHandler struct {
+	store   *store.Store
+	posters *PosterRegistry
+}
+
+// NewPostHistoryHandler creates a new PostHistoryHandler
+func NewPostHistoryHandler(s *store.Store, posters *PosterRegistry) *PostHistoryHandler {
+	return &PostHistoryHandler{
+		store:   s,
+		posters: posters,
+	}
+}
+
+// PostHistoryItem represents a post in the history response
+type PostHistoryItem struct {
+	ID        string `json:"id"`
+	Platform  string `json:"platform"`
+	ItemURI   string `json:"item_uri,omitempty"`
+	Text      string `json:"text"`
+	RemoteID  string `json:"remote_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	CreatedAt string `json:"created_at"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+	// Attempts is the number of times the post was sent
+	Attempts int `json:"attempts"`
+	// NextRetryAt is when a retrying post will be sent again
+	NextRetryAt string `json:"next_retry_at,omitempty"`
+}
+
+// PostHistoryResponse represents a page of post history
+type PostHistoryResponse struct {
+	Posts  []PostHistoryItem `json:"posts"`
+	Total  int               `json:"total"`
+	Limit  int               `json:"limit"`
+	Offset int               `json:"offset"`
+}
+
+// ListPosts returns the current user's post history
+// GET /api/posts?platform=&status=&limit=&offset=
+func (h *PostHistoryHandler) ListPosts(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	filter, err := parsePostFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	posts, total, err := h.store.ListPosts(ctx, userID, filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to list posts"})
+	}
+
+	items := make([]PostHistoryItem, 0, len(posts))
+	for _, post := range posts {
+		items = append(items, newPostHistoryItem(post))
+	}
+
+	return c.JSON(http.StatusOK, PostHistoryResponse{
+		Posts:  items,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	})
+}
+
+// DeletePost deletes a previous post from the remote platform
+// DELETE /api/posts/:id
+func (h *PostHistoryHandler) DeletePost(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid post id"})
+	}
+
+	ctx := c.Request().Context()
+	post, err := h.store.GetPost(ctx, userID, postID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get post"})
+	}
+	if post == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "post not found"})
+	}
+	if post.Status != store.PostStatusSuccess || !post.RemoteID.Valid || post.RemoteID.String == "" {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "post cannot be deleted"})
+	}
+
+	user, err := h.store.GetUserByID(ctx, userID)
+	if err != nil || user == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get user"})
+	}
+
+	poster, ok := h.posters.Get(post.Platform)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported platform"})
+	}
+	if !poster.Connected(user) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": post.Platform + " not connected"})
+	}
+
+	err = poster.Delete(ctx, user, post.RemoteID.String)
+	if errors.Is(err, ErrReconnectRequired) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": post.Platform + " reconnect required"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": "failed to delete post: " + err.Error()})
+	}
+
+	if err := h.store.MarkPostDeleted(ctx, post.ID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "post deleted but failed to update history"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "post deleted"})
+}
+
+// parsePostFilter parses the history query parameters
+func parsePostFilter(c echo.Context) (store.PostFilter, error) {
+	filter := store.PostFilter{
+		Platform: strings.ToLower(c.QueryParam("platform")),
+		Status:   strings.ToLower(c.QueryParam("status")),
+		Limit:    defaultPostHistoryLimit,
+	}
+
+	if val := c.QueryParam("limit"); val != "" {
+		limit, err := strconv.Atoi(val)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("invalid limit")
+		}
+		filter.Limit = min(limit, maxPostHistoryLimit)
+	}
+
+	if val := c.QueryParam("offset"); val != "" {
+		offset, err := strconv.Atoi(val)
+		if err != nil || offset < 0 {
+			return filter, errors.New("invalid offset")
+		}
+		filter.Offset = offset
+	}
+
+	switch filter.Status {
+	case "", store.PostStatusSuccess, store.PostStatusFailed, store.PostStatusDeleted, store.PostStatusRetrying:
+	default:
+		return filter, errors.New("invalid status")
+	}
+
+	return filter, nil
+}
+
+func newPostHistoryItem(post store.Post) PostHistoryItem {
+	item := PostHistoryItem{
+		ID:        post.ID.String(),
+		Platform:  post.Platform,
+		Text:      post.Text,
+		Status:    post.Status,
+		CreatedAt: post.CreatedAt.Format(time.RFC3339),
+		Attempts:  post.Attempts,
+	}
+	if post.ItemURI.Valid {
+		item.ItemURI = post.ItemURI.String
+	}
+	if post.RemoteID.Valid {
+		item.RemoteID = post.RemoteID.String
+	}
+	if post.Error.Valid {
+		item.Error = post.Error.String
+	}
+	if post.DeletedAt.Valid {
+		item.DeletedAt = post.DeletedAt.Time.Format(time.RFC3339)
+	}
+	if post.NextRetryAt.Valid {
+		item.NextRetryAt = post.NextRetryAt.Time.Format(time.RFC3339)
+	}
+	return item
+}
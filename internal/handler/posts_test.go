@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePostFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    store.PostFilter
+		wantErr bool
+	}{
+		{"defaults", "", store.PostFilter{Limit: defaultPostHistoryLimit}, false},
+		{"filters", "?platform=Misskey&status=failed&limit=5&offset=10", store.PostFilter{Platform: "misskey", Status: "failed", Limit: 5, Offset: 10}, false},
+		{"limit capped", "?limit=1000", store.PostFilter{Limit: maxPostHistoryLimit}, false},
+		{"invalid limit", "?limit=abc", store.PostFilter{}, true},
+		{"zero limit", "?limit=0", store.PostFilter{}, true},
+		{"negative offset", "?offset=-1", store.PostFilter{}, true},
+		{"deleted status", "?status=deleted", store.PostFilter{Status: "deleted", Limit: defaultPostHistoryLimit}, false},
+		{"invalid status", "?status=unknown", store.PostFilter{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/posts"+tt.query, nil)
+			c := e.NewContext(req, httptest.NewRecorder())
+
+			filter, err := parsePostFilter(c)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, filter)
+		})
+	}
+}
+
+func TestNewPostHistoryItem(t *testing.T) {
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	post := store.Post{
+		ID:        uuid.New(),
+		Platform:  "twitter",
+		ItemURI:   sql.NullString{String: "spotify:track:123", Valid: true},
+		Text:      "Test Song / Test Artist",
+		Status:    store.PostStatusFailed,
+		Error:     sql.NullString{String: "twitter api error: 403", Valid: true},
+		CreatedAt: createdAt,
+	}
+
+	item := newPostHistoryItem(post)
+
+	assert.Equal(t, post.ID.String(), item.ID)
+	assert.Equal(t, "spotify:track:123", item.ItemURI)
+	assert.Equal(t, "", item.RemoteID)
+	assert.Equal(t, "twitter api error: 403", item.Error)
+	assert.Equal(t, "2025-01-02T03:04:05Z", item.CreatedAt)
+}
+
+func TestDeletePost_InvalidID(t *testing.T) {
+	h := NewPostHistoryHandler(nil, NewPosterRegistry())
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/api/posts/not-a-uuid", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", uuid.New())
+	c.SetParamNames("id")
+	c.SetParamValues("not-a-uuid")
+
+	err := h.DeletePost(c)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// retryBaseDelay is the delay before the first retry of a failed post
+	retryBaseDelay = 30 * time.Second
+	// retryMaxDelay caps the exponential backoff between retries
+	retryMaxDelay = time.Hour
+)
+
+// PlatformAPIError represents an error response from a platform API without a dedicated error type
+type PlatformAPIError struct {
+	Platform   string
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay requested by the platform, if any
+	RetryAfter time.Duration
+}
+
+func (e *PlatformAPIError) Error() string {
+	return fmt.Sprintf("%s api error: %d - %s", e.Platform, e.StatusCode, e.Body)
+}
+
+// newPlatformAPIError creates a PlatformAPIError from a failed response
+func newPlatformAPIError(platform string, resp *http.Response, body []byte) *PlatformAPIError {
+	return &PlatformAPIError{
+		Platform:   platform,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.StatusCode, resp.Header, time.Now()),
+	}
+}
+
+// parseRetryAfter returns the delay requested by the Retry-After header (seconds or HTTP date)
+// or, for a 429, the x-rate-limit-reset header (Unix time), or 0 if neither is present.
+// Twitter sends x-rate-limit-reset on every response, so it only applies to rate limit errors.
+func parseRetryAfter(status int, header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return max(time.Duration(seconds)*time.Second, 0)
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return max(at.Sub(now), 0)
+		}
+	}
+	if value := header.Get("x-rate-limit-reset"); value != "" && status == http.StatusTooManyRequests {
+		if reset, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return max(time.Unix(reset, 0).Sub(now), 0)
+		}
+	}
+	return 0
+}
+
+// ClassifyPostError reports whether a failed post may succeed if retried later, and the delay
+// requested by the platform. Rate limits, server errors and connection failures are transient;
+// other client errors (including authentication failures) are permanent. Timeouts, including
+// 408 and 504 responses, are not retried, as the platform may have created the post without
+// responding in time.
+func ClassifyPostError(err error) (retryable bool, retryAfter time.Duration) {
+	if err == nil || errors.Is(err, ErrReconnectRequired) {
+		return false, 0
+	}
+
+	var (
+		platformErr *PlatformAPIError
+		twitterErr  *TwitterAPIError
+		blueskyErr  *BlueskyAPIError
+	)
+	switch {
+	case errors.As(err, &platformErr):
+		return retryableStatus(platformErr.StatusCode), platformErr.RetryAfter
+	case errors.As(err, &twitterErr):
+		return retryableStatus(twitterErr.StatusCode), twitterErr.RetryAfter
+	case errors.As(err, &blueskyErr):
+		return retryableStatus(blueskyErr.StatusCode), blueskyErr.RetryAfter
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && !netErr.Timeout() {
+		return true, 0
+	}
+	return false, 0
+}
+
+// retryableStatus reports whether an HTTP status code indicates a transient failure.
+// 408 and 504 are timeouts reported by the server or a proxy, treated like client timeouts.
+func retryableStatus(status int) bool {
+	if status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout {
+		return false
+	}
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// RetryDelay returns how long to wait before the given retry attempt (1 for the first retry),
+// doubling the delay for each attempt but never waiting less than the platform requested
+func RetryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	delay := retryMaxDelay
+	if shift := attempt - 1; shift < 8 {
+		delay = min(retryBaseDelay<<max(shift, 0), retryMaxDelay)
+	}
+	return max(delay, retryAfter)
+}
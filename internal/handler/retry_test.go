@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyPostError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantRetryable  bool
+		wantRetryAfter time.Duration
+	}{
+		{"Misskeyのサーバーエラー", &PlatformAPIError{Platform: "misskey", StatusCode: 502}, true, 0},
+		{"Twitterのレート制限", &TwitterAPIError{StatusCode: 429, RetryAfter: time.Minute}, true, time.Minute},
+		{"Blueskyのサーバーエラー", &BlueskyAPIError{StatusCode: 503}, true, 0},
+		{"ラップされたエラー", fmt.Errorf("failed to post: %w", &PlatformAPIError{Platform: "mastodon", StatusCode: 500}), true, 0},
+		{"認証エラー", &PlatformAPIError{Platform: "mastodon", StatusCode: 401}, false, 0},
+		{"不正なリクエスト", &TwitterAPIError{StatusCode: 403}, false, 0},
+		{"再連携が必要", ErrReconnectRequired, false, 0},
+		{"接続エラー", fmt.Errorf("failed to send request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), true, 0},
+		{"タイムアウト", context.DeadlineExceeded, false, 0},
+		{"サーバー側のタイムアウト", &PlatformAPIError{Platform: "misskey", StatusCode: 408}, false, 0},
+		{"ゲートウェイのタイムアウト", &BlueskyAPIError{StatusCode: 504}, false, 0},
+		{"ラップされたゲートウェイのタイムアウト", fmt.Errorf("failed to post: %w", &TwitterAPIError{StatusCode: 504}), false, 0},
+		{"その他のエラー", errors.New("failed to upload media"), false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryable, retryAfter := ClassifyPostError(tt.err)
+			assert.Equal(t, tt.wantRetryable, retryable)
+			assert.Equal(t, tt.wantRetryAfter, retryAfter)
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		want   time.Duration
+	}{
+		{"秒数", http.StatusTooManyRequests, http.Header{"Retry-After": {"120"}}, 2 * time.Minute},
+		{"HTTP日付", http.StatusServiceUnavailable, http.Header{"Retry-After": {now.Add(time.Hour).Format(http.TimeFormat)}}, time.Hour},
+		{"レート制限のリセット時刻", http.StatusTooManyRequests, http.Header{"X-Rate-Limit-Reset": {fmt.Sprint(now.Add(15 * time.Minute).Unix())}}, 15 * time.Minute},
+		{"429以外ではリセット時刻を使わない", http.StatusServiceUnavailable, http.Header{"X-Rate-Limit-Reset": {fmt.Sprint(now.Add(15 * time.Minute).Unix())}}, 0},
+		{"過去の時刻", http.StatusTooManyRequests, http.Header{"X-Rate-Limit-Reset": {fmt.Sprint(now.Add(-time.Minute).Unix())}}, 0},
+		{"不正な値", http.StatusTooManyRequests, http.Header{"Retry-After": {"soon"}}, 0},
+		{"ヘッダーなし", http.StatusTooManyRequests, http.Header{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseRetryAfter(tt.status, tt.header, now))
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, RetryDelay(1, 0))
+	assert.Equal(t, time.Minute, RetryDelay(2, 0))
+	assert.Equal(t, 4*time.Minute, RetryDelay(4, 0))
+	assert.Equal(t, time.Hour, RetryDelay(10, 0))
+	assert.Equal(t, time.Hour, RetryDelay(100, 0))
+	// プラットフォームが指定した待ち時間より短くしない
+	assert.Equal(t, 15*time.Minute, RetryDelay(1, 15*time.Minute))
+}
@@ -8,21 +8,24 @@ import (
 	"time"
 
 	"github.com/Soli0222/spotify-nowplaying/internal/auth"
+	"github.com/Soli0222/spotify-nowplaying/internal/spotify"
 	"github.com/Soli0222/spotify-nowplaying/internal/store"
 	"github.com/labstack/echo/v4"
 )
 
 // SettingsHandler handles user settings
 type SettingsHandler struct {
-	store     *store.Store
-	jwtConfig auth.JWTConfig
+	store         *store.Store
+	spotifyTokens *spotify.TokenSource
+	jwtConfig     auth.JWTConfig
 }
 
 // NewSettingsHandler creates a new SettingsHandler
-func NewSettingsHandler(s *store.Store, jwtConfig auth.JWTConfig) *SettingsHandler {
+func NewSettingsHandler(s *store.Store, tokens *spotify.TokenSource, jwtConfig auth.JWTConfig) *SettingsHandler {
 	return &SettingsHandler{
-		store:     s,
-		jwtConfig: jwtConfig,
+		store:         s,
+		spotifyTokens: tokens,
+		jwtConfig:     jwtConfig,
 	}
 }
 
@@ -45,6 +48,20 @@ type UserInfoResponse struct {
 	TwitterUserID    string `json:"twitter_user_id,omitempty"`
 	TwitterUsername  string `json:"twitter_username,omitempty"`
 	TwitterAvatarURL string `json:"twitter_avatar_url,omitempty"`
+	// TwitterReconnectRequired is set when the Twitter token can no longer be refreshed
+	TwitterReconnectRequired bool `json:"twitter_reconnect_required"`
+
+	MastodonConnected   bool   `json:"mastodon_connected"`
+	MastodonInstanceURL string `json:"mastodon_instance_url,omitempty"`
+	MastodonUserID      string `json:"mastodon_user_id,omitempty"`
+	MastodonUsername    string `json:"mastodon_username,omitempty"`
+	MastodonAvatarURL   string `json:"mastodon_avatar_url,omitempty"`
+	MastodonHost        string `json:"mastodon_host,omitempty"`
+
+	BlueskyConnected bool   `json:"bluesky_connected"`
+	BlueskyHandle    string `json:"bluesky_handle,omitempty"`
+	BlueskyDID       string `json:"bluesky_did,omitempty"`
+	BlueskyPDSURL    string `json:"bluesky_pds_url,omitempty"`
 
 	APIURLToken           string `json:"api_url_token"`
 	APIHeaderTokenEnabled bool   `json:"api_header_token_enabled"`
@@ -72,6 +89,8 @@ func (h *SettingsHandler) GetUserInfo(c echo.Context) error {
 		SpotifyUserID:         user.SpotifyUserID,
 		MisskeyConnected:      user.MisskeyAccessToken.Valid && user.MisskeyAccessToken.String != "",
 		TwitterConnected:      user.TwitterAccessToken.Valid && user.TwitterAccessToken.String != "",
+		MastodonConnected:     user.MastodonAccessToken.Valid && user.MastodonAccessToken.String != "",
+		BlueskyConnected:      user.BlueskyAppPassword.Valid && user.BlueskyAppPassword.String != "",
 		APIURLToken:           user.APIURLToken.String(),
 		APIHeaderTokenEnabled: user.APIHeaderTokenEnabled,
 	}
@@ -101,10 +120,37 @@ func (h *SettingsHandler) GetUserInfo(c echo.Context) error {
 	if user.TwitterAvatarURL.Valid {
 		resp.TwitterAvatarURL = user.TwitterAvatarURL.String
 	}
+	resp.TwitterReconnectRequired = user.TwitterReconnectRequired
 
-	// Fetch Spotify user profile if access token is available
-	if user.SpotifyAccessToken.Valid && user.SpotifyAccessToken.String != "" {
-		profile, err := h.getSpotifyUserProfile(user.SpotifyAccessToken.String)
+	if user.MastodonInstanceURL.Valid {
+		resp.MastodonInstanceURL = user.MastodonInstanceURL.String
+	}
+	if user.MastodonUserID.Valid {
+		resp.MastodonUserID = user.MastodonUserID.String
+	}
+	if user.MastodonUsername.Valid {
+		resp.MastodonUsername = user.MastodonUsername.String
+	}
+	if user.MastodonAvatarURL.Valid {
+		resp.MastodonAvatarURL = user.MastodonAvatarURL.String
+	}
+	if user.MastodonHost.Valid {
+		resp.MastodonHost = user.MastodonHost.String
+	}
+
+	if user.BlueskyHandle.Valid {
+		resp.BlueskyHandle = user.BlueskyHandle.String
+	}
+	if user.BlueskyDID.Valid {
+		resp.BlueskyDID = user.BlueskyDID.String
+	}
+	if user.BlueskyPDSURL.Valid {
+		resp.BlueskyPDSURL = user.BlueskyPDSURL.String
+	}
+
+	// Fetch Spotify user profile if a valid access token is available
+	if accessToken, err := h.spotifyTokens.AccessToken(ctx, user); err == nil {
+		profile, err := h.getSpotifyUserProfile(accessToken)
 		if err == nil {
 			resp.SpotifyDisplayName = profile.DisplayName
 			// Use the first (largest) image if available
@@ -290,3 +336,179 @@ func (h *SettingsHandler) GetAppConfig(c echo.Context) error {
 		TwitterEligibility: eligibility,
 	})
 }
+
+// PostSettingsRequest is the request body for updating posting preferences.
+// Omitted fields keep their current values.
+type PostSettingsRequest struct {
+	AttachMedia                 *bool `json:"attach_media"`
+	DedupeWindowMinutes         *int  `json:"dedupe_window_minutes"`
+	SessionGapMinutes           *int  `json:"session_gap_minutes"`
+	PostPaused                  *bool `json:"post_paused"`
+	PostLocalFiles              *bool `json:"post_local_files"`
+	RecentlyPlayedWindowMinutes *int  `json:"recently_played_window_minutes"`
+}
+
+// PostSettingsResponse represents the posting preferences response
+type PostSettingsResponse struct {
+	AttachMedia                 bool `json:"attach_media"`
+	DedupeWindowMinutes         int  `json:"dedupe_window_minutes"`
+	SessionGapMinutes           int  `json:"session_gap_minutes"`
+	PostPaused                  bool `json:"post_paused"`
+	PostLocalFiles              bool `json:"post_local_files"`
+	RecentlyPlayedWindowMinutes int  `json:"recently_played_window_minutes"`
+}
+
+// GetPostSettings returns the current user's posting preferences
+// GET /api/settings/posting
+func (h *SettingsHandler) GetPostSettings(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	settings, err := h.store.GetPostSettings(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get post settings"})
+	}
+
+	return c.JSON(http.StatusOK, newPostSettingsResponse(settings))
+}
+
+// UpdatePostSettings updates the current user's posting preferences
+// PUT /api/settings/posting
+func (h *SettingsHandler) UpdatePostSettings(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req PostSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	settings, err := h.store.GetPostSettings(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get post settings"})
+	}
+
+	if req.AttachMedia != nil {
+		settings.AttachMedia = *req.AttachMedia
+	}
+	if req.DedupeWindowMinutes != nil {
+		if *req.DedupeWindowMinutes < 0 || *req.DedupeWindowMinutes > store.MaxDedupeWindowMinutes {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("dedupe_window_minutes must be between 0 and %d", store.MaxDedupeWindowMinutes)})
+		}
+		settings.DedupeWindowMinutes = *req.DedupeWindowMinutes
+	}
+	if req.SessionGapMinutes != nil {
+		if *req.SessionGapMinutes < 0 || *req.SessionGapMinutes > store.MaxSessionGapMinutes {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("session_gap_minutes must be between 0 and %d", store.MaxSessionGapMinutes)})
+		}
+		settings.SessionGapMinutes = *req.SessionGapMinutes
+	}
+	if req.PostPaused != nil {
+		settings.PostPaused = *req.PostPaused
+	}
+	if req.PostLocalFiles != nil {
+		settings.PostLocalFiles = *req.PostLocalFiles
+	}
+	if req.RecentlyPlayedWindowMinutes != nil {
+		if *req.RecentlyPlayedWindowMinutes < 0 || *req.RecentlyPlayedWindowMinutes > store.MaxRecentlyPlayedWindowMinutes {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("recently_played_window_minutes must be between 0 and %d", store.MaxRecentlyPlayedWindowMinutes)})
+		}
+		settings.RecentlyPlayedWindowMinutes = *req.RecentlyPlayedWindowMinutes
+	}
+
+	if err := h.store.UpsertPostSettings(ctx, settings); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to save post settings"})
+	}
+
+	return c.JSON(http.StatusOK, newPostSettingsResponse(settings))
+}
+
+func newPostSettingsResponse(settings *store.PostSettings) PostSettingsResponse {
+	return PostSettingsResponse{
+		AttachMedia:                 settings.AttachMedia,
+		DedupeWindowMinutes:         settings.DedupeWindowMinutes,
+		SessionGapMinutes:           settings.SessionGapMinutes,
+		PostPaused:                  settings.PostPaused,
+		PostLocalFiles:              settings.PostLocalFiles,
+		RecentlyPlayedWindowMinutes: settings.RecentlyPlayedWindowMinutes,
+	}
+}
+
+// MisskeySettingsResponse represents the user's default Misskey note options
+type MisskeySettingsResponse struct {
+	Visibility         string `json:"visibility"`
+	CW                 string `json:"cw"`
+	LocalOnly          bool   `json:"local_only"`
+	ChannelID          string `json:"channel_id"`
+	ReactionAcceptance string `json:"reaction_acceptance"`
+}
+
+// GetMisskeySettings returns the current user's default Misskey note options
+// GET /api/settings/misskey
+func (h *SettingsHandler) GetMisskeySettings(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	user, err := h.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get user"})
+	}
+	if user == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+
+	return c.JSON(http.StatusOK, newMisskeySettingsResponse(user.MisskeyNote))
+}
+
+// UpdateMisskeySettings updates the current user's default Misskey note options.
+// Omitted fields keep their current values; empty strings clear cw, channel_id and reaction_acceptance.
+// PUT /api/settings/misskey
+func (h *SettingsHandler) UpdateMisskeySettings(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req MisskeyNoteOptions
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	user, err := h.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get user"})
+	}
+	if user == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+
+	settings := req.Apply(user.MisskeyNote)
+	if err := h.store.UpdateMisskeyNoteSettings(ctx, userID, settings); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to save misskey settings"})
+	}
+
+	return c.JSON(http.StatusOK, newMisskeySettingsResponse(settings))
+}
+
+func newMisskeySettingsResponse(settings store.MisskeyNoteSettings) MisskeySettingsResponse {
+	return MisskeySettingsResponse{
+		Visibility:         settings.Visibility,
+		CW:                 settings.CW.String,
+		LocalOnly:          settings.LocalOnly,
+		ChannelID:          settings.ChannelID.String,
+		ReactionAcceptance: settings.ReactionAcceptance.String,
+	}
+}
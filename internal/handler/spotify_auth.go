@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -45,7 +46,7 @@ type SpotifyUserResponse struct {
 // GET /api/auth/spotify
 func (h *SpotifyAuthHandler) LoginSpotify(c echo.Context) error {
 	authURL := "https://accounts.spotify.com/authorize"
-	scope := "user-read-currently-playing user-read-playback-state"
+	scope := "user-read-currently-playing user-read-playback-state user-read-recently-played"
 	clientID := os.Getenv("SPOTIFY_CLIENT_ID")
 	redirectURI := os.Getenv("BASE_URL") + "/api/auth/spotify/callback"
 
@@ -88,20 +89,20 @@ func (h *SpotifyAuthHandler) CallbackSpotify(c echo.Context) error {
 
 	redirectURI := os.Getenv("BASE_URL") + "/api/auth/spotify/callback"
 
+	ctx := c.Request().Context()
+
 	// Exchange code for tokens
-	tokens, err := h.spotifyClient.ExchangeToken(code, redirectURI)
+	tokens, err := h.spotifyClient.ExchangeToken(ctx, code, redirectURI)
 	if err != nil {
 		return c.Redirect(http.StatusFound, "/login?error=token_exchange_failed")
 	}
 
 	// Get user profile from Spotify
-	userProfile, err := h.getSpotifyUserProfile(tokens.AccessToken)
+	userProfile, err := h.getSpotifyUserProfile(ctx, tokens.AccessToken)
 	if err != nil {
 		return c.Redirect(http.StatusFound, "/login?error=profile_fetch_failed")
 	}
 
-	ctx := c.Request().Context()
-
 	// Calculate token expiration
 	expiresAt := time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second)
 
@@ -124,8 +125,8 @@ func (h *SpotifyAuthHandler) CallbackSpotify(c echo.Context) error {
 }
 
 // getSpotifyUserProfile fetches the user profile from Spotify
-func (h *SpotifyAuthHandler) getSpotifyUserProfile(accessToken string) (*SpotifyUserResponse, error) {
-	req, err := http.NewRequest("GET", "https://api.spotify.com/v1/me", nil)
+func (h *SpotifyAuthHandler) getSpotifyUserProfile(ctx context.Context, accessToken string) (*SpotifyUserResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/me", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -39,6 +39,7 @@ func TestSpotifyAuthHandler_LoginSpotify(t *testing.T) {
 	assert.Equal(t, "http://localhost:8080/api/auth/spotify/callback", parsed.Query().Get("redirect_uri"))
 	assert.Contains(t, parsed.Query().Get("scope"), "user-read-currently-playing")
 	assert.Contains(t, parsed.Query().Get("scope"), "user-read-playback-state")
+	assert.Contains(t, parsed.Query().Get("scope"), "user-read-recently-played")
 	assert.NotEmpty(t, parsed.Query().Get("state"))
 }
 
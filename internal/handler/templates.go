@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/auth"
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+// templatePlatformDefault is the template key that applies to every platform
+const templatePlatformDefault = "default"
+
+// TemplateHandler handles user-defined post templates
+type TemplateHandler struct {
+	store   *store.Store
+	posters *PosterRegistry
+}
+
+// NewTemplateHandler creates a new TemplateHandler
+func NewTemplateHandler(s *store.Store, posters *PosterRegistry) *TemplateHandler {
+	return &TemplateHandler{
+		store:   s,
+		posters: posters,
+	}
+}
+
+// TemplatesResponse represents the user's post templates
+type TemplatesResponse struct {
+	Templates       map[string]string       `json:"templates"`
+	Platforms       []string                `json:"platforms"`
+	Limits          map[string]PostLimits   `json:"limits"`
+	DefaultTemplate string                  `json:"default_template"`
+	Variables       []posttemplate.Variable `json:"variables"`
+}
+
+// TemplateRequest is the request body for saving a template
+// An empty template removes the user's template for the platform
+type TemplateRequest struct {
+	Template string `json:"template"`
+}
+
+// TemplatePreviewRequest is the request body for previewing a template
+type TemplatePreviewRequest struct {
+	Template string `json:"template"`
+	Platform string `json:"platform"`
+}
+
+// TemplatePreviewResponse is the rendered preview of a template
+type TemplatePreviewResponse struct {
+	Text string `json:"text"`
+}
+
+// GetTemplates returns the current user's post templates
+// GET /api/settings/templates
+func (h *TemplateHandler) GetTemplates(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	templates, err := h.store.GetPostTemplates(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get templates"})
+	}
+
+	limits := make(map[string]PostLimits)
+	for _, platform := range h.posters.Platforms() {
+		poster, _ := h.posters.Get(platform)
+		limits[platform] = poster.Limits()
+	}
+
+	return c.JSON(http.StatusOK, TemplatesResponse{
+		Templates:       templates,
+		Platforms:       h.templatePlatforms(),
+		Limits:          limits,
+		DefaultTemplate: posttemplate.DefaultTemplate,
+		Variables:       posttemplate.Variables,
+	})
+}
+
+// UpdateTemplate saves the current user's post template for a platform
+// PUT /api/settings/templates/:platform
+func (h *TemplateHandler) UpdateTemplate(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	platform := strings.ToLower(c.Param("platform"))
+	if !h.isTemplatePlatform(platform) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid platform"})
+	}
+
+	var req TemplateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+
+	ctx := c.Request().Context()
+	if strings.TrimSpace(req.Template) == "" {
+		if err := h.store.DeletePostTemplate(ctx, userID, platform); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to delete template"})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "template reset"})
+	}
+
+	if err := posttemplate.Validate(req.Template); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid template: " + err.Error()})
+	}
+
+	if err := h.store.UpsertPostTemplate(ctx, userID, platform, req.Template); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to save template"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "template saved"})
+}
+
+// PreviewTemplate renders a template with sample data.
+// If no template is given, the user's template for the platform is used.
+// POST /api/settings/templates/preview
+func (h *TemplateHandler) PreviewTemplate(c echo.Context) error {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req TemplatePreviewRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+
+	source := req.Template
+	if strings.TrimSpace(source) == "" {
+		ctx := c.Request().Context()
+		templates, err := h.store.GetPostTemplates(ctx, userID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get templates"})
+		}
+		source = selectTemplate(templates, strings.ToLower(req.Platform))
+	}
+
+	text, err := posttemplate.Render(source, posttemplate.SampleData())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid template: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, TemplatePreviewResponse{Text: text})
+}
+
+// templatePlatforms lists the valid template keys
+func (h *TemplateHandler) templatePlatforms() []string {
+	return append([]string{templatePlatformDefault}, h.posters.Platforms()...)
+}
+
+// isTemplatePlatform reports whether platform is a valid template key
+func (h *TemplateHandler) isTemplatePlatform(platform string) bool {
+	for _, p := range h.templatePlatforms() {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// selectTemplate returns the template for a platform, falling back to the
+// user's default template and then the built-in template
+func selectTemplate(templates map[string]string, platform string) string {
+	if t, ok := templates[platform]; ok && t != "" {
+		return t
+	}
+	if t, ok := templates[templatePlatformDefault]; ok && t != "" {
+		return t
+	}
+	return posttemplate.DefaultTemplate
+}
+
+// renderPostText renders the platform's template, falling back to the
+// built-in template if the stored one no longer renders
+func renderPostText(templates map[string]string, platform string, data posttemplate.Data) string {
+	text, err := posttemplate.Render(selectTemplate(templates, platform), data)
+	if err != nil || text == "" {
+		return posttemplate.RenderDefault(data)
+	}
+	return text
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectTemplate(t *testing.T) {
+	templates := map[string]string{
+		"default": "default {{.Track}}",
+		"twitter": "twitter {{.Track}}",
+	}
+
+	assert.Equal(t, "twitter {{.Track}}", selectTemplate(templates, "twitter"))
+	assert.Equal(t, "default {{.Track}}", selectTemplate(templates, "misskey"))
+	assert.Equal(t, posttemplate.DefaultTemplate, selectTemplate(nil, "misskey"))
+}
+
+func TestRenderPostText_FallsBackToDefault(t *testing.T) {
+	data := posttemplate.Data{Type: "track", Track: "Song", Artists: "Artist", URL: "https://open.spotify.com/track/1"}
+	templates := map[string]string{"misskey": "{{.Broken"}
+
+	text := renderPostText(templates, "misskey", data)
+
+	assert.Equal(t, "Song / Artist\n#NowPlaying #PsrPlaying\nhttps://open.spotify.com/track/1", text)
+}
+
+func newTemplateContext(method, path, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", uuid.New())
+	return c, rec
+}
+
+func TestPreviewTemplate(t *testing.T) {
+	h := NewTemplateHandler(nil, NewPosterRegistry(DefaultPosters(nil)...))
+	c, rec := newTemplateContext(http.MethodPost, "/api/settings/templates/preview", `{"template":"{{.Track}} / {{.Artists}}"}`)
+
+	err := h.PreviewTemplate(c)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp TemplatePreviewResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "あとがき / 来栖夏芽", resp.Text)
+}
+
+func TestPreviewTemplate_Invalid(t *testing.T) {
+	h := NewTemplateHandler(nil, NewPosterRegistry(DefaultPosters(nil)...))
+	c, rec := newTemplateContext(http.MethodPost, "/api/settings/templates/preview", `{"template":"{{printf \"%d\" 1}}"}`)
+
+	err := h.PreviewTemplate(c)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid template")
+}
+
+func TestUpdateTemplate_InvalidPlatform(t *testing.T) {
+	h := NewTemplateHandler(nil, NewPosterRegistry(DefaultPosters(nil)...))
+	c, rec := newTemplateContext(http.MethodPut, "/api/settings/templates/unknown", `{"template":"{{.Track}}"}`)
+	c.SetParamNames("platform")
+	c.SetParamValues("unknown")
+
+	err := h.UpdateTemplate(c)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestUpdateTemplate_InvalidTemplate(t *testing.T) {
+	h := NewTemplateHandler(nil, NewPosterRegistry(DefaultPosters(nil)...))
+	c, rec := newTemplateContext(http.MethodPut, "/api/settings/templates/misskey", `{"template":"{{.Unknown}}"}`)
+	c.SetParamNames("platform")
+	c.SetParamValues("misskey")
+
+	err := h.UpdateTemplate(c)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid template")
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
+)
+
+// truncationEllipsis is appended to fields shortened to fit a platform's length limit
+const truncationEllipsis = "…"
+
+// postURLPattern matches the URLs that platforms count as a fixed length
+var postURLPattern = regexp.MustCompile(`https?://\S+`)
+
+// lightweightRanges are the code point ranges counted as one by weighted platforms;
+// everything else counts as two (twitter-text v3 configuration)
+var lightweightRanges = [][2]rune{
+	{0x0000, 0x10FF}, // Latin, Greek, Cyrillic, Arabic, Hebrew, ...
+	{0x2000, 0x200D}, // spaces
+	{0x2010, 0x201F}, // dashes and quotes
+	{0x2032, 0x2037}, // primes
+}
+
+// Length returns the length of the text as counted by the platform
+func (l PostLimits) Length(text string) int {
+	length := 0
+	if l.URLLength > 0 {
+		urls := postURLPattern.FindAllStringIndex(text, -1)
+		length += len(urls) * l.URLLength
+		text = postURLPattern.ReplaceAllString(text, "")
+	}
+	for _, r := range text {
+		length += l.runeLength(r)
+	}
+	return length
+}
+
+// runeLength returns the length a single character counts as
+func (l PostLimits) runeLength(r rune) int {
+	if !l.Weighted {
+		return 1
+	}
+	for _, rng := range lightweightRanges {
+		if r >= rng[0] && r <= rng[1] {
+			return 1
+		}
+	}
+	return 2
+}
+
+// fitPostText renders the text and, if it exceeds the platform's length limit (as counted
+// by limits.Length), shortens the track, artist and show names until it fits. The URL and the fixed text of the
+// template are never cut. It reports whether any field was shortened; the returned text
+// may still be too long if the fixed parts alone exceed the limit.
+func fitPostText(render func(posttemplate.Data) string, data posttemplate.Data, limits PostLimits) (string, bool) {
+	text := render(data)
+	if limits.MaxLength <= 0 {
+		return text, false
+	}
+
+	truncated := false
+	for excess := limits.Length(text) - limits.MaxLength; excess > 0; excess = limits.Length(text) - limits.MaxLength {
+		field := longestTruncatableField(&data)
+		if field == nil {
+			break
+		}
+
+		// Drop characters from the end until the excess and the ellipsis fit,
+		// always shortening the field and keeping at least one character
+		runes := []rune(*field)
+		keep := len(runes)
+		for need := excess + limits.Length(truncationEllipsis); need > 0 && keep > 1; keep-- {
+			need -= limits.runeLength(runes[keep-1])
+		}
+		keep = min(keep, len(runes)-2)
+		*field = string(runes[:keep]) + truncationEllipsis
+
+		// Fields the template does not use leave the text unchanged
+		if shortened := render(data); shortened != text {
+			text, truncated = shortened, true
+		}
+	}
+
+	return text, truncated
+}
+
+// longestTruncatableField returns the longest of the track, artist and show names that
+// can still be shortened, or nil if none can
+func longestTruncatableField(data *posttemplate.Data) *string {
+	var longest *string
+	longestLen := 1 + utf8.RuneCountInString(truncationEllipsis)
+	for _, field := range []*string{&data.Track, &data.Artists, &data.Show} {
+		if n := utf8.RuneCountInString(*field); n > longestLen {
+			longest, longestLen = field, n
+		}
+	}
+	return longest
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFitPostText(t *testing.T) {
+	render := func(data posttemplate.Data) string {
+		return posttemplate.RenderDefault(data)
+	}
+	data := posttemplate.Data{
+		Type:    "track",
+		Track:   strings.Repeat("長", 300),
+		Artists: "来栖夏芽",
+		URL:     "https://open.spotify.com/track/1",
+	}
+
+	tests := []struct {
+		name          string
+		maxLength     int
+		wantTruncated bool
+	}{
+		{name: "制限内", maxLength: 3000, wantTruncated: false},
+		{name: "制限なし", maxLength: 0, wantTruncated: false},
+		{name: "制限超過", maxLength: 280, wantTruncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, truncated := fitPostText(render, data, PostLimits{MaxLength: tt.maxLength})
+
+			assert.Equal(t, tt.wantTruncated, truncated)
+			if tt.maxLength > 0 {
+				assert.LessOrEqual(t, utf8.RuneCountInString(text), tt.maxLength)
+			}
+			// URLとハッシュタグは切り詰めない
+			assert.Contains(t, text, "来栖夏芽")
+			assert.Contains(t, text, "#NowPlaying #PsrPlaying")
+			assert.True(t, strings.HasSuffix(text, data.URL))
+		})
+	}
+}
+
+func TestFitPostText_FixedPartTooLong(t *testing.T) {
+	render := func(data posttemplate.Data) string {
+		return data.URL
+	}
+	data := posttemplate.Data{Track: "あとがき", URL: "https://open.spotify.com/track/1"}
+
+	text, truncated := fitPostText(render, data, PostLimits{MaxLength: 10})
+
+	assert.Equal(t, data.URL, text)
+	assert.False(t, truncated)
+}
+
+func TestPostLimits_Length(t *testing.T) {
+	twitter := (&TwitterPoster{}).Limits()
+	mastodon := (&MastodonPoster{}).Limits()
+	plain := PostLimits{MaxLength: 3000}
+	url := "https://open.spotify.com/track/4iV5W9uYEdYUVa79Axb7Rh?si=0123456789abcdef"
+
+	tests := []struct {
+		name   string
+		limits PostLimits
+		text   string
+		want   int
+	}{
+		{name: "ASCII", limits: twitter, text: "Now Playing", want: 11},
+		{name: "日本語は2文字", limits: twitter, text: "あとがき", want: 8},
+		{name: "絵文字は2文字", limits: twitter, text: "🎵", want: 2},
+		{name: "URLは23文字", limits: twitter, text: "聴いてる " + url, want: 8 + 1 + 23},
+		{name: "MastodonのURL", limits: mastodon, text: "あとがき " + url, want: 5 + 23},
+		{name: "重み付けなし", limits: plain, text: "あとがき " + url, want: 5 + utf8.RuneCountInString(url)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.limits.Length(tt.text))
+		})
+	}
+}
+
+func TestFitPostText_TwitterWeighted(t *testing.T) {
+	render := func(data posttemplate.Data) string {
+		return posttemplate.RenderDefault(data)
+	}
+	data := posttemplate.Data{
+		Type:    "track",
+		Track:   strings.Repeat("長い曲名", 30),
+		Artists: strings.Repeat("アーティスト", 10),
+		URL:     "https://open.spotify.com/track/" + strings.Repeat("x", 100),
+	}
+	limits := (&TwitterPoster{}).Limits()
+
+	text, truncated := fitPostText(render, data, limits)
+
+	assert.True(t, truncated)
+	assert.LessOrEqual(t, limits.Length(text), limits.MaxLength)
+	// 切り詰めすぎない
+	assert.Greater(t, limits.Length(text), limits.MaxLength-4)
+	assert.True(t, strings.HasSuffix(text, "#NowPlaying #PsrPlaying\n"+data.URL))
+}
@@ -0,0 +1,382 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+// twitterAPIBaseURL is the base URL of the Twitter API (overridden in tests)
+var twitterAPIBaseURL = "https://api.twitter.com"
+
+// twitterTokenRefreshMargin is how long before expiry an access token is refreshed
+const twitterTokenRefreshMargin = 5 * time.Minute
+
+// TwitterAPIError represents an error response from the Twitter API
+type TwitterAPIError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay until the rate limit resets, if any
+	RetryAfter time.Duration
+}
+
+func (e *TwitterAPIError) Error() string {
+	return fmt.Sprintf("twitter api error: %d - %s", e.StatusCode, e.Body)
+}
+
+// twitterTokenStore persists refreshed Twitter tokens
+type twitterTokenStore interface {
+	UpdateTwitterToken(ctx context.Context, userID uuid.UUID, accessToken, refreshToken string, expiresAt time.Time, twitterUserID, username, avatarURL string) error
+	MarkTwitterReconnectRequired(ctx context.Context, userID uuid.UUID) error
+}
+
+// TwitterTweetRequest represents the request body for creating a Twitter tweet
+type TwitterTweetRequest struct {
+	Text  string             `json:"text"`
+	Media *TwitterTweetMedia `json:"media,omitempty"`
+	Reply *TwitterTweetReply `json:"reply,omitempty"`
+}
+
+// TwitterTweetReply makes a tweet a reply to another tweet
+type TwitterTweetReply struct {
+	InReplyToTweetID string `json:"in_reply_to_tweet_id"`
+}
+
+// TwitterTweetMedia represents the media attached to a tweet
+type TwitterTweetMedia struct {
+	MediaIDs []string `json:"media_ids"`
+}
+
+// TwitterTweetResponse represents the response from Twitter POST /2/tweets
+type TwitterTweetResponse struct {
+	Data struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// requestTwitterTokenRefresh exchanges a refresh token for a new token pair
+func requestTwitterTokenRefresh(ctx context.Context, refreshToken string) (*TwitterTokenResponse, error) {
+	data := url.Values{}
+	data.Set("refresh_token", refreshToken)
+	data.Set("grant_type", "refresh_token")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", twitterAPIBaseURL+"/2/oauth2/token", strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(os.Getenv("TWITTER_CLIENT_ID"), os.Getenv("TWITTER_CLIENT_SECRET"))
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &TwitterAPIError{StatusCode: resp.StatusCode, Body: string(body), RetryAfter: parseRetryAfter(resp.StatusCode, resp.Header, time.Now())}
+	}
+
+	var tokenResp TwitterTokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, errors.New("twitter returned no access token")
+	}
+
+	return &tokenResp, nil
+}
+
+// twitterRefreshLocker is a twitterTokenStore that can serialize refreshes across replicas
+type twitterRefreshLocker interface {
+	WithRefreshLock(ctx context.Context, platform string, userID uuid.UUID, fn func(ctx context.Context) error) error
+	GetUserByID(ctx context.Context, id uuid.UUID) (*store.User, error)
+}
+
+// twitterTokens is the token pair produced (or reused) by a refresh
+type twitterTokens struct {
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+}
+
+// twitterRefreshTimeout bounds a token refresh, which runs detached from the request that started it
+const twitterRefreshTimeout = 30 * time.Second
+
+// twitterRefreshes coalesces concurrent Twitter token refreshes for the same user
+var twitterRefreshes singleflight.Group
+
+// refreshTwitterToken refreshes the user's access token and persists the rotated tokens.
+// Concurrent refreshes for the same user are coalesced in-process and, when the store
+// supports it, serialized across replicas; tokens already refreshed by another caller are reused.
+// The refresh and save are not canceled with the request that started them, as losing the
+// rotated refresh token would disconnect the user; each caller stops waiting when its own ctx ends.
+// If Twitter rejects the refresh token, the user is flagged as needing to reconnect.
+func refreshTwitterToken(ctx context.Context, s twitterTokenStore, user *store.User) error {
+	staleUser := *user
+	ch := twitterRefreshes.DoChan(user.ID.String(), func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), twitterRefreshTimeout)
+		defer cancel()
+		return refreshTwitterTokenLocked(ctx, s, &staleUser)
+	})
+
+	var res singleflight.Result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	err := res.Err
+	if errors.Is(err, ErrReconnectRequired) {
+		user.TwitterReconnectRequired = true
+	}
+	if err != nil {
+		return err
+	}
+	tokens := res.Val.(twitterTokens)
+
+	user.TwitterAccessToken = sql.NullString{String: tokens.accessToken, Valid: true}
+	user.TwitterRefreshToken = sql.NullString{String: tokens.refreshToken, Valid: true}
+	user.TwitterTokenExpiresAt = sql.NullTime{Time: tokens.expiresAt, Valid: !tokens.expiresAt.IsZero()}
+	user.TwitterReconnectRequired = false
+
+	return nil
+}
+
+// refreshTwitterTokenLocked refreshes the token while holding the store's refresh lock, if any
+func refreshTwitterTokenLocked(ctx context.Context, s twitterTokenStore, user *store.User) (twitterTokens, error) {
+	locker, ok := s.(twitterRefreshLocker)
+	if !ok {
+		return requestAndSaveTwitterToken(ctx, s, user)
+	}
+
+	var tokens twitterTokens
+	err := locker.WithRefreshLock(ctx, "twitter", user.ID, func(ctx context.Context) error {
+		stored, err := locker.GetUserByID(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to reload twitter token: %w", err)
+		}
+		if stored == nil {
+			return errors.New("user not found")
+		}
+		if stored.TwitterReconnectRequired {
+			return ErrReconnectRequired
+		}
+
+		// Reuse tokens another replica refreshed while we were waiting for the lock
+		if stored.TwitterAccessToken.Valid && stored.TwitterAccessToken.String != user.TwitterAccessToken.String && !twitterTokenExpiring(stored, time.Now()) {
+			tokens = twitterTokens{
+				accessToken:  stored.TwitterAccessToken.String,
+				refreshToken: stored.TwitterRefreshToken.String,
+				expiresAt:    stored.TwitterTokenExpiresAt.Time,
+			}
+			return nil
+		}
+
+		tokens, err = requestAndSaveTwitterToken(ctx, s, stored)
+		return err
+	})
+	return tokens, err
+}
+
+// requestAndSaveTwitterToken exchanges the user's refresh token and persists the rotated tokens
+func requestAndSaveTwitterToken(ctx context.Context, s twitterTokenStore, user *store.User) (twitterTokens, error) {
+	if !user.TwitterRefreshToken.Valid || user.TwitterRefreshToken.String == "" {
+		return twitterTokens{}, markTwitterReconnectRequired(ctx, s, user)
+	}
+
+	tokenResp, err := requestTwitterTokenRefresh(ctx, user.TwitterRefreshToken.String)
+	if err != nil {
+		var apiErr *TwitterAPIError
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnauthorized) {
+			return twitterTokens{}, markTwitterReconnectRequired(ctx, s, user)
+		}
+		return twitterTokens{}, fmt.Errorf("failed to refresh twitter token: %w", err)
+	}
+
+	// Twitter rotates refresh tokens, but keep the old one if none was returned
+	tokens := twitterTokens{
+		accessToken:  tokenResp.AccessToken,
+		refreshToken: tokenResp.RefreshToken,
+		expiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}
+	if tokens.refreshToken == "" {
+		tokens.refreshToken = user.TwitterRefreshToken.String
+	}
+
+	if err := s.UpdateTwitterToken(ctx, user.ID, tokens.accessToken, tokens.refreshToken, tokens.expiresAt,
+		user.TwitterUserID.String, user.TwitterUsername.String, user.TwitterAvatarURL.String); err != nil {
+		return twitterTokens{}, err
+	}
+
+	return tokens, nil
+}
+
+// markTwitterReconnectRequired records that the user must reconnect Twitter and returns ErrReconnectRequired
+func markTwitterReconnectRequired(ctx context.Context, s twitterTokenStore, user *store.User) error {
+	// Best effort: the post still fails with ErrReconnectRequired if the flag cannot be saved
+	_ = s.MarkTwitterReconnectRequired(ctx, user.ID)
+	user.TwitterReconnectRequired = true
+	return ErrReconnectRequired
+}
+
+// twitterTokenExpiring reports whether the access token expires within the refresh margin
+func twitterTokenExpiring(user *store.User, now time.Time) bool {
+	return user.TwitterTokenExpiresAt.Valid && now.Add(twitterTokenRefreshMargin).After(user.TwitterTokenExpiresAt.Time)
+}
+
+// withTwitterToken runs fn with the user's access token. The token is refreshed before
+// it expires, and if Twitter still answers 401 it is refreshed and fn is retried once.
+func withTwitterToken(ctx context.Context, s twitterTokenStore, user *store.User, fn func(accessToken string) error) error {
+	if user.TwitterReconnectRequired {
+		return ErrReconnectRequired
+	}
+
+	if twitterTokenExpiring(user, time.Now()) {
+		if err := refreshTwitterToken(ctx, s, user); err != nil {
+			return err
+		}
+	}
+
+	err := fn(user.TwitterAccessToken.String)
+	var apiErr *TwitterAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		return err
+	}
+
+	if err := refreshTwitterToken(ctx, s, user); err != nil {
+		return err
+	}
+	return fn(user.TwitterAccessToken.String)
+}
+
+// TwitterPoster posts tweets with the user's Twitter OAuth 2.0 token
+type TwitterPoster struct {
+	store twitterTokenStore
+}
+
+// Platform returns "twitter"
+func (p *TwitterPoster) Platform() string {
+	return string(PostTargetTwitter)
+}
+
+// Connected reports whether the user has connected Twitter
+func (p *TwitterPoster) Connected(user *store.User) bool {
+	return user.TwitterAccessToken.Valid && user.TwitterAccessToken.String != ""
+}
+
+func (p *TwitterPoster) postsReplies() {}
+
+// Limits returns the Twitter tweet length limit. Twitter counts every URL as 23
+// characters and CJK characters and emoji as two.
+func (p *TwitterPoster) Limits() PostLimits {
+	return PostLimits{MaxLength: 280, URLLength: 23, Weighted: true}
+}
+
+// Post posts a tweet to Twitter and returns the created tweet ID
+func (p *TwitterPoster) Post(ctx context.Context, user *store.User, req PostRequest) (string, error) {
+	var tweetID string
+	err := withTwitterToken(ctx, p.store, user, func(accessToken string) error {
+		reqBody := TwitterTweetRequest{
+			Text: req.Text,
+		}
+		if req.ReplyTo != "" {
+			reqBody.Reply = &TwitterTweetReply{InReplyToTweetID: req.ReplyTo}
+		}
+
+		if req.Artwork != nil {
+			// Post without media if the upload fails (e.g. token lacks media.write)
+			if mediaID, err := uploadToTwitterMedia(ctx, accessToken, req.Artwork); err == nil {
+				reqBody.Media = &TwitterTweetMedia{MediaIDs: []string{mediaID}}
+			}
+		}
+
+		var err error
+		tweetID, err = createTweet(ctx, accessToken, reqBody)
+		return err
+	})
+	return tweetID, err
+}
+
+// createTweet sends POST /2/tweets and returns the created tweet ID
+func createTweet(ctx context.Context, accessToken string, reqBody TwitterTweetRequest) (string, error) {
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", twitterAPIBaseURL+"/2/tweets", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return "", &TwitterAPIError{StatusCode: resp.StatusCode, Body: string(body), RetryAfter: parseRetryAfter(resp.StatusCode, resp.Header, time.Now())}
+	}
+
+	var tweetResp TwitterTweetResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tweetResp); err != nil {
+		// The tweet was created; only the ID is unknown
+		return "", nil
+	}
+
+	return tweetResp.Data.ID, nil
+}
+
+// Delete deletes a tweet from Twitter
+func (p *TwitterPoster) Delete(ctx context.Context, user *store.User, tweetID string) error {
+	return withTwitterToken(ctx, p.store, user, func(accessToken string) error {
+		req, err := http.NewRequestWithContext(ctx, "DELETE", twitterAPIBaseURL+"/2/tweets/"+url.PathEscape(tweetID), nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send request: %w", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return &TwitterAPIError{StatusCode: resp.StatusCode, Body: string(body), RetryAfter: parseRetryAfter(resp.StatusCode, resp.Header, time.Now())}
+		}
+
+		return nil
+	})
+}
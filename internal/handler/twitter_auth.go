@@ -91,7 +91,7 @@ func (h *TwitterAuthHandler) StartTwitterAuth(c echo.Context) error {
 	// Build Twitter OAuth URL
 	clientID := os.Getenv("TWITTER_CLIENT_ID")
 	redirectURI := os.Getenv("BASE_URL") + "/api/twitter/callback"
-	scope := "tweet.read tweet.write users.read offline.access"
+	scope := "tweet.read tweet.write users.read media.write offline.access"
 
 	authURL := fmt.Sprintf(
 		"https://x.com/i/oauth2/authorize?response_type=code&client_id=%s&redirect_uri=%s&scope=%s&state=%s&code_challenge=%s&code_challenge_method=S256",
@@ -194,43 +194,7 @@ func (h *TwitterAuthHandler) CallbackTwitterAuth(c echo.Context) error {
 
 // RefreshTwitterToken refreshes the Twitter access token
 func (h *TwitterAuthHandler) RefreshTwitterToken(ctx echo.Context, userID string, refreshToken string) (*TwitterTokenResponse, error) {
-	clientID := os.Getenv("TWITTER_CLIENT_ID")
-	clientSecret := os.Getenv("TWITTER_CLIENT_SECRET")
-
-	data := url.Values{}
-	data.Set("refresh_token", refreshToken)
-	data.Set("grant_type", "refresh_token")
-
-	req, err := http.NewRequest("POST", "https://api.twitter.com/2/oauth2/token", strings.NewReader(data.Encode()))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.SetBasicAuth(clientID, clientSecret)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("refresh failed: %s", string(body))
-	}
-
-	var tokenResp TwitterTokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, err
-	}
-
-	return &tokenResp, nil
+	return requestTwitterTokenRefresh(ctx.Request().Context(), refreshToken)
 }
 
 // DisconnectTwitter disconnects Twitter from the user account
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTwitterTokenStore はテスト用のトークン保存先
+type fakeTwitterTokenStore struct {
+	accessToken       string
+	refreshToken      string
+	reconnectRequired bool
+}
+
+func (s *fakeTwitterTokenStore) UpdateTwitterToken(ctx context.Context, userID uuid.UUID, accessToken, refreshToken string, expiresAt time.Time, twitterUserID, username, avatarURL string) error {
+	s.accessToken = accessToken
+	s.refreshToken = refreshToken
+	return nil
+}
+
+func (s *fakeTwitterTokenStore) MarkTwitterReconnectRequired(ctx context.Context, userID uuid.UUID) error {
+	s.reconnectRequired = true
+	return nil
+}
+
+// newFakeTwitterAPI はトークン更新とツイート投稿を受け付けるテスト用サーバーを起動する
+func newFakeTwitterAPI(t *testing.T, refreshStatus int, validToken string) (*int, *int) {
+	refreshes, tweets := 0, 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/2/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		assert.Equal(t, "old-refresh", r.FormValue("refresh_token"))
+		if refreshStatus != http.StatusOK {
+			w.WriteHeader(refreshStatus)
+			_, _ = w.Write([]byte(`{"error":"invalid_request"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(TwitterTokenResponse{AccessToken: "new-access", RefreshToken: "new-refresh", ExpiresIn: 7200})
+	})
+	mux.HandleFunc("/2/tweets", func(w http.ResponseWriter, r *http.Request) {
+		tweets++
+		if r.Header.Get("Authorization") != "Bearer "+validToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"data":{"id":"tweet-1"}}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	original := twitterAPIBaseURL
+	twitterAPIBaseURL = server.URL
+	t.Cleanup(func() { twitterAPIBaseURL = original })
+
+	return &refreshes, &tweets
+}
+
+func newTwitterUser(expiresIn time.Duration) *store.User {
+	return &store.User{
+		ID:                    uuid.New(),
+		TwitterAccessToken:    sql.NullString{String: "old-access", Valid: true},
+		TwitterRefreshToken:   sql.NullString{String: "old-refresh", Valid: true},
+		TwitterTokenExpiresAt: sql.NullTime{Time: time.Now().Add(expiresIn), Valid: true},
+	}
+}
+
+func TestTwitterPoster_RefreshesBeforeExpiry(t *testing.T) {
+	refreshes, tweets := newFakeTwitterAPI(t, http.StatusOK, "new-access")
+	s := &fakeTwitterTokenStore{}
+	user := newTwitterUser(time.Minute)
+
+	id, err := (&TwitterPoster{store: s}).Post(context.Background(), user, PostRequest{Text: "hello"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "tweet-1", id)
+	assert.Equal(t, 1, *refreshes)
+	assert.Equal(t, 1, *tweets)
+	assert.Equal(t, "new-access", s.accessToken)
+	assert.Equal(t, "new-refresh", s.refreshToken)
+	assert.Equal(t, "new-refresh", user.TwitterRefreshToken.String)
+}
+
+func TestTwitterPoster_RefreshesOnUnauthorized(t *testing.T) {
+	refreshes, tweets := newFakeTwitterAPI(t, http.StatusOK, "new-access")
+	s := &fakeTwitterTokenStore{}
+	user := newTwitterUser(time.Hour)
+
+	id, err := (&TwitterPoster{store: s}).Post(context.Background(), user, PostRequest{Text: "hello"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "tweet-1", id)
+	assert.Equal(t, 1, *refreshes)
+	assert.Equal(t, 2, *tweets)
+	assert.Equal(t, "new-access", s.accessToken)
+}
+
+func TestTwitterPoster_RefreshRejected(t *testing.T) {
+	refreshes, tweets := newFakeTwitterAPI(t, http.StatusBadRequest, "new-access")
+	s := &fakeTwitterTokenStore{}
+	user := newTwitterUser(time.Minute)
+	poster := &TwitterPoster{store: s}
+
+	_, err := poster.Post(context.Background(), user, PostRequest{Text: "hello"})
+
+	assert.ErrorIs(t, err, ErrReconnectRequired)
+	assert.True(t, s.reconnectRequired)
+	assert.True(t, user.TwitterReconnectRequired)
+	assert.Equal(t, 0, *tweets)
+
+	// 再接続が必要なユーザーはリクエストを送らない
+	_, err = poster.Post(context.Background(), user, PostRequest{Text: "hello"})
+
+	assert.ErrorIs(t, err, ErrReconnectRequired)
+	assert.Equal(t, 1, *refreshes)
+}
+
+func TestTwitterPoster_RefreshServerError(t *testing.T) {
+	newFakeTwitterAPI(t, http.StatusServiceUnavailable, "new-access")
+	s := &fakeTwitterTokenStore{}
+	user := newTwitterUser(time.Minute)
+
+	_, err := (&TwitterPoster{store: s}).Post(context.Background(), user, PostRequest{Text: "hello"})
+
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrReconnectRequired)
+	assert.False(t, s.reconnectRequired)
+}
+
+func TestRefreshTwitterToken_SavesAfterCallerCanceled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_ = json.NewEncoder(w).Encode(TwitterTokenResponse{AccessToken: "new-access", RefreshToken: "new-refresh", ExpiresIn: 7200})
+	}))
+	t.Cleanup(server.Close)
+	original := twitterAPIBaseURL
+	twitterAPIBaseURL = server.URL
+	t.Cleanup(func() { twitterAPIBaseURL = original })
+
+	s := &fakeTwitterTokenStore{}
+	user := newTwitterUser(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- refreshTwitterToken(ctx, s, user) }()
+
+	// リクエストがキャンセルされても、ローテーションされたリフレッシュトークンは保存される
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-errCh, context.Canceled)
+
+	waiter := newTwitterUser(time.Minute)
+	waiter.ID = user.ID
+	go func() { errCh <- refreshTwitterToken(context.Background(), s, waiter) }()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	require.NoError(t, <-errCh)
+	assert.Equal(t, "new-refresh", s.refreshToken)
+	assert.Equal(t, "new-refresh", waiter.TwitterRefreshToken.String)
+}
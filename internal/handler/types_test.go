@@ -10,9 +10,89 @@ import (
 func TestPostTargetConstants(t *testing.T) {
 	assert.Equal(t, PostTarget("misskey"), PostTargetMisskey)
 	assert.Equal(t, PostTarget("twitter"), PostTargetTwitter)
+	assert.Equal(t, PostTarget("mastodon"), PostTargetMastodon)
+	assert.Equal(t, PostTarget("bluesky"), PostTargetBluesky)
 	assert.Equal(t, PostTarget("both"), PostTargetBoth)
 }
 
+func TestParsePostTargetList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  PostTarget
+	}{
+		{"", ""},
+		{"misskey", PostTargetMisskey},
+		{"Mastodon", PostTargetMastodon},
+		{"both", PostTargetBoth},
+		{"twitter,misskey", PostTargetBoth},
+		{"mastodon, misskey", "misskey,mastodon"},
+		{"both,mastodon", "misskey,twitter,mastodon"},
+		{"misskey,misskey", PostTargetMisskey},
+		{"bluesky,mastodon,both", "misskey,twitter,mastodon,bluesky"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParsePostTargetList(tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := ParsePostTargetList("misskey,unknown")
+	assert.Error(t, err)
+}
+
+func TestParsePostTarget_DefaultsToBoth(t *testing.T) {
+	tests := []struct {
+		value string
+		want  PostTarget
+	}{
+		{"", PostTargetBoth},
+		{"unknown", PostTargetBoth},
+		{"misskey,mastodon", "misskey,mastodon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParsePostTarget(tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParsePostTarget_RejectsUnknownListEntry(t *testing.T) {
+	_, err := ParsePostTarget("misskey,unknown")
+	assert.EqualError(t, err, "unknown target: unknown")
+}
+
+func TestPostTarget_Includes(t *testing.T) {
+	assert.True(t, PostTargetBoth.Includes(PostTargetMisskey))
+	assert.True(t, PostTargetBoth.Includes(PostTargetTwitter))
+	assert.False(t, PostTargetBoth.Includes(PostTargetMastodon))
+	assert.True(t, PostTarget("misskey,mastodon").Includes(PostTargetMastodon))
+	assert.False(t, PostTarget("misskey,mastodon").Includes(PostTargetTwitter))
+}
+
+func TestMastodonStatusRequest_JSONMarshal(t *testing.T) {
+	req := MastodonStatusRequest{
+		Status:     "Hello Mastodon",
+		Visibility: "public",
+	}
+
+	data, err := json.Marshal(req)
+	assert.NoError(t, err)
+
+	var unmarshaled map[string]any
+	err = json.Unmarshal(data, &unmarshaled)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Hello Mastodon", unmarshaled["status"])
+	assert.Equal(t, "public", unmarshaled["visibility"])
+	assert.NotContains(t, unmarshaled, "media_ids")
+}
+
 func TestPostResponse_JSONMarshal(t *testing.T) {
 	resp := PostResponse{
 		Success: true,
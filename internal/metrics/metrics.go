@@ -42,6 +42,15 @@ var (
 		[]string{"endpoint"},
 	)
 
+	// source は "spotify"（429を受けた）または "client"（クライアント側のレート制限で待機した）
+	SpotifyAPIThrottledTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "spotify_api_throttled_total",
+			Help: "Total number of throttled Spotify API requests",
+		},
+		[]string{"endpoint", "source"},
+	)
+
 	ShareRedirectsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "share_redirects_total",
@@ -0,0 +1,206 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/handler"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultInterval    = 15 * time.Second
+	defaultMaxAttempts = 6
+	defaultConcurrency = 4
+
+	// batchSize is the number of queued posts claimed per poll
+	batchSize = 20
+	// claimLease is how long a claimed post is hidden from other replicas;
+	// it must be longer than sending a post may take
+	claimLease = 2 * time.Minute
+)
+
+// Config holds retry worker settings
+type Config struct {
+	Interval    time.Duration
+	MaxAttempts int
+	Concurrency int
+}
+
+// LoadConfig loads the worker config from environment variables
+func LoadConfig() Config {
+	config := Config{
+		Interval:    defaultInterval,
+		MaxAttempts: defaultMaxAttempts,
+		Concurrency: defaultConcurrency,
+	}
+
+	// OUTBOX_INTERVAL (Go duration, e.g. "15s")
+	if val := os.Getenv("OUTBOX_INTERVAL"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= time.Second {
+			config.Interval = d
+		}
+	}
+
+	// OUTBOX_MAX_ATTEMPTS (total number of times a post is sent, including the first)
+	if val := os.Getenv("OUTBOX_MAX_ATTEMPTS"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			config.MaxAttempts = n
+		}
+	}
+
+	// OUTBOX_CONCURRENCY (number of queued posts retried in parallel)
+	if val := os.Getenv("OUTBOX_CONCURRENCY"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			config.Concurrency = n
+		}
+	}
+
+	return config
+}
+
+// Store is the subset of store.Store used by the worker
+type Store interface {
+	ClaimOutboxEntries(ctx context.Context, limit int, lease time.Duration) ([]store.OutboxEntry, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (*store.User, error)
+	CompleteOutboxEntry(ctx context.Context, postID uuid.UUID, attempts int, remoteID string) error
+	RescheduleOutboxEntry(ctx context.Context, postID uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error
+	FailOutboxEntry(ctx context.Context, postID uuid.UUID, attempts int, lastError string) error
+}
+
+// Retrier sends a queued post again
+type Retrier interface {
+	RetryQueuedPost(ctx context.Context, user *store.User, entry *store.OutboxEntry) (string, error)
+}
+
+// Worker retries posts that failed transiently, backing off exponentially between attempts
+type Worker struct {
+	config  Config
+	store   Store
+	retrier Retrier
+	logger  *slog.Logger
+	now     func() time.Time
+}
+
+// NewWorker creates a new Worker
+func NewWorker(config Config, s Store, retrier Retrier) *Worker {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultConcurrency
+	}
+	return &Worker{
+		config:  config,
+		store:   s,
+		retrier: retrier,
+		logger:  slog.Default(),
+		now:     time.Now,
+	}
+}
+
+// Run polls the outbox every interval until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	w.logger.Info("outbox worker started", "interval", w.config.Interval.String(), "max_attempts", w.config.MaxAttempts)
+
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.Poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			w.logger.Info("outbox worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll retries the queued posts that are due
+func (w *Worker) Poll(ctx context.Context) {
+	entries, err := w.store.ClaimOutboxEntries(ctx, batchSize, claimLease)
+	if err != nil {
+		w.logger.Error("failed to claim outbox entries", "error", err)
+		return
+	}
+
+	sem := make(chan struct{}, w.config.Concurrency)
+	var wg sync.WaitGroup
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(entry store.OutboxEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			w.retry(ctx, entry)
+		}(entry)
+	}
+	wg.Wait()
+}
+
+// retry sends a queued post once more and completes, reschedules or fails it
+func (w *Worker) retry(ctx context.Context, entry store.OutboxEntry) {
+	attempts := entry.Attempts + 1
+	logger := w.logger.With("post_id", entry.PostID, "user_id", entry.UserID, "platform", entry.Platform, "attempts", attempts)
+
+	user, err := w.store.GetUserByID(ctx, entry.UserID)
+	if err != nil {
+		// Leave the entry claimed; it is retried when the lease expires
+		logger.Error("failed to get outbox user", "error", err)
+		return
+	}
+	if user == nil {
+		w.fail(ctx, logger, entry, attempts, "user not found")
+		return
+	}
+
+	remoteID, postErr := w.retrier.RetryQueuedPost(ctx, user, &entry)
+	if ctx.Err() != nil {
+		// Shutting down: the entry is retried when the lease expires
+		return
+	}
+	if postErr == nil {
+		if err := w.store.CompleteOutboxEntry(ctx, entry.PostID, attempts, remoteID); err != nil {
+			logger.Error("failed to complete outbox entry", "error", err)
+			return
+		}
+		logger.Info("queued post succeeded")
+		return
+	}
+
+	retryable, retryAfter := handler.ClassifyPostError(postErr)
+	if !retryable || attempts >= w.config.MaxAttempts {
+		w.fail(ctx, logger, entry, attempts, postErr.Error())
+		return
+	}
+
+	nextAttemptAt := w.now().Add(handler.RetryDelay(attempts, retryAfter))
+	if err := w.store.RescheduleOutboxEntry(ctx, entry.PostID, attempts, nextAttemptAt, postErr.Error()); err != nil {
+		logger.Error("failed to reschedule outbox entry", "error", err)
+		return
+	}
+	logger.Warn("queued post failed, retrying later", "error", postErr, "next_attempt_at", nextAttemptAt)
+}
+
+// fail marks a queued post as permanently failed
+func (w *Worker) fail(ctx context.Context, logger *slog.Logger, entry store.OutboxEntry, attempts int, lastError string) {
+	if err := w.store.FailOutboxEntry(ctx, entry.PostID, attempts, lastError); err != nil {
+		logger.Error("failed to fail outbox entry", "error", err)
+		return
+	}
+	logger.Warn("queued post failed permanently", "error", lastError)
+}
@@ -0,0 +1,171 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/handler"
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outboxResult はテスト用ストアに記録された再試行の結果
+type outboxResult struct {
+	status        string
+	attempts      int
+	remoteID      string
+	lastError     string
+	nextAttemptAt time.Time
+}
+
+// fakeStore はテスト用のインメモリストア
+type fakeStore struct {
+	mu      sync.Mutex
+	entries []store.OutboxEntry
+	users   map[uuid.UUID]*store.User
+	results map[uuid.UUID]outboxResult
+}
+
+func newFakeStore(entries ...store.OutboxEntry) *fakeStore {
+	s := &fakeStore{entries: entries, users: make(map[uuid.UUID]*store.User), results: make(map[uuid.UUID]outboxResult)}
+	for _, entry := range entries {
+		s.users[entry.UserID] = &store.User{ID: entry.UserID}
+	}
+	return s
+}
+
+func (s *fakeStore) ClaimOutboxEntries(ctx context.Context, limit int, lease time.Duration) ([]store.OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := s.entries
+	s.entries = nil
+	return claimed, nil
+}
+
+func (s *fakeStore) GetUserByID(ctx context.Context, id uuid.UUID) (*store.User, error) {
+	return s.users[id], nil
+}
+
+func (s *fakeStore) CompleteOutboxEntry(ctx context.Context, postID uuid.UUID, attempts int, remoteID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[postID] = outboxResult{status: store.PostStatusSuccess, attempts: attempts, remoteID: remoteID}
+	return nil
+}
+
+func (s *fakeStore) RescheduleOutboxEntry(ctx context.Context, postID uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[postID] = outboxResult{status: store.PostStatusRetrying, attempts: attempts, lastError: lastError, nextAttemptAt: nextAttemptAt}
+	return nil
+}
+
+func (s *fakeStore) FailOutboxEntry(ctx context.Context, postID uuid.UUID, attempts int, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[postID] = outboxResult{status: store.PostStatusFailed, attempts: attempts, lastError: lastError}
+	return nil
+}
+
+// fakeRetrier はテスト用の再送処理（エラーを返さなければ成功）
+type fakeRetrier struct {
+	err error
+}
+
+func (r *fakeRetrier) RetryQueuedPost(ctx context.Context, user *store.User, entry *store.OutboxEntry) (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	return "note-id", nil
+}
+
+func TestWorker_Poll(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		attempts int
+		err      error
+		want     outboxResult
+	}{
+		{
+			name:     "再送に成功",
+			attempts: 1,
+			want:     outboxResult{status: store.PostStatusSuccess, attempts: 2, remoteID: "note-id"},
+		},
+		{
+			name:     "一時的なエラーは再試行を予約する",
+			attempts: 1,
+			err:      &handler.PlatformAPIError{Platform: "misskey", StatusCode: 502, Body: "bad gateway"},
+			want: outboxResult{
+				status:        store.PostStatusRetrying,
+				attempts:      2,
+				lastError:     "misskey api error: 502 - bad gateway",
+				nextAttemptAt: now.Add(time.Minute),
+			},
+		},
+		{
+			name:     "Retry-Afterがバックオフより長い",
+			attempts: 1,
+			err:      &handler.TwitterAPIError{StatusCode: 429, Body: "too many requests", RetryAfter: 15 * time.Minute},
+			want: outboxResult{
+				status:        store.PostStatusRetrying,
+				attempts:      2,
+				lastError:     "twitter api error: 429 - too many requests",
+				nextAttemptAt: now.Add(15 * time.Minute),
+			},
+		},
+		{
+			name:     "恒久的なエラーは即座に失敗にする",
+			attempts: 1,
+			err:      &handler.PlatformAPIError{Platform: "mastodon", StatusCode: 401, Body: "unauthorized"},
+			want:     outboxResult{status: store.PostStatusFailed, attempts: 2, lastError: "mastodon api error: 401 - unauthorized"},
+		},
+		{
+			name:     "最大試行回数に達した",
+			attempts: 2,
+			err:      &handler.PlatformAPIError{Platform: "misskey", StatusCode: 503, Body: "unavailable"},
+			want:     outboxResult{status: store.PostStatusFailed, attempts: 3, lastError: "misskey api error: 503 - unavailable"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := store.OutboxEntry{PostID: uuid.New(), UserID: uuid.New(), Platform: "misskey", Attempts: tt.attempts}
+			s := newFakeStore(entry)
+			w := NewWorker(Config{MaxAttempts: 3}, s, &fakeRetrier{err: tt.err})
+			w.now = func() time.Time { return now }
+
+			w.Poll(context.Background())
+
+			require.Contains(t, s.results, entry.PostID)
+			assert.Equal(t, tt.want, s.results[entry.PostID])
+		})
+	}
+}
+
+func TestWorker_FailsWhenUserDeleted(t *testing.T) {
+	entry := store.OutboxEntry{PostID: uuid.New(), UserID: uuid.New(), Platform: "misskey", Attempts: 1}
+	s := newFakeStore(entry)
+	delete(s.users, entry.UserID)
+	w := NewWorker(Config{}, s, &fakeRetrier{})
+
+	w.Poll(context.Background())
+
+	assert.Equal(t, store.PostStatusFailed, s.results[entry.PostID].status)
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("OUTBOX_INTERVAL", "1m")
+	t.Setenv("OUTBOX_MAX_ATTEMPTS", "10")
+	t.Setenv("OUTBOX_CONCURRENCY", "8")
+
+	config := LoadConfig()
+
+	assert.Equal(t, time.Minute, config.Interval)
+	assert.Equal(t, 10, config.MaxAttempts)
+	assert.Equal(t, 8, config.Concurrency)
+}
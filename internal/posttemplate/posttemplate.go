@@ -0,0 +1,236 @@
+package posttemplate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// DefaultTemplate is the post template used when a user has not configured one.
+// It produces the same text as the original hard-coded format, with #JustPlayed
+// in place of #NowPlaying for the recently played fallback.
+const DefaultTemplate = `{{.Track}} / {{if eq .Type "episode"}}{{.Show}}{{else}}{{.Artists}}{{end}}
+{{if .JustPlayed}}#JustPlayed{{else}}#NowPlaying{{end}}{{if eq .Type "track"}} #PsrPlaying{{end}}
+{{.URL}}`
+
+const (
+	// MaxTemplateLength is the maximum length of a template source in bytes
+	MaxTemplateLength = 1000
+	// MaxOutputLength is the maximum length of a rendered post in bytes
+	MaxOutputLength = 4000
+)
+
+var (
+	// ErrTemplateTooLong is returned when a template source exceeds MaxTemplateLength
+	ErrTemplateTooLong = errors.New("template is too long")
+	// ErrOutputTooLong is returned when a rendered post exceeds MaxOutputLength
+	ErrOutputTooLong = errors.New("rendered text is too long")
+)
+
+// Data is the set of variables available to post templates
+type Data struct {
+	// Type is the content type: "track", "episode" or "chapter" (audiobook)
+	Type string
+	// Track is the track, episode or chapter name
+	Track string
+	// Artists is the comma-separated artist names, or the authors of an audiobook (empty for episodes)
+	Artists string
+	// Album is the album name (empty for episodes)
+	Album string
+	// Show is the podcast show or audiobook name (empty for tracks)
+	Show string
+	// URL is the Spotify URL of the item
+	URL string
+	// Progress is the playback position formatted as m:ss
+	Progress string
+	// Duration is the length of the item formatted as m:ss
+	Duration string
+	// Device is the name of the device playing the item
+	Device string
+	// JustPlayed is true when nothing is playing and the last played track is posted instead
+	JustPlayed bool
+}
+
+// Variables documents the variables available to templates, in display order
+var Variables = []Variable{
+	{Name: "Type", Description: `Content type ("track", "episode" or "chapter")`},
+	{Name: "Track", Description: "Track, episode or chapter name"},
+	{Name: "Artists", Description: "Artist names, comma-separated"},
+	{Name: "Album", Description: "Album name"},
+	{Name: "Show", Description: "Podcast show or audiobook name"},
+	{Name: "URL", Description: "Spotify URL"},
+	{Name: "Progress", Description: "Playback position (m:ss)"},
+	{Name: "Duration", Description: "Track or episode length (m:ss)"},
+	{Name: "Device", Description: "Playback device name"},
+	{Name: "JustPlayed", Description: "true when posting the last played track because nothing is playing"},
+}
+
+// Variable describes a template variable
+type Variable struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// allowedFuncs lists the functions templates may call. Everything else
+// (printf, call, index, ...) is rejected so user templates stay cheap and predictable.
+var allowedFuncs = map[string]bool{
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"and": true, "or": true, "not": true, "len": true,
+	"upper": true, "lower": true, "trim": true,
+}
+
+var funcs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+}
+
+// SampleData returns example data used to validate and preview templates
+func SampleData() Data {
+	return Data{
+		Type:     "track",
+		Track:    "あとがき",
+		Artists:  "来栖夏芽",
+		Album:    "あとがき",
+		URL:      "https://open.spotify.com/track/5WehEFiES0ebVqgXpYQ8Fi",
+		Progress: "1:23",
+		Duration: "4:56",
+		Device:   "iPhone",
+	}
+}
+
+// Parse parses and validates a template source
+func Parse(text string) (*template.Template, error) {
+	if len(text) > MaxTemplateLength {
+		return nil, ErrTemplateTooLong
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("template is empty")
+	}
+
+	tmpl, err := template.New("post").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, errors.New("template definitions are not allowed")
+	}
+	if err := checkNode(tmpl.Tree.Root); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Validate checks that a template parses and renders with sample data
+func Validate(text string) error {
+	tmpl, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	_, err = execute(tmpl, SampleData())
+	return err
+}
+
+// Render renders a template source with the given data
+func Render(text string, data Data) (string, error) {
+	tmpl, err := Parse(text)
+	if err != nil {
+		return "", err
+	}
+	return execute(tmpl, data)
+}
+
+// RenderDefault renders DefaultTemplate, which is known to be valid
+func RenderDefault(data Data) string {
+	text, err := Render(DefaultTemplate, data)
+	if err != nil {
+		// Unreachable unless DefaultTemplate is broken
+		return fmt.Sprintf("%s\n#NowPlaying\n%s", data.Track, data.URL)
+	}
+	return text
+}
+
+func execute(tmpl *template.Template, data Data) (string, error) {
+	w := &limitedBuffer{limit: MaxOutputLength}
+	if err := tmpl.Execute(w, data); err != nil {
+		if errors.Is(err, ErrOutputTooLong) {
+			return "", ErrOutputTooLong
+		}
+		return "", err
+	}
+	return strings.TrimSpace(w.String()), nil
+}
+
+// checkNode rejects template constructs outside the documented safe subset
+func checkNode(node parse.Node) error {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkNode(child); err != nil {
+				return err
+			}
+		}
+	case *parse.TextNode, *parse.CommentNode, *parse.FieldNode, *parse.VariableNode,
+		*parse.DotNode, *parse.StringNode, *parse.NumberNode, *parse.BoolNode, *parse.NilNode:
+		return nil
+	case *parse.IdentifierNode:
+		if !allowedFuncs[n.Ident] {
+			return fmt.Errorf("function %q is not allowed", n.Ident)
+		}
+	case *parse.ActionNode:
+		return checkNode(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkNode(cmd); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkNode(arg); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkBranch(&n.BranchNode)
+	default:
+		return fmt.Errorf("template construct %q is not allowed", node.String())
+	}
+	return nil
+}
+
+func checkBranch(n *parse.BranchNode) error {
+	if err := checkNode(n.Pipe); err != nil {
+		return err
+	}
+	if err := checkNode(n.List); err != nil {
+		return err
+	}
+	return checkNode(n.ElseList)
+}
+
+// limitedBuffer is a bytes.Buffer that fails once limit bytes are exceeded
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, ErrOutputTooLong
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,103 @@
+package posttemplate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderDefault_Track(t *testing.T) {
+	text := RenderDefault(Data{
+		Type:    "track",
+		Track:   "Test Song",
+		Artists: "Artist1, Artist2",
+		URL:     "https://open.spotify.com/track/123",
+	})
+
+	assert.Equal(t, "Test Song / Artist1, Artist2\n#NowPlaying #PsrPlaying\nhttps://open.spotify.com/track/123", text)
+}
+
+func TestRenderDefault_Episode(t *testing.T) {
+	text := RenderDefault(Data{
+		Type:  "episode",
+		Track: "Test Episode",
+		Show:  "Test Podcast",
+		URL:   "https://open.spotify.com/episode/789",
+	})
+
+	assert.Equal(t, "Test Episode / Test Podcast\n#NowPlaying\nhttps://open.spotify.com/episode/789", text)
+}
+
+func TestRenderDefault_JustPlayed(t *testing.T) {
+	text := RenderDefault(Data{
+		Type:       "track",
+		Track:      "Test Song",
+		Artists:    "Test Artist",
+		URL:        "https://open.spotify.com/track/123",
+		JustPlayed: true,
+	})
+
+	assert.Equal(t, "Test Song / Test Artist\n#JustPlayed #PsrPlaying\nhttps://open.spotify.com/track/123", text)
+}
+
+func TestRenderDefault_WithoutURL(t *testing.T) {
+	text := RenderDefault(Data{Type: "track", Track: "Test Song", Artists: "Test Artist"})
+
+	assert.Equal(t, "Test Song / Test Artist\n#NowPlaying #PsrPlaying", text)
+}
+
+func TestRender_CustomTemplate(t *testing.T) {
+	text, err := Render(`🎵 {{.Track}} - {{upper .Artists}} ({{.Album}}) on {{.Device}} @ {{.Progress}}`, SampleData())
+
+	require.NoError(t, err)
+	assert.Equal(t, "🎵 あとがき - 来栖夏芽 (あとがき) on iPhone @ 1:23", text)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{"default", DefaultTemplate, false},
+		{"fields", "{{.Track}} / {{.Artists}} {{.URL}}", false},
+		{"conditional", `{{if .Album}}{{.Album}}{{else}}-{{end}}`, false},
+		{"comparison", `{{if and (eq .Type "track") (ne .Device "")}}{{.Device}}{{end}}`, false},
+		{"empty", "   ", true},
+		{"syntax error", "{{.Track", true},
+		{"unknown field", "{{.Password}}", true},
+		{"printf", `{{printf "%999999d" 1}}`, true},
+		{"range", "{{range 1000000000}}x{{end}}", true},
+		{"define", `{{define "x"}}x{{end}}{{.Track}}`, true},
+		{"template call", `{{template "post" .}}`, true},
+		{"too long", strings.Repeat("a", MaxTemplateLength+1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.template)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRender_OutputTooLong(t *testing.T) {
+	data := SampleData()
+	data.Track = strings.Repeat("a", MaxOutputLength)
+
+	_, err := Render("{{.Track}}{{.Track}}", data)
+
+	assert.ErrorIs(t, err, ErrOutputTooLong)
+}
+
+func TestVariables_MatchData(t *testing.T) {
+	for _, v := range Variables {
+		assert.NoError(t, Validate("{{."+v.Name+"}}"), v.Name)
+	}
+}
@@ -1,42 +1,122 @@
 package spotify
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // Client はSpotify APIクライアントのインターフェース
+// 各メソッドはctxがキャンセルされるとリクエストを中断する
 type Client interface {
 	// GetPlayerData は現在再生中の情報を取得する
-	GetPlayerData(accessToken string) (*PlayerResponse, time.Duration, error)
+	GetPlayerData(ctx context.Context, accessToken string) (*PlayerResponse, time.Duration, error)
 	// ExchangeToken は認証コードをアクセストークンに交換する
-	ExchangeToken(code, redirectURI string) (*Tokens, error)
+	ExchangeToken(ctx context.Context, code, redirectURI string) (*Tokens, error)
 	// RefreshToken はリフレッシュトークンを使用して新しいアクセストークンを取得する
-	RefreshToken(refreshToken string) (*Tokens, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*Tokens, error)
+	// GetRecentlyPlayed は最近再生したトラックを新しい順に最大limit件取得する
+	GetRecentlyPlayed(ctx context.Context, accessToken string, limit int) ([]PlayHistory, error)
+	// GetCurrentUserID はアクセストークンのSpotifyユーザーIDを取得する
+	GetCurrentUserID(ctx context.Context, accessToken string) (string, error)
 }
 
 // PlayerResponse はSpotify Player APIのレスポンス
 type PlayerResponse struct {
+	IsPlaying  bool `json:"is_playing"`
+	ProgressMs int  `json:"progress_ms"`
+	// Timestamp はレスポンスの再生状態が取得された時刻（Unixミリ秒）
+	Timestamp            int64  `json:"timestamp"`
 	CurrentlyPlayingType string `json:"currently_playing_type"`
 	Item                 Item   `json:"item"`
+	Device               Device `json:"device"`
+	// Context は再生元のアルバム・プレイリストなど（再生元がない場合はnil）
+	Context      *PlaybackContext `json:"context"`
+	ShuffleState bool             `json:"shuffle_state"`
+	// RepeatState は "off", "track", "context" のいずれか
+	RepeatState string `json:"repeat_state"`
+}
+
+// Device は再生中のデバイス情報
+type Device struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	Type             string `json:"type"`
+	IsActive         bool   `json:"is_active"`
+	IsPrivateSession bool   `json:"is_private_session"`
+	IsRestricted     bool   `json:"is_restricted"`
+	// VolumePercent はデバイスが音量を報告しない場合nil
+	VolumePercent *int `json:"volume_percent"`
+}
+
+// PlaybackContext は再生元（アルバム・プレイリスト・アーティスト・番組）の情報
+type PlaybackContext struct {
+	// Type は "album", "playlist", "artist", "show" のいずれか
+	Type         string       `json:"type"`
+	URI          string       `json:"uri"`
+	Href         string       `json:"href"`
+	ExternalUrls ExternalUrls `json:"external_urls"`
+}
+
+// PlayHistory はRecently Played APIの再生履歴の1件
+type PlayHistory struct {
+	// Track は再生したトラック（エピソードは履歴に含まれない）
+	Track Item `json:"track"`
+	// PlayedAt は再生された時刻
+	PlayedAt time.Time `json:"played_at"`
+	// Context は再生元（再生元がない場合はnil）
+	Context *PlaybackContext `json:"context"`
+}
+
+// PlayerResponse は再生履歴を一時停止中のPlayerResponseとして返す
+// 投稿処理を現在再生中のアイテムと共通にするために使う
+func (h PlayHistory) PlayerResponse() *PlayerResponse {
+	return &PlayerResponse{
+		IsPlaying:            false,
+		ProgressMs:           h.Track.DurationMs,
+		Timestamp:            h.PlayedAt.UnixMilli(),
+		CurrentlyPlayingType: "track",
+		Item:                 h.Track,
+		Context:              h.Context,
+	}
+}
+
+// recentlyPlayedResponse はRecently Played APIのレスポンス
+type recentlyPlayedResponse struct {
+	Items []PlayHistory `json:"items"`
+}
+
+// currentUserResponse はGet Current User's ProfileのレスポンスのうちユーザーID
+type currentUserResponse struct {
+	ID string `json:"id"`
 }
 
 // HTTPClient はHTTP通信を行うクライアント
 type HTTPClient struct {
-	client       *http.Client
-	tokenURL     string
-	playerURL    string
-	clientID     string
-	clientSecret string
-	logger       *slog.Logger
+	client    *http.Client
+	tokenURL  string
+	playerURL string
+	// recentlyPlayedURL はRecently Played APIのURL（limitはクエリに追加する）
+	recentlyPlayedURL string
+	meURL             string
+	clientID          string
+	clientSecret      string
+	logger            *slog.Logger
+	// limiter は全ユーザーで共有するレート制限
+	limiter        *rateLimiter
+	maxRetries     int
+	retryBaseDelay time.Duration
+	maxRetryWait   time.Duration
 }
 
 // ClientOption はHTTPClientの設定オプション
@@ -63,6 +143,20 @@ func WithPlayerURL(url string) ClientOption {
 	}
 }
 
+// WithRecentlyPlayedURL は再生履歴のURLを設定する（テスト用）
+func WithRecentlyPlayedURL(url string) ClientOption {
+	return func(c *HTTPClient) {
+		c.recentlyPlayedURL = url
+	}
+}
+
+// WithMeURL はユーザープロフィールのURLを設定する（テスト用）
+func WithMeURL(url string) ClientOption {
+	return func(c *HTTPClient) {
+		c.meURL = url
+	}
+}
+
 // WithLogger はロガーを設定する
 func WithLogger(logger *slog.Logger) ClientOption {
 	return func(c *HTTPClient) {
@@ -70,15 +164,39 @@ func WithLogger(logger *slog.Logger) ClientOption {
 	}
 }
 
+// WithRateLimit は全ユーザー合計で1秒あたりに送るリクエスト数とバーストを設定する
+// limitが0以下の場合はリクエストを間引かない
+func WithRateLimit(limit float64, burst int) ClientOption {
+	return func(c *HTTPClient) {
+		c.limiter = newRateLimiter(limit, burst)
+	}
+}
+
+// WithRetry は429と5xxを再試行する回数、Retry-Afterがない場合の初回の待ち時間、
+// 再試行する最大の待ち時間を設定する
+func WithRetry(maxRetries int, baseDelay, maxWait time.Duration) ClientOption {
+	return func(c *HTTPClient) {
+		c.maxRetries = maxRetries
+		c.retryBaseDelay = baseDelay
+		c.maxRetryWait = maxWait
+	}
+}
+
 // NewHTTPClient は新しいHTTPClientを作成する
 func NewHTTPClient(opts ...ClientOption) *HTTPClient {
 	c := &HTTPClient{
-		client:       &http.Client{Timeout: 10 * time.Second},
-		tokenURL:     "https://accounts.spotify.com/api/token",
-		playerURL:    "https://api.spotify.com/v1/me/player?market=JP",
-		clientID:     os.Getenv("SPOTIFY_CLIENT_ID"),
-		clientSecret: os.Getenv("SPOTIFY_CLIENT_SECRET"),
-		logger:       slog.Default(),
+		client:            &http.Client{Timeout: 10 * time.Second},
+		tokenURL:          "https://accounts.spotify.com/api/token",
+		playerURL:         "https://api.spotify.com/v1/me/player?market=JP",
+		recentlyPlayedURL: "https://api.spotify.com/v1/me/player/recently-played",
+		meURL:             "https://api.spotify.com/v1/me",
+		clientID:          os.Getenv("SPOTIFY_CLIENT_ID"),
+		clientSecret:      os.Getenv("SPOTIFY_CLIENT_SECRET"),
+		logger:            slog.Default(),
+		limiter:           newRateLimiter(rateLimitFromEnv()),
+		maxRetries:        defaultMaxRetries,
+		retryBaseDelay:    defaultRetryBaseDelay,
+		maxRetryWait:      defaultMaxRetryWait,
 	}
 
 	for _, opt := range opts {
@@ -89,18 +207,20 @@ func NewHTTPClient(opts ...ClientOption) *HTTPClient {
 }
 
 // GetPlayerData は現在再生中の情報を取得する
-func (c *HTTPClient) GetPlayerData(accessToken string) (*PlayerResponse, time.Duration, error) {
+// 何も再生していない場合（204）はエラーではなく空のPlayerResponse（StateIdle）を返す
+func (c *HTTPClient) GetPlayerData(ctx context.Context, accessToken string) (*PlayerResponse, time.Duration, error) {
 	start := time.Now()
 
-	req, err := http.NewRequest("GET", c.playerURL, nil)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Accept-Language", "ja")
+	resp, err := c.do(ctx, "player", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.playerURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
 
-	resp, err := c.client.Do(req)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Accept-Language", "ja")
+		return req, nil
+	})
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -108,8 +228,11 @@ func (c *HTTPClient) GetPlayerData(accessToken string) (*PlayerResponse, time.Du
 
 	duration := time.Since(start)
 
+	if resp.StatusCode == http.StatusNoContent {
+		return &PlayerResponse{}, duration, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return &PlayerResponse{}, duration, &APIError{StatusCode: resp.StatusCode}
+		return &PlayerResponse{}, duration, &APIError{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp.Header)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -126,23 +249,87 @@ func (c *HTTPClient) GetPlayerData(accessToken string) (*PlayerResponse, time.Du
 	return &playerResp, duration, nil
 }
 
+// GetRecentlyPlayed は最近再生したトラックを新しい順に最大limit件取得する
+// user-read-recently-playedスコープが必要
+func (c *HTTPClient) GetRecentlyPlayed(ctx context.Context, accessToken string, limit int) ([]PlayHistory, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+
+	resp, err := c.do(ctx, "recently_played", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.recentlyPlayedURL+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Accept-Language", "ja")
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(body), RetryAfter: retryAfter(resp.Header)}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var recentResp recentlyPlayedResponse
+	if err := json.Unmarshal(body, &recentResp); err != nil {
+		c.logger.Error("failed to unmarshal recently played response", "error", err)
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return recentResp.Items, nil
+}
+
+// GetCurrentUserID はアクセストークンのSpotifyユーザーIDを取得する
+// IDの取得には追加のスコープは不要
+func (c *HTTPClient) GetCurrentUserID(ctx context.Context, accessToken string) (string, error) {
+	resp, err := c.do(ctx, "me", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.meURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", &APIError{StatusCode: resp.StatusCode, Message: string(body), RetryAfter: retryAfter(resp.Header)}
+	}
+
+	var me currentUserResponse
+	if err := json.NewDecoder(resp.Body).Decode(&me); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if me.ID == "" {
+		return "", errors.New("spotify returned no user id")
+	}
+
+	return me.ID, nil
+}
+
 // ExchangeToken は認証コードをアクセストークンに交換する
-func (c *HTTPClient) ExchangeToken(code, redirectURI string) (*Tokens, error) {
+func (c *HTTPClient) ExchangeToken(ctx context.Context, code, redirectURI string) (*Tokens, error) {
 	form := url.Values{}
 	form.Set("grant_type", "authorization_code")
 	form.Set("code", code)
 	form.Set("redirect_uri", redirectURI)
 
-	req, err := http.NewRequest("POST", c.tokenURL, strings.NewReader(form.Encode()))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	auth := base64.StdEncoding.EncodeToString([]byte(c.clientID + ":" + c.clientSecret))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Authorization", "Basic "+auth)
-
-	resp, err := c.client.Do(req)
+	resp, err := c.postTokenForm(ctx, form)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -151,7 +338,7 @@ func (c *HTTPClient) ExchangeToken(code, redirectURI string) (*Tokens, error) {
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		c.logger.Error("token exchange failed", "status", resp.StatusCode, "body", string(body))
-		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(body)}
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(body), RetryAfter: retryAfter(resp.Header)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -168,21 +355,12 @@ func (c *HTTPClient) ExchangeToken(code, redirectURI string) (*Tokens, error) {
 }
 
 // RefreshToken はリフレッシュトークンを使用して新しいアクセストークンを取得する
-func (c *HTTPClient) RefreshToken(refreshToken string) (*Tokens, error) {
+func (c *HTTPClient) RefreshToken(ctx context.Context, refreshToken string) (*Tokens, error) {
 	form := url.Values{}
 	form.Set("grant_type", "refresh_token")
 	form.Set("refresh_token", refreshToken)
 
-	req, err := http.NewRequest("POST", c.tokenURL, strings.NewReader(form.Encode()))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	auth := base64.StdEncoding.EncodeToString([]byte(c.clientID + ":" + c.clientSecret))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Authorization", "Basic "+auth)
-
-	resp, err := c.client.Do(req)
+	resp, err := c.postTokenForm(ctx, form)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -191,7 +369,7 @@ func (c *HTTPClient) RefreshToken(refreshToken string) (*Tokens, error) {
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		c.logger.Error("token refresh failed", "status", resp.StatusCode, "body", string(body))
-		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(body)}
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(body), RetryAfter: retryAfter(resp.Header)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -213,10 +391,27 @@ func (c *HTTPClient) RefreshToken(refreshToken string) (*Tokens, error) {
 	return &tokens, nil
 }
 
+// postTokenForm はクライアント認証付きでトークンエンドポイントにフォームを送る
+func (c *HTTPClient) postTokenForm(ctx context.Context, form url.Values) (*http.Response, error) {
+	return c.do(ctx, "token", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		auth := base64.StdEncoding.EncodeToString([]byte(c.clientID + ":" + c.clientSecret))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Basic "+auth)
+		return req, nil
+	})
+}
+
 // APIError はSpotify APIエラーを表す
 type APIError struct {
 	StatusCode int
 	Message    string
+	// RetryAfter は429でSpotifyが指定した待ち時間（指定がなければ0）
+	RetryAfter time.Duration
 }
 
 func (e *APIError) Error() string {
@@ -228,7 +423,9 @@ func (e *APIError) Error() string {
 
 // IsAPIError はエラーがAPIErrorかどうかを判定する
 func IsAPIError(err error) (*APIError, bool) {
-	if apiErr, ok := err.(*APIError); ok {
+	// レート制限の停止中に返す429はリクエスト失敗としてラップされている
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
 		return apiErr, true
 	}
 	return nil, false
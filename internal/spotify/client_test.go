@@ -1,10 +1,14 @@
 package spotify
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -36,7 +40,7 @@ func TestHTTPClient_GetPlayerData_Success(t *testing.T) {
 		WithPlayerURL(server.URL),
 	)
 
-	resp, duration, err := client.GetPlayerData("test-token")
+	resp, duration, err := client.GetPlayerData(context.Background(), "test-token")
 
 	require.NoError(t, err)
 	assert.NotNil(t, resp)
@@ -55,7 +59,7 @@ func TestHTTPClient_GetPlayerData_APIError(t *testing.T) {
 		WithPlayerURL(server.URL),
 	)
 
-	resp, duration, err := client.GetPlayerData("invalid-token")
+	resp, duration, err := client.GetPlayerData(context.Background(), "invalid-token")
 
 	require.Error(t, err)
 	assert.NotNil(t, resp)
@@ -66,6 +70,46 @@ func TestHTTPClient_GetPlayerData_APIError(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
 }
 
+func TestHTTPClient_GetPlayerData_NoContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(
+		WithPlayerURL(server.URL),
+	)
+
+	// 何も再生していない場合はエラーではなく空のレスポンスを返す
+	resp, _, err := client.GetPlayerData(context.Background(), "test-token")
+
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, StateIdle, resp.State())
+}
+
+func TestHTTPClient_GetPlayerData_Canceled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewHTTPClient(
+		WithPlayerURL(server.URL),
+	)
+
+	// 応答を待たずにキャンセルされたリクエストを中断する
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, _, err := client.GetPlayerData(ctx, "test-token")
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
 func TestHTTPClient_GetPlayerData_InvalidJSON(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte("invalid json"))
@@ -76,13 +120,106 @@ func TestHTTPClient_GetPlayerData_InvalidJSON(t *testing.T) {
 		WithPlayerURL(server.URL),
 	)
 
-	resp, _, err := client.GetPlayerData("test-token")
+	resp, _, err := client.GetPlayerData(context.Background(), "test-token")
 
 	require.Error(t, err)
 	assert.Nil(t, resp)
 	assert.Contains(t, err.Error(), "failed to unmarshal")
 }
 
+func TestHTTPClient_GetRecentlyPlayed_Success(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "recently_played.json"))
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "1", r.URL.Query().Get("limit"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(
+		WithRecentlyPlayedURL(server.URL),
+	)
+
+	history, err := client.GetRecentlyPlayed(context.Background(), "test-token", 1)
+
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "あとがき", history[0].Track.Name)
+	assert.Equal(t, time.Date(2026, 1, 1, 12, 0, 0, 123000000, time.UTC), history[0].PlayedAt)
+	require.NotNil(t, history[0].Context)
+	assert.Equal(t, "playlist", history[0].Context.Type)
+
+	// 再生履歴は一時停止中のトラックとして投稿処理に渡す
+	playerResp := history[0].PlayerResponse()
+	assert.Equal(t, StatePaused, playerResp.State())
+	assert.Equal(t, StateTrack, playerResp.ContentState())
+	trackData, contentType := ParsePlayerResponse(playerResp)
+	assert.Equal(t, "track", contentType)
+	assert.Equal(t, "https://open.spotify.com/track/5WehEFiES0ebVqgXpYQ8Fi", trackData.TrackURL)
+}
+
+func TestHTTPClient_GetRecentlyPlayed_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("Insufficient client scope"))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(
+		WithRecentlyPlayedURL(server.URL),
+	)
+
+	history, err := client.GetRecentlyPlayed(context.Background(), "test-token", 1)
+
+	require.Error(t, err)
+	assert.Nil(t, history)
+	apiErr, ok := IsAPIError(err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	assert.Equal(t, "Insufficient client scope", apiErr.Message)
+}
+
+func TestHTTPClient_GetCurrentUserID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"spotify-user","display_name":"Alice"}`))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(
+		WithMeURL(server.URL),
+	)
+
+	id, err := client.GetCurrentUserID(context.Background(), "test-token")
+
+	require.NoError(t, err)
+	assert.Equal(t, "spotify-user", id)
+}
+
+func TestHTTPClient_GetCurrentUserID_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(
+		WithMeURL(server.URL),
+	)
+
+	_, err := client.GetCurrentUserID(context.Background(), "test-token")
+
+	apiErr, ok := IsAPIError(err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
 func TestHTTPClient_ExchangeToken_Success(t *testing.T) {
 	t.Setenv("SPOTIFY_CLIENT_ID", "test-client-id")
 	t.Setenv("SPOTIFY_CLIENT_SECRET", "test-client-secret")
@@ -111,7 +248,7 @@ func TestHTTPClient_ExchangeToken_Success(t *testing.T) {
 		WithTokenURL(server.URL),
 	)
 
-	tokens, err := client.ExchangeToken("test-code", "http://localhost/callback")
+	tokens, err := client.ExchangeToken(context.Background(), "test-code", "http://localhost/callback")
 
 	require.NoError(t, err)
 	assert.NotNil(t, tokens)
@@ -133,7 +270,7 @@ func TestHTTPClient_ExchangeToken_APIError(t *testing.T) {
 		WithTokenURL(server.URL),
 	)
 
-	tokens, err := client.ExchangeToken("invalid-code", "http://localhost/callback")
+	tokens, err := client.ExchangeToken(context.Background(), "invalid-code", "http://localhost/callback")
 
 	require.Error(t, err)
 	assert.Nil(t, tokens)
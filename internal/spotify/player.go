@@ -5,11 +5,16 @@ import (
 	"net/url"
 	"os"
 	"strings"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
 )
 
 // Artist はSpotifyのアーティスト情報
 type Artist struct {
-	Name string `json:"name"`
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	URI          string       `json:"uri"`
+	ExternalUrls ExternalUrls `json:"external_urls"`
 }
 
 // ExternalUrls は外部URL情報
@@ -17,25 +22,142 @@ type ExternalUrls struct {
 	Spotify string `json:"spotify"`
 }
 
-// Item はSpotifyの再生アイテム情報
+// Image はSpotifyの画像情報（大きい順に並んでいる）
+type Image struct {
+	URL    string `json:"url"`
+	Height int    `json:"height"`
+	Width  int    `json:"width"`
+}
+
+// Album はSpotifyのアルバム情報
+type Album struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URI  string `json:"uri"`
+	// AlbumType は "album", "single", "compilation" のいずれか
+	AlbumType    string       `json:"album_type"`
+	ReleaseDate  string       `json:"release_date"`
+	Artists      []Artist     `json:"artists"`
+	ExternalUrls ExternalUrls `json:"external_urls"`
+	Images       []Image      `json:"images"`
+}
+
+// Show はSpotifyのポッドキャスト番組情報
+type Show struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	URI          string       `json:"uri"`
+	Publisher    string       `json:"publisher"`
+	ExternalUrls ExternalUrls `json:"external_urls"`
+	Images       []Image      `json:"images"`
+}
+
+// Audiobook はSpotifyのオーディオブック情報
+type Audiobook struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	URI       string   `json:"uri"`
+	Authors   []Author `json:"authors"`
+	Narrators []Author `json:"narrators"`
+	Publisher string   `json:"publisher"`
+	Images    []Image  `json:"images"`
+}
+
+// Author はオーディオブックの著者・ナレーター
+type Author struct {
+	Name string `json:"name"`
+}
+
+// Item はSpotifyの再生アイテム情報（トラック、エピソード、オーディオブックのチャプター）
 type Item struct {
-	Artists []Artist `json:"artists"`
-	Name    string   `json:"name"`
-	Show    struct {
-		Name string `json:"name"`
-	} `json:"show"`
+	ID string `json:"id"`
+	// Type は "track", "episode", "chapter" のいずれか
+	Type       string    `json:"type"`
+	Artists    []Artist  `json:"artists"`
+	Name       string    `json:"name"`
+	Album      Album     `json:"album"`
+	Show       Show      `json:"show"`
+	Audiobook  Audiobook `json:"audiobook"`
+	DurationMs int       `json:"duration_ms"`
+	Explicit   bool      `json:"explicit"`
+	// IsLocal はローカルファイルの場合true（IDやURLを持たない）
+	IsLocal      bool         `json:"is_local"`
 	ExternalUrls ExternalUrls `json:"external_urls"`
+	URI          string       `json:"uri"`
+	// Images はエピソード・チャプターのカバー画像（トラックの場合はAlbum.Imagesを使用）
+	Images []Image `json:"images"`
 }
 
 // TrackData はシェア用のトラック情報
 type TrackData struct {
+	TrackID    string
+	TrackURI   string
 	TrackName  string
 	TrackURL   string
 	ArtistName string
-	TrackEnc   string
+	AlbumName  string
+	ShowName   string
+	IsPlaying  bool
+	ProgressMs int
+	DurationMs int
+	Explicit   bool
+	DeviceName string
+	DeviceType string
+	// ContextType / ContextURI は再生元（再生元がない場合は空）
+	ContextType string
+	ContextURI  string
+	Shuffle     bool
+	ImageURL    string
+	TrackEnc    string
+}
+
+// TemplateData は投稿テンプレート用の変数を生成する
+func (t TrackData) TemplateData(contentType string) posttemplate.Data {
+	data := posttemplate.Data{
+		Type:     contentType,
+		Track:    t.TrackName,
+		Album:    t.AlbumName,
+		Show:     t.ShowName,
+		URL:      t.TrackURL,
+		Progress: formatProgress(t.ProgressMs),
+		Duration: formatProgress(t.DurationMs),
+		Device:   t.DeviceName,
+	}
+	if contentType != "episode" {
+		data.Artists = t.ArtistName
+	}
+	return data
+}
+
+// largestImageURL は最も大きい画像のURLを返す
+func largestImageURL(images []Image) string {
+	var best Image
+	for _, img := range images {
+		if best.URL == "" || img.Width*img.Height > best.Width*best.Height {
+			best = img
+		}
+	}
+	return best.URL
+}
+
+// formatProgress は再生位置や長さをm:ss形式に変換する
+func formatProgress(ms int) string {
+	if ms < 0 {
+		ms = 0
+	}
+	seconds := ms / 1000
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// shareText はシェアURL用のテキスト（URLはパラメータで別途渡すため含めない）をrenderで生成する
+func shareText(t TrackData, contentType string, render func(posttemplate.Data) string) string {
+	data := t.TemplateData(contentType)
+	data.URL = ""
+	return render(data)
 }
 
 // ParsePlayerResponse はPlayerResponseからシェア情報を生成する
+// コンテンツタイプは "track", "episode", "chapter"、共有できない場合（何も再生していない・広告）は "unknown"
 func ParsePlayerResponse(data *PlayerResponse) (TrackData, string) {
 	var trackData TrackData
 	var contentType string
@@ -44,7 +166,12 @@ func ParsePlayerResponse(data *PlayerResponse) (TrackData, string) {
 		return trackData, "unknown"
 	}
 
-	switch data.CurrentlyPlayingType {
+	playingType := data.CurrentlyPlayingType
+	if data.Item.Type == "chapter" {
+		playingType = "chapter"
+	}
+
+	switch playingType {
 	case "track":
 		contentType = "track"
 		trackArtists := make([]string, 0, len(data.Item.Artists))
@@ -57,7 +184,8 @@ func ParsePlayerResponse(data *PlayerResponse) (TrackData, string) {
 			TrackName:  data.Item.Name,
 			TrackURL:   data.Item.ExternalUrls.Spotify,
 			ArtistName: trackArtist,
-			TrackEnc:   url.QueryEscape(fmt.Sprintf("%s / %s\n#NowPlaying #PsrPlaying", data.Item.Name, trackArtist)),
+			AlbumName:  data.Item.Album.Name,
+			ImageURL:   largestImageURL(data.Item.Album.Images),
 		}
 	case "episode":
 		contentType = "episode"
@@ -65,12 +193,46 @@ func ParsePlayerResponse(data *PlayerResponse) (TrackData, string) {
 			TrackName:  data.Item.Name,
 			TrackURL:   data.Item.ExternalUrls.Spotify,
 			ArtistName: data.Item.Show.Name,
-			TrackEnc:   url.QueryEscape(fmt.Sprintf("%s / %s\n#NowPlaying", data.Item.Name, data.Item.Show.Name)),
+			ShowName:   data.Item.Show.Name,
+			ImageURL:   largestImageURL(data.Item.Images),
+		}
+	case "chapter":
+		contentType = "chapter"
+		authors := make([]string, 0, len(data.Item.Audiobook.Authors))
+		for _, author := range data.Item.Audiobook.Authors {
+			authors = append(authors, author.Name)
+		}
+		images := data.Item.Images
+		if len(images) == 0 {
+			images = data.Item.Audiobook.Images
+		}
+		trackData = TrackData{
+			TrackName:  data.Item.Name,
+			TrackURL:   data.Item.ExternalUrls.Spotify,
+			ArtistName: strings.Join(authors, ", "),
+			ShowName:   data.Item.Audiobook.Name,
+			ImageURL:   largestImageURL(images),
 		}
 	default:
-		contentType = "unknown"
+		return trackData, "unknown"
 	}
 
+	trackData.TrackID = data.Item.ID
+	trackData.TrackURI = data.Item.URI
+	trackData.IsPlaying = data.IsPlaying
+	trackData.ProgressMs = data.ProgressMs
+	trackData.DurationMs = data.Item.DurationMs
+	trackData.Explicit = data.Item.Explicit
+	trackData.DeviceName = data.Device.Name
+	trackData.DeviceType = data.Device.Type
+	trackData.Shuffle = data.ShuffleState
+	if data.Context != nil {
+		trackData.ContextType = data.Context.Type
+		trackData.ContextURI = data.Context.URI
+	}
+
+	trackData.TrackEnc = url.QueryEscape(shareText(trackData, contentType, posttemplate.RenderDefault))
+
 	return trackData, contentType
 }
 
@@ -90,12 +252,19 @@ func BuildShareURL(trackData TrackData, platform string) string {
 	}
 }
 
-// GetShareInfo はPlayerResponseからシェア情報を取得する
+// GetShareInfo はPlayerResponseから既定のテンプレートでシェア情報を取得する
 func GetShareInfo(data *PlayerResponse, platform string) (shareURL string, contentType string) {
+	return GetShareInfoWithTemplate(data, platform, posttemplate.RenderDefault)
+}
+
+// GetShareInfoWithTemplate はPlayerResponseからシェア情報を取得する
+// シェアテキストはrenderで生成する（ユーザーのテンプレートを使う場合）
+func GetShareInfoWithTemplate(data *PlayerResponse, platform string, render func(posttemplate.Data) string) (shareURL string, contentType string) {
 	trackData, contentType := ParsePlayerResponse(data)
 	if contentType == "unknown" {
 		return "", contentType
 	}
+	trackData.TrackEnc = url.QueryEscape(shareText(trackData, contentType, render))
 	shareURL = BuildShareURL(trackData, platform)
 	return shareURL, contentType
 }
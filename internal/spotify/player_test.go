@@ -1,10 +1,15 @@
 package spotify
 
 import (
+	"encoding/json"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/Soli0222/spotify-nowplaying/internal/posttemplate"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetShareInfo_Track_Misskey(t *testing.T) {
@@ -50,6 +55,29 @@ func TestGetShareInfo_Track_Twitter(t *testing.T) {
 	assert.Contains(t, shareURL, url.QueryEscape("Test Song / Artist1, Artist2"))
 }
 
+func TestGetShareInfoWithTemplate(t *testing.T) {
+	playerResp := &PlayerResponse{
+		CurrentlyPlayingType: "track",
+		Item: Item{
+			Name:    "Test Song",
+			Artists: []Artist{{Name: "Test Artist"}},
+			ExternalUrls: ExternalUrls{
+				Spotify: "https://open.spotify.com/track/123",
+			},
+		},
+	}
+
+	shareURL, contentType := GetShareInfoWithTemplate(playerResp, "Twitter", func(data posttemplate.Data) string {
+		// URLはシェアURLのパラメータで渡すため、テンプレートには空で渡される
+		assert.Empty(t, data.URL)
+		return "🎧 " + data.Track
+	})
+
+	assert.Equal(t, "track", contentType)
+	assert.Contains(t, shareURL, "url=https://open.spotify.com/track/123")
+	assert.Contains(t, shareURL, "text="+url.QueryEscape("🎧 Test Song"))
+}
+
 func TestGetShareInfo_Episode(t *testing.T) {
 	t.Setenv("SERVER_URI", "https://misskey.example.com")
 
@@ -57,9 +85,7 @@ func TestGetShareInfo_Episode(t *testing.T) {
 		CurrentlyPlayingType: "episode",
 		Item: Item{
 			Name: "Test Episode",
-			Show: struct {
-				Name string `json:"name"`
-			}{Name: "Test Podcast"},
+			Show: Show{Name: "Test Podcast"},
 			ExternalUrls: ExternalUrls{
 				Spotify: "https://open.spotify.com/episode/789",
 			},
@@ -155,9 +181,7 @@ func TestParsePlayerResponse_Episode(t *testing.T) {
 		CurrentlyPlayingType: "episode",
 		Item: Item{
 			Name: "Test Episode",
-			Show: struct {
-				Name string `json:"name"`
-			}{Name: "Test Podcast"},
+			Show: Show{Name: "Test Podcast"},
 			ExternalUrls: ExternalUrls{
 				Spotify: "https://open.spotify.com/episode/789",
 			},
@@ -210,3 +234,273 @@ func TestBuildShareURL_UnknownPlatform(t *testing.T) {
 
 	assert.Empty(t, shareURL)
 }
+
+func TestTrackData_TemplateData(t *testing.T) {
+	playerResp := &PlayerResponse{
+		ProgressMs:           83000,
+		CurrentlyPlayingType: "track",
+		Item: Item{
+			Name:    "Test Song",
+			Artists: []Artist{{Name: "Artist1"}, {Name: "Artist2"}},
+			Album:   Album{Name: "Test Album"},
+			ExternalUrls: ExternalUrls{
+				Spotify: "https://open.spotify.com/track/123",
+			},
+		},
+		Device: Device{Name: "iPhone"},
+	}
+
+	trackData, contentType := ParsePlayerResponse(playerResp)
+	data := trackData.TemplateData(contentType)
+
+	assert.Equal(t, "track", data.Type)
+	assert.Equal(t, "Test Song", data.Track)
+	assert.Equal(t, "Artist1, Artist2", data.Artists)
+	assert.Equal(t, "Test Album", data.Album)
+	assert.Equal(t, "", data.Show)
+	assert.Equal(t, "https://open.spotify.com/track/123", data.URL)
+	assert.Equal(t, "1:23", data.Progress)
+	assert.Equal(t, "iPhone", data.Device)
+}
+
+func TestTrackData_TemplateData_Episode(t *testing.T) {
+	trackData := TrackData{TrackName: "Test Episode", ArtistName: "Test Podcast", ShowName: "Test Podcast"}
+
+	data := trackData.TemplateData("episode")
+
+	assert.Equal(t, "Test Podcast", data.Show)
+	assert.Equal(t, "", data.Artists)
+	assert.Equal(t, "0:00", data.Progress)
+}
+
+func TestParsePlayerResponse_ImageURL(t *testing.T) {
+	track := &PlayerResponse{
+		CurrentlyPlayingType: "track",
+		Item: Item{
+			Name: "Test Song",
+			Album: Album{
+				Name: "Test Album",
+				Images: []Image{
+					{URL: "https://i.scdn.co/image/small", Width: 64, Height: 64},
+					{URL: "https://i.scdn.co/image/large", Width: 640, Height: 640},
+					{URL: "https://i.scdn.co/image/medium", Width: 300, Height: 300},
+				},
+			},
+		},
+	}
+	trackData, _ := ParsePlayerResponse(track)
+	assert.Equal(t, "https://i.scdn.co/image/large", trackData.ImageURL)
+
+	episode := &PlayerResponse{
+		CurrentlyPlayingType: "episode",
+		Item: Item{
+			Name:   "Test Episode",
+			Images: []Image{{URL: "https://i.scdn.co/image/episode", Width: 640, Height: 640}},
+		},
+	}
+	episodeData, _ := ParsePlayerResponse(episode)
+	assert.Equal(t, "https://i.scdn.co/image/episode", episodeData.ImageURL)
+
+	noImages := &PlayerResponse{CurrentlyPlayingType: "track", Item: Item{Name: "Test Song"}}
+	noImagesData, _ := ParsePlayerResponse(noImages)
+	assert.Empty(t, noImagesData.ImageURL)
+}
+
+// loadPlayerFixture はtestdataに保存したSpotify Player APIのレスポンスを読み込む
+func loadPlayerFixture(t *testing.T, name string) *PlayerResponse {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	var resp PlayerResponse
+	require.NoError(t, json.Unmarshal(body, &resp))
+	return &resp
+}
+
+func TestPlayerResponse_UnmarshalFixtures(t *testing.T) {
+	volume := 62
+	tests := []struct {
+		fixture string
+		check   func(t *testing.T, resp *PlayerResponse)
+	}{
+		{
+			fixture: "player_track.json",
+			check: func(t *testing.T, resp *PlayerResponse) {
+				assert.True(t, resp.IsPlaying)
+				assert.Equal(t, 83000, resp.ProgressMs)
+				assert.Equal(t, int64(1767225600000), resp.Timestamp)
+				assert.True(t, resp.ShuffleState)
+				assert.Equal(t, "context", resp.RepeatState)
+				assert.Equal(t, Device{
+					ID:            "3f228e06c8562e2f439e22932da6c3231715ed53",
+					Name:          "Soli's MacBook Pro",
+					Type:          "Computer",
+					IsActive:      true,
+					VolumePercent: &volume,
+				}, resp.Device)
+				require.NotNil(t, resp.Context)
+				assert.Equal(t, "playlist", resp.Context.Type)
+				assert.Equal(t, "spotify:playlist:37i9dQZF1DXdbRLJPSmnyq", resp.Context.URI)
+				assert.Equal(t, "https://open.spotify.com/playlist/37i9dQZF1DXdbRLJPSmnyq", resp.Context.ExternalUrls.Spotify)
+
+				item := resp.Item
+				assert.Equal(t, "5WehEFiES0ebVqgXpYQ8Fi", item.ID)
+				assert.Equal(t, "track", item.Type)
+				assert.Equal(t, "spotify:track:5WehEFiES0ebVqgXpYQ8Fi", item.URI)
+				assert.Equal(t, 296000, item.DurationMs)
+				assert.False(t, item.IsLocal)
+				require.Len(t, item.Artists, 2)
+				assert.Equal(t, "0bAsR2unSRpn6BQPEnNlZm", item.Artists[0].ID)
+				assert.Equal(t, "spotify:artist:0bAsR2unSRpn6BQPEnNlZm", item.Artists[0].URI)
+				assert.Equal(t, "4m2880jivSbbyEGAKfITCa", item.Album.ID)
+				assert.Equal(t, "single", item.Album.AlbumType)
+				assert.Equal(t, "2023-03-08", item.Album.ReleaseDate)
+				assert.Len(t, item.Album.Images, 3)
+			},
+		},
+		{
+			fixture: "player_episode.json",
+			check: func(t *testing.T, resp *PlayerResponse) {
+				assert.Equal(t, "episode", resp.CurrentlyPlayingType)
+				assert.Nil(t, resp.Device.VolumePercent)
+				require.NotNil(t, resp.Context)
+				assert.Equal(t, "show", resp.Context.Type)
+				assert.Equal(t, "episode", resp.Item.Type)
+				assert.Equal(t, 2745000, resp.Item.DurationMs)
+				assert.Equal(t, Show{
+					ID:           "2MAi0BvDc6GTFvKFPXnkCL",
+					Name:         "週刊ミュージックレーダー",
+					URI:          "spotify:show:2MAi0BvDc6GTFvKFPXnkCL",
+					Publisher:    "Music Radar",
+					ExternalUrls: ExternalUrls{Spotify: "https://open.spotify.com/show/2MAi0BvDc6GTFvKFPXnkCL"},
+					Images:       []Image{{URL: "https://i.scdn.co/image/ab6765630000ba8ac0ffee", Height: 640, Width: 640}},
+				}, resp.Item.Show)
+			},
+		},
+		{
+			fixture: "player_private_session.json",
+			check: func(t *testing.T, resp *PlayerResponse) {
+				assert.False(t, resp.IsPlaying)
+				assert.Nil(t, resp.Context)
+				assert.Empty(t, resp.Device.ID)
+				assert.True(t, resp.Device.IsPrivateSession)
+				assert.True(t, resp.Device.IsRestricted)
+				assert.True(t, resp.Item.Explicit)
+				assert.Empty(t, resp.Item.Album.Images)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			tt.check(t, loadPlayerFixture(t, tt.fixture))
+		})
+	}
+}
+
+func TestParsePlayerResponse_Fixtures(t *testing.T) {
+	tests := []struct {
+		fixture         string
+		wantContentType string
+		want            TrackData
+	}{
+		{
+			fixture:         "player_track.json",
+			wantContentType: "track",
+			want: TrackData{
+				TrackID:     "5WehEFiES0ebVqgXpYQ8Fi",
+				TrackURI:    "spotify:track:5WehEFiES0ebVqgXpYQ8Fi",
+				TrackName:   "あとがき",
+				TrackURL:    "https://open.spotify.com/track/5WehEFiES0ebVqgXpYQ8Fi",
+				ArtistName:  "来栖夏芽, Guest",
+				AlbumName:   "あとがき",
+				IsPlaying:   true,
+				ProgressMs:  83000,
+				DurationMs:  296000,
+				DeviceName:  "Soli's MacBook Pro",
+				DeviceType:  "Computer",
+				ContextType: "playlist",
+				ContextURI:  "spotify:playlist:37i9dQZF1DXdbRLJPSmnyq",
+				Shuffle:     true,
+				ImageURL:    "https://i.scdn.co/image/ab67616d0000b273c0ffee",
+			},
+		},
+		{
+			fixture:         "player_episode.json",
+			wantContentType: "episode",
+			want: TrackData{
+				TrackID:     "512ojhOuo1ktJprKbVcKyQ",
+				TrackURI:    "spotify:episode:512ojhOuo1ktJprKbVcKyQ",
+				TrackName:   "#42 新年スペシャル",
+				TrackURL:    "https://open.spotify.com/episode/512ojhOuo1ktJprKbVcKyQ",
+				ArtistName:  "週刊ミュージックレーダー",
+				ShowName:    "週刊ミュージックレーダー",
+				IsPlaying:   true,
+				ProgressMs:  1234567,
+				DurationMs:  2745000,
+				DeviceName:  "iPhone",
+				DeviceType:  "Smartphone",
+				ContextType: "show",
+				ContextURI:  "spotify:show:2MAi0BvDc6GTFvKFPXnkCL",
+				ImageURL:    "https://i.scdn.co/image/ab6765630000ba8ac0ffee",
+			},
+		},
+		{
+			fixture:         "player_private_session.json",
+			wantContentType: "track",
+			want: TrackData{
+				TrackID:    "3MwQ4IIdfIMZqmuJVsmyvw",
+				TrackURI:   "spotify:track:3MwQ4IIdfIMZqmuJVsmyvw",
+				TrackName:  "感電",
+				TrackURL:   "https://open.spotify.com/track/3MwQ4IIdfIMZqmuJVsmyvw",
+				ArtistName: "Kenshi Yonezu",
+				AlbumName:  "STRAY SHEEP",
+				ProgressMs: 5000,
+				DurationMs: 238000,
+				Explicit:   true,
+				DeviceName: "Living Room",
+				DeviceType: "Speaker",
+			},
+		},
+		{
+			fixture:         "player_chapter.json",
+			wantContentType: "chapter",
+			want: TrackData{
+				TrackID:     "0D5wENdkdwbqlrHoaJ9g29",
+				TrackURI:    "spotify:episode:0D5wENdkdwbqlrHoaJ9g29",
+				TrackName:   "Chapter 3",
+				TrackURL:    "https://open.spotify.com/episode/0D5wENdkdwbqlrHoaJ9g29",
+				ArtistName:  "Jane Austen",
+				ShowName:    "Pride and Prejudice",
+				IsPlaying:   true,
+				ProgressMs:  600000,
+				DurationMs:  1520000,
+				DeviceName:  "iPhone",
+				DeviceType:  "Smartphone",
+				ContextType: "audiobook",
+				ContextURI:  "spotify:audiobook:7iHfbu1YPACw6oZPAFJtqe",
+				ImageURL:    "https://i.scdn.co/image/ab676663000022a8c0ffee",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			trackData, contentType := ParsePlayerResponse(loadPlayerFixture(t, tt.fixture))
+
+			assert.Equal(t, tt.wantContentType, contentType)
+			// TrackEncはシェアテキストから生成されるため個別に確認する
+			assert.NotEmpty(t, trackData.TrackEnc)
+			trackData.TrackEnc = ""
+			assert.Equal(t, tt.want, trackData)
+		})
+	}
+}
+
+func TestTrackData_TemplateData_Duration(t *testing.T) {
+	trackData, contentType := ParsePlayerResponse(loadPlayerFixture(t, "player_track.json"))
+
+	data := trackData.TemplateData(contentType)
+
+	assert.Equal(t, "1:23", data.Progress)
+	assert.Equal(t, "4:56", data.Duration)
+}
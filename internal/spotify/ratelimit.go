@@ -0,0 +1,168 @@
+package spotify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/metrics"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultRateLimit は全ユーザー合計で1秒あたりに送るSpotify APIリクエスト数
+	DefaultRateLimit = 5
+	// DefaultRateBurst は瞬間的に送れるリクエスト数
+	DefaultRateBurst = 10
+
+	defaultMaxRetries     = 2
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	// defaultMaxRetryWait を超える待ち時間を指定された場合は再試行せずにエラーを返す
+	defaultMaxRetryWait = 10 * time.Second
+	// maxRateLimitPause は429で全体を停止する時間の上限
+	// Retry-Afterがこれより長くても、過ぎたらSpotifyに問い合わせ直す
+	maxRateLimitPause = time.Minute
+)
+
+// rateLimiter はクライアントIDで共有されるSpotifyのレート制限を超えないように
+// 全ユーザーのリクエストを間引き、429を受けたときは指定時間まで全体を停止する
+type rateLimiter struct {
+	limiter *rate.Limiter
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// newRateLimiter は1秒あたりlimit件、バーストburst件のrateLimiterを作成する
+// limitが0以下の場合はリクエストを間引かない
+func newRateLimiter(limit float64, burst int) *rateLimiter {
+	l := rate.Inf
+	if limit > 0 {
+		l = rate.Limit(limit)
+	}
+	return &rateLimiter{limiter: rate.NewLimiter(l, max(burst, 1))}
+}
+
+// rateLimitFromEnv はSPOTIFY_RATE_LIMIT / SPOTIFY_RATE_BURSTからレート制限を読み込む
+func rateLimitFromEnv() (float64, int) {
+	limit, burst := float64(DefaultRateLimit), DefaultRateBurst
+
+	// SPOTIFY_RATE_LIMIT（1秒あたりのリクエスト数、0で無制限）
+	if val := os.Getenv("SPOTIFY_RATE_LIMIT"); val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil && f >= 0 {
+			limit = f
+		}
+	}
+
+	// SPOTIFY_RATE_BURST
+	if val := os.Getenv("SPOTIFY_RATE_BURST"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			burst = n
+		}
+	}
+
+	return limit, burst
+}
+
+// wait はリクエストを送れるようになるまで待機する
+// 待たされた場合はスロットリングとしてメトリクスに記録する
+// 停止の残り時間がmaxWaitやctxの期限を超える場合は待たずに429のAPIErrorを返す
+func (l *rateLimiter) wait(ctx context.Context, endpoint string, maxWait time.Duration) error {
+	l.mu.Lock()
+	pause := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+	if pause > 0 {
+		metrics.SpotifyAPIThrottledTotal.WithLabelValues(endpoint, "client").Inc()
+		if deadline, ok := ctx.Deadline(); pause > maxWait || (ok && time.Now().Add(pause).After(deadline)) {
+			return &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: pause}
+		}
+		if err := sleep(ctx, pause); err != nil {
+			return err
+		}
+	}
+
+	if !l.limiter.Allow() {
+		metrics.SpotifyAPIThrottledTotal.WithLabelValues(endpoint, "client").Inc()
+		return l.limiter.Wait(ctx)
+	}
+	return nil
+}
+
+// pause は指定時間（最大maxRateLimitPause）まで全てのリクエストを止める
+func (l *rateLimiter) pause(d time.Duration) {
+	d = min(d, maxRateLimitPause)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// do はレート制限を守ってリクエストを送り、429と5xxは再試行する
+// リクエストボディを送り直せるよう、newRequestは試行ごとに新しいリクエストを作る
+// 再試行しきれなかった場合は最後のレスポンスをそのまま返す
+func (c *HTTPClient) do(ctx context.Context, endpoint string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx, endpoint, c.maxRetryWait); err != nil {
+			return nil, err
+		}
+
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return resp, nil
+		}
+
+		delay := retryAfter(resp.Header)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			metrics.SpotifyAPIThrottledTotal.WithLabelValues(endpoint, "spotify").Inc()
+			// レート制限はクライアントID全体にかかるため、他のユーザーのリクエストも止める
+			c.limiter.pause(delay)
+		}
+		if delay == 0 {
+			delay = c.retryBaseDelay << attempt
+		}
+		if attempt >= c.maxRetries || delay > c.maxRetryWait {
+			return resp, nil
+		}
+
+		c.logger.Warn("retrying spotify api request", "endpoint", endpoint, "status", resp.StatusCode, "delay", delay.String())
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryAfter はRetry-Afterヘッダー（秒数）の待ち時間を返す
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// sleep はdの間、またはctxがキャンセルされるまで待機する
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
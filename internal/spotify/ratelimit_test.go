@@ -0,0 +1,160 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStatusServer は指定したステータスを順に返し、最後は再生情報を返すテスト用サーバー
+func newStatusServer(t *testing.T, calls *atomic.Int32, retryAfter string, statuses ...int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		_ = json.NewEncoder(w).Encode(PlayerResponse{CurrentlyPlayingType: "track"})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPClient_RetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		wantCalls  int32
+		wantStatus int
+	}{
+		{"429の後に成功", []int{http.StatusTooManyRequests}, 2, 0},
+		{"5xxの後に成功", []int{http.StatusBadGateway, http.StatusServiceUnavailable}, 3, 0},
+		{"再試行回数を超えた", []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}, 3, http.StatusServiceUnavailable},
+		{"4xxは再試行しない", []int{http.StatusUnauthorized}, 1, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := newStatusServer(t, &calls, "0", tt.statuses...)
+			client := NewHTTPClient(WithPlayerURL(server.URL), WithRetry(2, time.Millisecond, time.Second))
+
+			resp, _, err := client.GetPlayerData(context.Background(), "test-token")
+
+			assert.Equal(t, tt.wantCalls, calls.Load())
+			if tt.wantStatus == 0 {
+				require.NoError(t, err)
+				assert.Equal(t, "track", resp.CurrentlyPlayingType)
+				return
+			}
+			apiErr, ok := IsAPIError(err)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantStatus, apiErr.StatusCode)
+		})
+	}
+}
+
+func TestHTTPClient_RetryAfterTooLong(t *testing.T) {
+	var calls atomic.Int32
+	server := newStatusServer(t, &calls, "60", http.StatusTooManyRequests)
+	client := NewHTTPClient(WithPlayerURL(server.URL), WithRetry(2, time.Millisecond, time.Second))
+
+	_, _, err := client.GetPlayerData(context.Background(), "test-token")
+
+	apiErr, ok := IsAPIError(err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, time.Minute, apiErr.RetryAfter)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// レート制限が解除されるまでは他のユーザーのリクエストも送らず、待たずに429を返す
+	started := time.Now()
+	_, _, err = client.GetPlayerData(context.Background(), "other-token")
+	apiErr, ok = IsAPIError(err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Greater(t, apiErr.RetryAfter, 50*time.Second)
+	assert.Less(t, time.Since(started), time.Second)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRateLimiter_WaitReturnsWhenPauseExceedsDeadline(t *testing.T) {
+	l := newRateLimiter(0, 1)
+	l.pause(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err := l.wait(ctx, "test", time.Second)
+
+	apiErr, ok := IsAPIError(err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Less(t, time.Since(started), 50*time.Millisecond)
+
+	// 期限内に解除される停止は待ってから通す
+	require.NoError(t, l.wait(context.Background(), "test", time.Second))
+	assert.GreaterOrEqual(t, time.Since(started), 150*time.Millisecond)
+}
+
+func TestRateLimiter_PauseIsCapped(t *testing.T) {
+	l := newRateLimiter(0, 1)
+	l.pause(time.Hour)
+
+	assert.WithinDuration(t, time.Now().Add(maxRateLimitPause), l.pausedUntil, time.Second)
+}
+
+func TestHTTPClient_RetryResendsTokenForm(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "old-refresh", r.Form.Get("refresh_token"))
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Tokens{AccessToken: "new-access"})
+	}))
+	defer server.Close()
+	client := NewHTTPClient(WithTokenURL(server.URL), WithRetry(2, time.Millisecond, time.Second))
+
+	tokens, err := client.RefreshToken(context.Background(), "old-refresh")
+
+	require.NoError(t, err)
+	assert.Equal(t, "new-access", tokens.AccessToken)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestHTTPClient_RateLimit(t *testing.T) {
+	var calls atomic.Int32
+	server := newStatusServer(t, &calls, "")
+	client := NewHTTPClient(WithPlayerURL(server.URL), WithRateLimit(20, 1))
+
+	started := time.Now()
+	for range 3 {
+		_, _, err := client.GetPlayerData(context.Background(), "test-token")
+		require.NoError(t, err)
+	}
+
+	// バースト1件、毎秒20件なので2件目以降は50msずつ待たされる
+	assert.GreaterOrEqual(t, time.Since(started), 90*time.Millisecond)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestRateLimitFromEnv(t *testing.T) {
+	t.Setenv("SPOTIFY_RATE_LIMIT", "2.5")
+	t.Setenv("SPOTIFY_RATE_BURST", "4")
+
+	limit, burst := rateLimitFromEnv()
+
+	assert.Equal(t, 2.5, limit)
+	assert.Equal(t, 4, burst)
+}
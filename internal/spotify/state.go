@@ -0,0 +1,64 @@
+package spotify
+
+// PlaybackState は再生状態の種類
+type PlaybackState string
+
+const (
+	// StateIdle は何も再生していない状態（Player APIが204を返した）
+	StateIdle PlaybackState = "idle"
+	// StatePaused は再生アイテムがあるが一時停止中の状態
+	StatePaused PlaybackState = "paused"
+	// StateAd は広告を再生中の状態（アイテムの情報は返されない）
+	StateAd PlaybackState = "ad"
+	// StateLocalFile はローカルファイルを再生中の状態（SpotifyのURLやアートワークがない）
+	StateLocalFile PlaybackState = "local_file"
+	// StateTrack はトラックを再生中の状態
+	StateTrack PlaybackState = "track"
+	// StateEpisode はポッドキャストのエピソードを再生中の状態
+	StateEpisode PlaybackState = "episode"
+	// StateChapter はオーディオブックのチャプターを再生中の状態
+	StateChapter PlaybackState = "chapter"
+	// StateUnknown は上記のいずれにも当てはまらない状態
+	StateUnknown PlaybackState = "unknown"
+)
+
+// State は再生状態を返す
+// 一時停止中はアイテムの種類に関わらずStatePausedを返す（種類はContentStateで取得する）
+func (r *PlayerResponse) State() PlaybackState {
+	content := r.ContentState()
+	switch content {
+	case StateIdle, StateAd:
+		return content
+	}
+	if !r.IsPlaying {
+		return StatePaused
+	}
+	return content
+}
+
+// ContentState は一時停止中かどうかに関わらず、再生中のアイテムの種類を返す
+func (r *PlayerResponse) ContentState() PlaybackState {
+	if r == nil {
+		return StateIdle
+	}
+	if r.CurrentlyPlayingType == "ad" {
+		return StateAd
+	}
+	if r.CurrentlyPlayingType == "" && r.Item.URI == "" && r.Item.Name == "" {
+		return StateIdle
+	}
+	if r.Item.IsLocal {
+		return StateLocalFile
+	}
+
+	switch {
+	case r.Item.Type == "chapter":
+		return StateChapter
+	case r.CurrentlyPlayingType == "track":
+		return StateTrack
+	case r.CurrentlyPlayingType == "episode":
+		return StateEpisode
+	default:
+		return StateUnknown
+	}
+}
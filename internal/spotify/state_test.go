@@ -0,0 +1,67 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlayerResponse_State_Fixtures(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		wantState   PlaybackState
+		wantContent PlaybackState
+	}{
+		{fixture: "player_track.json", wantState: StateTrack, wantContent: StateTrack},
+		{fixture: "player_episode.json", wantState: StateEpisode, wantContent: StateEpisode},
+		{fixture: "player_chapter.json", wantState: StateChapter, wantContent: StateChapter},
+		{fixture: "player_ad.json", wantState: StateAd, wantContent: StateAd},
+		{fixture: "player_local_file.json", wantState: StateLocalFile, wantContent: StateLocalFile},
+		// 一時停止中のトラック
+		{fixture: "player_private_session.json", wantState: StatePaused, wantContent: StateTrack},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			resp := loadPlayerFixture(t, tt.fixture)
+
+			assert.Equal(t, tt.wantState, resp.State())
+			assert.Equal(t, tt.wantContent, resp.ContentState())
+		})
+	}
+}
+
+func TestPlayerResponse_State(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *PlayerResponse
+		want PlaybackState
+	}{
+		{
+			name: "nil",
+			resp: nil,
+			want: StateIdle,
+		},
+		{
+			name: "204のレスポンス",
+			resp: &PlayerResponse{},
+			want: StateIdle,
+		},
+		{
+			name: "一時停止中の広告は広告として扱う",
+			resp: &PlayerResponse{CurrentlyPlayingType: "ad"},
+			want: StateAd,
+		},
+		{
+			name: "未知の種類",
+			resp: &PlayerResponse{CurrentlyPlayingType: "unknown", IsPlaying: true, Item: Item{Name: "Something"}},
+			want: StateUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.resp.State())
+		})
+	}
+}
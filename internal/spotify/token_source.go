@@ -0,0 +1,200 @@
+package spotify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultRefreshMargin は有効期限のどれだけ前にアクセストークンを更新するか
+	DefaultRefreshMargin = 5 * time.Minute
+
+	// refreshTimeout はトークンの更新と保存にかける最大時間
+	// 更新はリクエストのキャンセルと切り離して実行するため、独自のタイムアウトを設ける
+	refreshTimeout = 30 * time.Second
+)
+
+var (
+	// ErrNotConnected はユーザーがSpotifyのアクセストークンを持っていないことを表す
+	ErrNotConnected = errors.New("spotify not connected")
+	// ErrNoRefreshToken はトークンの更新に必要なリフレッシュトークンがないことを表す
+	ErrNoRefreshToken = errors.New("spotify token expired and no refresh token available")
+)
+
+// TokenStore は更新したトークンを永続化する
+type TokenStore interface {
+	UpdateSpotifyToken(ctx context.Context, userID uuid.UUID, accessToken, refreshToken string, expiresAt time.Time) error
+}
+
+// RefreshLocker はレプリカ間でトークン更新を直列化できるTokenStore
+// TokenStoreがこれを実装している場合、ロック取得後に保存済みのトークンを読み直し、
+// 他のレプリカが更新済みであればそれを再利用する
+type RefreshLocker interface {
+	WithRefreshLock(ctx context.Context, platform string, userID uuid.UUID, fn func(ctx context.Context) error) error
+	GetUserByID(ctx context.Context, id uuid.UUID) (*store.User, error)
+}
+
+// refreshResult は更新後（または再利用した）トークン
+type refreshResult struct {
+	accessToken  string
+	refreshToken string
+	expiresAt    sql.NullTime
+}
+
+// TokenSource はユーザーの有効なアクセストークンを返す
+// 保存された有効期限が近づいている場合は事前にトークンを更新して永続化する
+// 同じユーザーの同時更新はプロセス内でまとめ、RefreshLockerがあればレプリカ間でも直列化する
+type TokenSource struct {
+	client  Client
+	store   TokenStore
+	margin  time.Duration
+	now     func() time.Time
+	flights singleflight.Group
+}
+
+// TokenSourceOption はTokenSourceの設定オプション
+type TokenSourceOption func(*TokenSource)
+
+// WithRefreshMargin は有効期限前に更新を始める猶予を設定する
+func WithRefreshMargin(margin time.Duration) TokenSourceOption {
+	return func(s *TokenSource) {
+		s.margin = margin
+	}
+}
+
+// NewTokenSource は新しいTokenSourceを作成する
+func NewTokenSource(client Client, store TokenStore, opts ...TokenSourceOption) *TokenSource {
+	s := &TokenSource{
+		client: client,
+		store:  store,
+		margin: DefaultRefreshMargin,
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// AccessToken はユーザーの有効なアクセストークンを返す
+// 有効期限が猶予以内に迫っている場合は更新してから返す
+func (s *TokenSource) AccessToken(ctx context.Context, user *store.User) (string, error) {
+	if !user.SpotifyAccessToken.Valid || user.SpotifyAccessToken.String == "" {
+		return "", ErrNotConnected
+	}
+
+	if !s.expiring(user) {
+		return user.SpotifyAccessToken.String, nil
+	}
+
+	return s.Refresh(ctx, user)
+}
+
+// Refresh はアクセストークンを強制的に更新し、新しいアクセストークンを返す
+// APIが401を返した場合など、保存された有効期限より前に失効したときに使用する
+// 他のリクエストやレプリカが既に更新していれば、そのトークンを再利用する
+// 更新と保存は最初の呼び出し元のキャンセルに影響されずに完了させ、各呼び出し元は自分のctxが
+// 終わった時点で待機をやめる
+func (s *TokenSource) Refresh(ctx context.Context, user *store.User) (string, error) {
+	if !user.SpotifyRefreshToken.Valid || user.SpotifyRefreshToken.String == "" {
+		return "", ErrNoRefreshToken
+	}
+
+	staleAccessToken := user.SpotifyAccessToken.String
+	refreshToken := user.SpotifyRefreshToken.String
+	ch := s.flights.DoChan(user.ID.String(), func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+		defer cancel()
+		return s.refreshLocked(ctx, user.ID, staleAccessToken, refreshToken)
+	})
+
+	var res singleflight.Result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if res.Err != nil {
+		return "", res.Err
+	}
+
+	result := res.Val.(refreshResult)
+	user.SpotifyAccessToken = sql.NullString{String: result.accessToken, Valid: true}
+	user.SpotifyRefreshToken = sql.NullString{String: result.refreshToken, Valid: result.refreshToken != ""}
+	user.SpotifyTokenExpiresAt = result.expiresAt
+
+	return result.accessToken, nil
+}
+
+// refreshLocked はRefreshLockerがあればロックを取得してからトークンを更新する
+func (s *TokenSource) refreshLocked(ctx context.Context, userID uuid.UUID, staleAccessToken, refreshToken string) (refreshResult, error) {
+	locker, ok := s.store.(RefreshLocker)
+	if !ok {
+		return s.refresh(ctx, userID, refreshToken)
+	}
+
+	var result refreshResult
+	err := locker.WithRefreshLock(ctx, "spotify", userID, func(ctx context.Context) error {
+		stored, err := locker.GetUserByID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to reload spotify token: %w", err)
+		}
+		if stored == nil {
+			return ErrNotConnected
+		}
+
+		// 待機中に他のレプリカが更新していれば再利用する
+		if stored.SpotifyAccessToken.Valid && stored.SpotifyAccessToken.String != staleAccessToken && !s.expiring(stored) {
+			result = refreshResult{
+				accessToken:  stored.SpotifyAccessToken.String,
+				refreshToken: stored.SpotifyRefreshToken.String,
+				expiresAt:    stored.SpotifyTokenExpiresAt,
+			}
+			return nil
+		}
+
+		if !stored.SpotifyRefreshToken.Valid || stored.SpotifyRefreshToken.String == "" {
+			return ErrNoRefreshToken
+		}
+		result, err = s.refresh(ctx, userID, stored.SpotifyRefreshToken.String)
+		return err
+	})
+	return result, err
+}
+
+// refresh はリフレッシュトークンで新しいトークンを取得して永続化する
+func (s *TokenSource) refresh(ctx context.Context, userID uuid.UUID, refreshToken string) (refreshResult, error) {
+	tokens, err := s.client.RefreshToken(ctx, refreshToken)
+	if err != nil {
+		return refreshResult{}, fmt.Errorf("failed to refresh spotify token: %w", err)
+	}
+
+	expiresAt := s.now().Add(time.Duration(tokens.ExpiresIn) * time.Second)
+	if err := s.store.UpdateSpotifyToken(ctx, userID, tokens.AccessToken, tokens.RefreshToken, expiresAt); err != nil {
+		return refreshResult{}, fmt.Errorf("failed to update spotify token: %w", err)
+	}
+
+	return refreshResult{
+		accessToken:  tokens.AccessToken,
+		refreshToken: tokens.RefreshToken,
+		expiresAt:    sql.NullTime{Time: expiresAt, Valid: true},
+	}, nil
+}
+
+// expiring は保存された有効期限が猶予以内かどうかを判定する
+// 有効期限が不明な場合は401を受けるまで現在のトークンを使う
+func (s *TokenSource) expiring(user *store.User) bool {
+	if !user.SpotifyTokenExpiresAt.Valid {
+		return false
+	}
+	return s.now().Add(s.margin).After(user.SpotifyTokenExpiresAt.Time)
+}
@@ -0,0 +1,251 @@
+package spotify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTokenStore はテスト用のトークン保存先
+type fakeTokenStore struct {
+	updates      int
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+}
+
+func (s *fakeTokenStore) UpdateSpotifyToken(ctx context.Context, userID uuid.UUID, accessToken, refreshToken string, expiresAt time.Time) error {
+	s.updates++
+	s.accessToken = accessToken
+	s.refreshToken = refreshToken
+	s.expiresAt = expiresAt
+	return nil
+}
+
+func newTokenServer(t *testing.T, refreshes *int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*refreshes++
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "refresh_token", r.Form.Get("grant_type"))
+		assert.Equal(t, "old-refresh", r.Form.Get("refresh_token"))
+		_ = json.NewEncoder(w).Encode(Tokens{AccessToken: "new-access", ExpiresIn: 3600})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTokenUser(expiresAt sql.NullTime) *store.User {
+	return &store.User{
+		ID:                    uuid.New(),
+		SpotifyAccessToken:    sql.NullString{String: "old-access", Valid: true},
+		SpotifyRefreshToken:   sql.NullString{String: "old-refresh", Valid: true},
+		SpotifyTokenExpiresAt: expiresAt,
+	}
+}
+
+func TestTokenSource_AccessToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		expiresAt     sql.NullTime
+		wantToken     string
+		wantRefreshes int
+	}{
+		{
+			name:          "有効期限まで余裕がある",
+			expiresAt:     sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+			wantToken:     "old-access",
+			wantRefreshes: 0,
+		},
+		{
+			name:          "有効期限が近い",
+			expiresAt:     sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true},
+			wantToken:     "new-access",
+			wantRefreshes: 1,
+		},
+		{
+			name:          "有効期限切れ",
+			expiresAt:     sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true},
+			wantToken:     "new-access",
+			wantRefreshes: 1,
+		},
+		{
+			name:          "有効期限が不明",
+			expiresAt:     sql.NullTime{},
+			wantToken:     "old-access",
+			wantRefreshes: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refreshes := 0
+			server := newTokenServer(t, &refreshes)
+			tokenStore := &fakeTokenStore{}
+			source := NewTokenSource(NewHTTPClient(WithTokenURL(server.URL)), tokenStore)
+			user := newTokenUser(tt.expiresAt)
+
+			token, err := source.AccessToken(context.Background(), user)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantToken, token)
+			assert.Equal(t, tt.wantRefreshes, refreshes)
+			assert.Equal(t, tt.wantRefreshes, tokenStore.updates)
+		})
+	}
+}
+
+func TestTokenSource_RefreshPersistsTokens(t *testing.T) {
+	refreshes := 0
+	server := newTokenServer(t, &refreshes)
+	tokenStore := &fakeTokenStore{}
+	source := NewTokenSource(NewHTTPClient(WithTokenURL(server.URL)), tokenStore)
+	user := newTokenUser(sql.NullTime{})
+
+	token, err := source.Refresh(context.Background(), user)
+
+	require.NoError(t, err)
+	assert.Equal(t, "new-access", token)
+	// レスポンスにリフレッシュトークンが含まれない場合は元のトークンを保持する
+	assert.Equal(t, "old-refresh", tokenStore.refreshToken)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), tokenStore.expiresAt, time.Minute)
+	assert.Equal(t, "new-access", user.SpotifyAccessToken.String)
+	assert.Equal(t, tokenStore.expiresAt, user.SpotifyTokenExpiresAt.Time)
+}
+
+func TestTokenSource_Errors(t *testing.T) {
+	source := NewTokenSource(NewHTTPClient(), &fakeTokenStore{})
+
+	_, err := source.AccessToken(context.Background(), &store.User{})
+	assert.ErrorIs(t, err, ErrNotConnected)
+
+	user := newTokenUser(sql.NullTime{Time: time.Now(), Valid: true})
+	user.SpotifyRefreshToken = sql.NullString{}
+	_, err = source.AccessToken(context.Background(), user)
+	assert.ErrorIs(t, err, ErrNoRefreshToken)
+}
+
+// fakeLockingTokenStore はRefreshLockerを実装するテスト用の保存先
+type fakeLockingTokenStore struct {
+	fakeTokenStore
+	mu     sync.Mutex
+	stored *store.User
+	locks  int
+}
+
+func (s *fakeLockingTokenStore) WithRefreshLock(ctx context.Context, platform string, userID uuid.UUID, fn func(ctx context.Context) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks++
+	return fn(ctx)
+}
+
+func (s *fakeLockingTokenStore) GetUserByID(ctx context.Context, id uuid.UUID) (*store.User, error) {
+	stored := *s.stored
+	return &stored, nil
+}
+
+func TestTokenSource_ReusesTokenRefreshedByAnotherReplica(t *testing.T) {
+	refreshes := 0
+	server := newTokenServer(t, &refreshes)
+	user := newTokenUser(sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true})
+
+	// 他のレプリカが既に更新したトークン
+	stored := newTokenUser(sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true})
+	stored.ID = user.ID
+	stored.SpotifyAccessToken.String = "replica-access"
+	tokenStore := &fakeLockingTokenStore{stored: stored}
+	source := NewTokenSource(NewHTTPClient(WithTokenURL(server.URL)), tokenStore)
+
+	token, err := source.AccessToken(context.Background(), user)
+
+	require.NoError(t, err)
+	assert.Equal(t, "replica-access", token)
+	assert.Equal(t, 1, tokenStore.locks)
+	assert.Equal(t, 0, refreshes)
+	assert.Equal(t, 0, tokenStore.updates)
+	assert.Equal(t, "replica-access", user.SpotifyAccessToken.String)
+}
+
+func TestTokenSource_CoalescesConcurrentRefreshes(t *testing.T) {
+	var refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes.Add(1)
+		// 同時に届いた他の更新要求が待機するまで応答を遅らせる
+		time.Sleep(100 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode(Tokens{AccessToken: "new-access", RefreshToken: "new-refresh", ExpiresIn: 3600})
+	}))
+	defer server.Close()
+
+	userID := uuid.New()
+	source := NewTokenSource(NewHTTPClient(WithTokenURL(server.URL)), &fakeTokenStore{})
+
+	const callers = 5
+	tokens := make([]string, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user := newTokenUser(sql.NullTime{Time: time.Now(), Valid: true})
+			user.ID = userID
+			tokens[i], _ = source.AccessToken(context.Background(), user)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), refreshes.Load())
+	for _, token := range tokens {
+		assert.Equal(t, "new-access", token)
+	}
+}
+
+func TestTokenSource_RefreshOutlivesCanceledCaller(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_ = json.NewEncoder(w).Encode(Tokens{AccessToken: "new-access", RefreshToken: "new-refresh", ExpiresIn: 3600})
+	}))
+	defer server.Close()
+
+	tokenStore := &fakeTokenStore{}
+	source := NewTokenSource(NewHTTPClient(WithTokenURL(server.URL)), tokenStore)
+	first := newTokenUser(sql.NullTime{Time: time.Now(), Valid: true})
+	second := newTokenUser(sql.NullTime{Time: time.Now(), Valid: true})
+	second.ID = first.ID
+
+	// 最初の呼び出し元は更新の途中でキャンセルされる
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := source.Refresh(ctx, first)
+		firstErr <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-firstErr, context.Canceled)
+
+	// 待機中の呼び出し元には更新結果が返り、トークンは保存される
+	secondToken := make(chan string, 1)
+	go func() {
+		token, _ := source.Refresh(context.Background(), second)
+		secondToken <- token
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	assert.Equal(t, "new-access", <-secondToken)
+	assert.Equal(t, 1, tokenStore.updates)
+	assert.Equal(t, "new-refresh", tokenStore.refreshToken)
+	assert.Equal(t, "old-access", first.SpotifyAccessToken.String)
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AutoPostSettings represents the auto-post settings of a user
+type AutoPostSettings struct {
+	UserID       uuid.UUID
+	Enabled      bool
+	Target       string
+	LastItemURI  sql.NullString
+	LastPostedAt sql.NullTime
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// GetAutoPostSettings retrieves the auto-post settings for a user
+func (s *Store) GetAutoPostSettings(ctx context.Context, userID uuid.UUID) (*AutoPostSettings, error) {
+	settings := &AutoPostSettings{}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id, enabled, target, last_item_uri, last_posted_at, created_at, updated_at
+		FROM autopost_settings WHERE user_id = $1
+	`, userID).Scan(
+		&settings.UserID, &settings.Enabled, &settings.Target, &settings.LastItemURI,
+		&settings.LastPostedAt, &settings.CreatedAt, &settings.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get autopost settings: %w", err)
+	}
+	return settings, nil
+}
+
+// UpsertAutoPostSettings creates or updates the auto-post settings for a user
+func (s *Store) UpsertAutoPostSettings(ctx context.Context, userID uuid.UUID, enabled bool, target string) (*AutoPostSettings, error) {
+	settings := &AutoPostSettings{}
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO autopost_settings (user_id, enabled, target)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			target = EXCLUDED.target,
+			updated_at = NOW()
+		RETURNING user_id, enabled, target, last_item_uri, last_posted_at, created_at, updated_at
+	`, userID, enabled, target).Scan(
+		&settings.UserID, &settings.Enabled, &settings.Target, &settings.LastItemURI,
+		&settings.LastPostedAt, &settings.CreatedAt, &settings.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert autopost settings: %w", err)
+	}
+	return settings, nil
+}
+
+// ListEnabledAutoPostSettings returns the auto-post settings of all users with auto-post enabled
+func (s *Store) ListEnabledAutoPostSettings(ctx context.Context) ([]AutoPostSettings, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, enabled, target, last_item_uri, last_posted_at, created_at, updated_at
+		FROM autopost_settings WHERE enabled
+		ORDER BY user_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list autopost settings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []AutoPostSettings
+	for rows.Next() {
+		var settings AutoPostSettings
+		if err := rows.Scan(
+			&settings.UserID, &settings.Enabled, &settings.Target, &settings.LastItemURI,
+			&settings.LastPostedAt, &settings.CreatedAt, &settings.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan autopost settings: %w", err)
+		}
+		list = append(list, settings)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list autopost settings: %w", err)
+	}
+	return list, nil
+}
+
+// ClaimAutoPostItem claims itemURI for posting by the scheduler for the lease duration.
+// It returns false if the item was already posted or another claim has not expired, so that
+// only one scheduler (even across replicas) posts a given track change. The claim must be
+// completed with CompleteAutoPostItem once posted, or released with ReleaseAutoPostItem.
+func (s *Store) ClaimAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string, lease time.Duration) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE autopost_settings SET
+			pending_item_uri = $2,
+			pending_until = NOW() + make_interval(secs => $3),
+			updated_at = NOW()
+		WHERE user_id = $1 AND enabled AND last_item_uri IS DISTINCT FROM $2
+			AND (pending_until IS NULL OR pending_until < NOW())
+	`, userID, itemURI, lease.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim autopost item: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim autopost item: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// CompleteAutoPostItem records a claimed item as the last auto-posted item for a user
+func (s *Store) CompleteAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE autopost_settings SET
+			last_item_uri = pending_item_uri,
+			last_posted_at = NOW(),
+			pending_item_uri = NULL,
+			pending_until = NULL,
+			updated_at = NOW()
+		WHERE user_id = $1 AND pending_item_uri = $2
+	`, userID, itemURI)
+	if err != nil {
+		return fmt.Errorf("failed to complete autopost item: %w", err)
+	}
+	return nil
+}
+
+// SkipAutoPostItem records a claimed item that failed permanently as handled, without updating
+// last_posted_at, so that it is not posted again while it keeps playing
+func (s *Store) SkipAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE autopost_settings SET
+			last_item_uri = pending_item_uri,
+			pending_item_uri = NULL,
+			pending_until = NULL,
+			updated_at = NOW()
+		WHERE user_id = $1 AND pending_item_uri = $2
+	`, userID, itemURI)
+	if err != nil {
+		return fmt.Errorf("failed to skip autopost item: %w", err)
+	}
+	return nil
+}
+
+// ReleaseAutoPostItem releases a claimed item that could not be posted, so that it is tried again
+func (s *Store) ReleaseAutoPostItem(ctx context.Context, userID uuid.UUID, itemURI string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE autopost_settings SET
+			pending_item_uri = NULL,
+			pending_until = NULL,
+			updated_at = NOW()
+		WHERE user_id = $1 AND pending_item_uri = $2
+	`, userID, itemURI)
+	if err != nil {
+		return fmt.Errorf("failed to release autopost item: %w", err)
+	}
+	return nil
+}
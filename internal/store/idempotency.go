@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyRecord is the stored outcome of a request sent with an Idempotency-Key
+type IdempotencyRecord struct {
+	UserID       uuid.UUID
+	Key          string
+	RequestHash  string
+	StatusCode   sql.NullInt32 // NULL while the first request is in progress
+	ResponseBody []byte
+	// LockedUntil is when an in-progress reservation may be taken over by a retry
+	LockedUntil sql.NullTime
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Completed reports whether the first request has stored its response
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode.Valid
+}
+
+// ReserveIdempotencyKey claims the key for a new request for the lease duration. If the key is
+// already in use (and not expired), the existing record is returned and nothing is reserved.
+// An in-progress reservation whose lease has passed (e.g. the process crashed while posting)
+// is taken over by a request with the same parameters.
+func (s *Store) ReserveIdempotencyKey(ctx context.Context, userID uuid.UUID, key, requestHash string, ttl, lease time.Duration) (*IdempotencyRecord, error) {
+	// Expired keys can be reused, so drop the user's expired keys first
+	if _, err := s.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys WHERE user_id = $1 AND expires_at < NOW()
+	`, userID); err != nil {
+		return nil, fmt.Errorf("failed to cleanup idempotency keys: %w", err)
+	}
+
+	now := time.Now()
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, locked_until, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE SET
+			locked_until = EXCLUDED.locked_until,
+			expires_at = EXCLUDED.expires_at,
+			created_at = NOW()
+		WHERE idempotency_keys.status_code IS NULL
+			AND idempotency_keys.request_hash = EXCLUDED.request_hash
+			AND (idempotency_keys.locked_until IS NULL OR idempotency_keys.locked_until < NOW())
+	`, userID, key, requestHash, now.Add(lease), now.Add(ttl))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 1 {
+		return nil, nil
+	}
+
+	record := &IdempotencyRecord{}
+	err = s.db.QueryRowContext(ctx, `
+		SELECT user_id, idempotency_key, request_hash, status_code, response_body, locked_until, created_at, expires_at
+		FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2
+	`, userID, key).Scan(
+		&record.UserID, &record.Key, &record.RequestHash, &record.StatusCode,
+		&record.ResponseBody, &record.LockedUntil, &record.CreatedAt, &record.ExpiresAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	return record, nil
+}
+
+// CompleteIdempotencyKey stores the response of the request that reserved the key
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, statusCode int, responseBody []byte) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE idempotency_keys SET
+			status_code = $3,
+			response_body = $4,
+			locked_until = NULL
+		WHERE user_id = $1 AND idempotency_key = $2
+	`, userID, key, statusCode, string(responseBody))
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey deletes a reserved key so that the request can be retried
+func (s *Store) ReleaseIdempotencyKey(ctx context.Context, userID uuid.UUID, key string) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2
+	`, userID, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Soli0222/spotify-nowplaying/internal/crypto"
+	"github.com/google/uuid"
+)
+
+// MastodonApp represents an OAuth app registered on a Mastodon-compatible instance
+type MastodonApp struct {
+	InstanceURL  string
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	CreatedAt    time.Time
+}
+
+// MastodonOAuthSession represents a Mastodon OAuth session
+type MastodonOAuthSession struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	State       string
+	InstanceURL string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// GetMastodonApp retrieves the OAuth app registered on an instance
+func (s *Store) GetMastodonApp(ctx context.Context, instanceURL string) (*MastodonApp, error) {
+	app := &MastodonApp{}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT instance_url, client_id, client_secret, redirect_uri, created_at
+		FROM mastodon_apps WHERE instance_url = $1
+	`, instanceURL).Scan(&app.InstanceURL, &app.ClientID, &app.ClientSecret, &app.RedirectURI, &app.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get mastodon app: %w", err)
+	}
+
+	decrypted, err := crypto.DecryptToken(app.ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt mastodon client secret: %w", err)
+	}
+	app.ClientSecret = decrypted
+
+	return app, nil
+}
+
+// SaveMastodonApp stores the OAuth app registered on an instance and returns the
+// app that ended up stored. A concurrent registration that already stored an app
+// with the same redirect URI wins; the existing row is only replaced when the
+// redirect URI has changed.
+func (s *Store) SaveMastodonApp(ctx context.Context, app *MastodonApp) (*MastodonApp, error) {
+	encClientSecret, err := crypto.EncryptToken(app.ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt mastodon client secret: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO mastodon_apps (instance_url, client_id, client_secret, redirect_uri)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (instance_url) DO UPDATE SET
+			client_id = EXCLUDED.client_id,
+			client_secret = EXCLUDED.client_secret,
+			redirect_uri = EXCLUDED.redirect_uri,
+			created_at = NOW()
+		WHERE mastodon_apps.redirect_uri IS DISTINCT FROM EXCLUDED.redirect_uri
+	`, app.InstanceURL, app.ClientID, encClientSecret, app.RedirectURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save mastodon app: %w", err)
+	}
+
+	stored, err := s.GetMastodonApp(ctx, app.InstanceURL)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("failed to save mastodon app: no row for %s", app.InstanceURL)
+	}
+	return stored, nil
+}
+
+// CreateMastodonOAuthSession creates a new Mastodon OAuth session
+func (s *Store) CreateMastodonOAuthSession(ctx context.Context, userID uuid.UUID, state, instanceURL string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO mastodon_oauth_sessions (user_id, state, instance_url)
+		VALUES ($1, $2, $3)
+	`, userID, state, instanceURL)
+	if err != nil {
+		return fmt.Errorf("failed to create mastodon oauth session: %w", err)
+	}
+	return nil
+}
+
+// GetMastodonOAuthSession retrieves a Mastodon OAuth session by state
+func (s *Store) GetMastodonOAuthSession(ctx context.Context, state string) (*MastodonOAuthSession, error) {
+	session := &MastodonOAuthSession{}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, state, instance_url, created_at, expires_at
+		FROM mastodon_oauth_sessions WHERE state = $1 AND expires_at > NOW()
+	`, state).Scan(
+		&session.ID, &session.UserID, &session.State, &session.InstanceURL,
+		&session.CreatedAt, &session.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get mastodon oauth session: %w", err)
+	}
+	return session, nil
+}
+
+// DeleteMastodonOAuthSession deletes a Mastodon OAuth session
+func (s *Store) DeleteMastodonOAuthSession(ctx context.Context, state string) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM mastodon_oauth_sessions WHERE state = $1
+	`, state)
+	if err != nil {
+		return fmt.Errorf("failed to delete mastodon oauth session: %w", err)
+	}
+	return nil
+}
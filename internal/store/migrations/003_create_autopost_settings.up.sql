@@ -0,0 +1,15 @@
+-- Auto-post settings for the background scheduler
+CREATE TABLE IF NOT EXISTS autopost_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    target VARCHAR(32) NOT NULL DEFAULT 'both',
+
+    -- Last Spotify item posted by the scheduler (used for track change detection)
+    last_item_uri VARCHAR(255),
+    last_posted_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_autopost_settings_enabled ON autopost_settings(enabled) WHERE enabled;
@@ -0,0 +1,10 @@
+-- User-defined post templates
+-- platform is 'default' (applies to every platform) or a platform name such as 'misskey' or 'twitter'
+CREATE TABLE IF NOT EXISTS post_templates (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    platform VARCHAR(32) NOT NULL,
+    template TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, platform)
+);
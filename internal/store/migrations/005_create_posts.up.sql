@@ -0,0 +1,18 @@
+-- Post history: one row per posting attempt per platform
+CREATE TABLE IF NOT EXISTS posts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    platform VARCHAR(32) NOT NULL,
+    item_uri VARCHAR(255),
+    text TEXT NOT NULL,
+
+    -- ID of the created note/tweet on the remote platform
+    remote_id VARCHAR(255),
+
+    status VARCHAR(32) NOT NULL,  -- 'success' or 'failed'
+    error TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_posts_user_id_created_at ON posts(user_id, created_at DESC);
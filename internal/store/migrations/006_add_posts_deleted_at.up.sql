@@ -0,0 +1,2 @@
+-- Track posts deleted from the remote platform
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,10 @@
+-- Per-user posting preferences
+CREATE TABLE IF NOT EXISTS post_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+
+    -- Attach album/show artwork to posts
+    attach_media BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
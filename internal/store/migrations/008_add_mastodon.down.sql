@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS mastodon_oauth_sessions;
+DROP TABLE IF EXISTS mastodon_apps;
+
+ALTER TABLE users DROP COLUMN IF EXISTS mastodon_host;
+ALTER TABLE users DROP COLUMN IF EXISTS mastodon_avatar_url;
+ALTER TABLE users DROP COLUMN IF EXISTS mastodon_username;
+ALTER TABLE users DROP COLUMN IF EXISTS mastodon_user_id;
+ALTER TABLE users DROP COLUMN IF EXISTS mastodon_access_token;
+ALTER TABLE users DROP COLUMN IF EXISTS mastodon_instance_url;
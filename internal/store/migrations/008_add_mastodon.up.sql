@@ -0,0 +1,28 @@
+-- Mastodon-compatible integration (OAuth 2.0 authorization code flow)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mastodon_instance_url VARCHAR(512);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mastodon_access_token TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mastodon_user_id VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mastodon_username VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mastodon_avatar_url TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mastodon_host VARCHAR(512);
+
+-- OAuth apps registered on each instance (shared by all users of the instance)
+CREATE TABLE IF NOT EXISTS mastodon_apps (
+    instance_url VARCHAR(512) PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    client_secret TEXT NOT NULL,  -- encrypted
+    redirect_uri TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Mastodon OAuth sessions table for tracking ongoing authentications
+CREATE TABLE IF NOT EXISTS mastodon_oauth_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    state VARCHAR(64) NOT NULL,
+    instance_url VARCHAR(512) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() + INTERVAL '10 minutes'
+);
+
+CREATE INDEX IF NOT EXISTS idx_mastodon_oauth_sessions_state ON mastodon_oauth_sessions(state);
@@ -0,0 +1,6 @@
+ALTER TABLE users DROP COLUMN IF EXISTS bluesky_refresh_jwt;
+ALTER TABLE users DROP COLUMN IF EXISTS bluesky_access_jwt;
+ALTER TABLE users DROP COLUMN IF EXISTS bluesky_app_password;
+ALTER TABLE users DROP COLUMN IF EXISTS bluesky_did;
+ALTER TABLE users DROP COLUMN IF EXISTS bluesky_handle;
+ALTER TABLE users DROP COLUMN IF EXISTS bluesky_pds_url;
@@ -0,0 +1,7 @@
+-- Bluesky (AT Protocol) integration using app passwords
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bluesky_pds_url VARCHAR(512);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bluesky_handle VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bluesky_did VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bluesky_app_password TEXT;  -- encrypted
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bluesky_access_jwt TEXT;    -- encrypted
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bluesky_refresh_jwt TEXT;   -- encrypted
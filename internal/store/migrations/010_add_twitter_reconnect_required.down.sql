@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN IF EXISTS twitter_reconnect_required;
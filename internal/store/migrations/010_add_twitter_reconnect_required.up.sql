@@ -0,0 +1,2 @@
+-- Set when the Twitter refresh token is rejected and the user must reconnect
+ALTER TABLE users ADD COLUMN IF NOT EXISTS twitter_reconnect_required BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_posts_user_platform_item;
+ALTER TABLE post_settings DROP COLUMN IF EXISTS dedupe_window_minutes;
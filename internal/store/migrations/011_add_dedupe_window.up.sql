@@ -0,0 +1,4 @@
+-- Skip reposting the same item to the same platform within this many minutes (0 = disabled)
+ALTER TABLE post_settings ADD COLUMN IF NOT EXISTS dedupe_window_minutes INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_posts_user_platform_item ON posts(user_id, platform, item_uri, created_at DESC);
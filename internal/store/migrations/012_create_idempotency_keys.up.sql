@@ -0,0 +1,19 @@
+-- Responses of API post requests sent with an Idempotency-Key header, replayed for retries
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    idempotency_key VARCHAR(255) NOT NULL,
+
+    -- SHA-256 of the request parameters, used to detect a key reused for a different request
+    request_hash VARCHAR(64) NOT NULL,
+
+    -- NULL while the first request is still in progress
+    status_code INTEGER,
+    response_body TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    PRIMARY KEY (user_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_users_api_header_token_hash;
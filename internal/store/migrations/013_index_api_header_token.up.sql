@@ -0,0 +1,2 @@
+-- POST /api/post looks users up by their header token alone
+CREATE INDEX IF NOT EXISTS idx_users_api_header_token_hash ON users(api_header_token_hash) WHERE api_header_token_enabled;
@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN IF EXISTS misskey_reaction_acceptance;
+ALTER TABLE users DROP COLUMN IF EXISTS misskey_channel_id;
+ALTER TABLE users DROP COLUMN IF EXISTS misskey_local_only;
+ALTER TABLE users DROP COLUMN IF EXISTS misskey_cw;
+ALTER TABLE users DROP COLUMN IF EXISTS misskey_visibility;
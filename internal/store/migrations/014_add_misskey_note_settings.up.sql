@@ -0,0 +1,6 @@
+-- Default options for Misskey notes, stored with the Misskey connection
+ALTER TABLE users ADD COLUMN IF NOT EXISTS misskey_visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS misskey_cw TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS misskey_local_only BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS misskey_channel_id TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS misskey_reaction_acceptance TEXT;
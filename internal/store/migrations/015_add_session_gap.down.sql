@@ -0,0 +1 @@
+ALTER TABLE post_settings DROP COLUMN IF EXISTS session_gap_minutes;
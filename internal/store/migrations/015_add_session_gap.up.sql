@@ -0,0 +1,2 @@
+-- Post as a reply to the previous post when it was made within this many minutes (0 = disabled)
+ALTER TABLE post_settings ADD COLUMN IF NOT EXISTS session_gap_minutes INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS post_outbox;
+ALTER TABLE posts DROP COLUMN IF EXISTS next_retry_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,23 @@
+-- Number of times the post was sent, and when a queued post will be retried
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS next_retry_at TIMESTAMP WITH TIME ZONE;
+
+-- Posts that failed transiently and are waiting to be retried by the outbox worker
+CREATE TABLE IF NOT EXISTS post_outbox (
+    post_id UUID PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    platform VARCHAR(32) NOT NULL,
+
+    -- The post request to resend (text, options, artwork URL)
+    payload JSONB NOT NULL,
+
+    attempts INTEGER NOT NULL DEFAULT 1,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    -- Set while a worker is sending the post, so that other replicas skip it
+    locked_until TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_outbox_next_attempt_at ON post_outbox(next_attempt_at);
@@ -0,0 +1,2 @@
+ALTER TABLE post_settings DROP COLUMN IF EXISTS post_local_files;
+ALTER TABLE post_settings DROP COLUMN IF EXISTS post_paused;
@@ -0,0 +1,3 @@
+-- Whether paused playback and local files may be posted
+ALTER TABLE post_settings ADD COLUMN IF NOT EXISTS post_paused BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE post_settings ADD COLUMN IF NOT EXISTS post_local_files BOOLEAN NOT NULL DEFAULT FALSE;
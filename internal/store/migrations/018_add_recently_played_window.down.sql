@@ -0,0 +1 @@
+ALTER TABLE post_settings DROP COLUMN IF EXISTS recently_played_window_minutes;
@@ -0,0 +1,2 @@
+-- Post the last played track when nothing is playing and it ended within this many minutes (0 = disabled)
+ALTER TABLE post_settings ADD COLUMN IF NOT EXISTS recently_played_window_minutes INTEGER NOT NULL DEFAULT 0;
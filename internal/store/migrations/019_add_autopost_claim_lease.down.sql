@@ -0,0 +1,2 @@
+ALTER TABLE autopost_settings DROP COLUMN IF EXISTS pending_until;
+ALTER TABLE autopost_settings DROP COLUMN IF EXISTS pending_item_uri;
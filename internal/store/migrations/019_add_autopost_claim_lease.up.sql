@@ -0,0 +1,3 @@
+-- Item being auto-posted and when its claim expires; last_item_uri is only set once a post succeeds
+ALTER TABLE autopost_settings ADD COLUMN IF NOT EXISTS pending_item_uri VARCHAR(255);
+ALTER TABLE autopost_settings ADD COLUMN IF NOT EXISTS pending_until TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,3 @@
+-- Until when the request that reserved the key is considered in progress; a reservation whose
+-- request crashed is taken over by a retry once this has passed (NULL for completed keys)
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
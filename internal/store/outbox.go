@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEntry is a post queued for retry after a transient failure
+type OutboxEntry struct {
+	PostID   uuid.UUID
+	UserID   uuid.UUID
+	Platform string
+	// Payload is the JSON-encoded post request to resend
+	Payload       []byte
+	Attempts      int
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
+
+// EnqueuePost records a failed post in the history with the retrying status and queues it
+// to be sent again at nextAttemptAt. post.ID and post.CreatedAt are set on success.
+func (s *Store) EnqueuePost(ctx context.Context, post *Post, payload []byte, nextAttemptAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin enqueue transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	post.Status = PostStatusRetrying
+	post.Attempts = 1
+	post.NextRetryAt = sql.NullTime{Time: nextAttemptAt, Valid: true}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO posts (user_id, platform, item_uri, text, status, error, attempts, next_retry_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, post.UserID, post.Platform, post.ItemURI, post.Text, post.Status, post.Error, post.Attempts, post.NextRetryAt).Scan(
+		&post.ID, &post.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create post: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO post_outbox (post_id, user_id, platform, payload, attempts, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, post.ID, post.UserID, post.Platform, string(payload), post.Attempts, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue post: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit enqueue transaction: %w", err)
+	}
+	return nil
+}
+
+// ClaimOutboxEntries leases up to limit entries that are due for retry, so that other
+// replicas skip them until the lease expires or the entry is completed, rescheduled or failed
+func (s *Store) ClaimOutboxEntries(ctx context.Context, limit int, lease time.Duration) ([]OutboxEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE post_outbox SET locked_until = NOW() + make_interval(secs => $2)
+		WHERE post_id IN (
+			SELECT post_id FROM post_outbox
+			WHERE next_attempt_at <= NOW() AND (locked_until IS NULL OR locked_until <= NOW())
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING post_id, user_id, platform, payload, attempts, next_attempt_at, created_at
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox entries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	entries := []OutboxEntry{}
+	for rows.Next() {
+		var entry OutboxEntry
+		if err := rows.Scan(
+			&entry.PostID, &entry.UserID, &entry.Platform, &entry.Payload,
+			&entry.Attempts, &entry.NextAttemptAt, &entry.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim outbox entries: %w", err)
+	}
+	return entries, nil
+}
+
+// CompleteOutboxEntry marks the queued post as successful after the given number of attempts
+// and removes it from the outbox
+func (s *Store) CompleteOutboxEntry(ctx context.Context, postID uuid.UUID, attempts int, remoteID string) error {
+	return s.finishOutboxEntry(ctx, postID, attempts, PostStatusSuccess, sql.NullString{String: remoteID, Valid: remoteID != ""}, sql.NullString{})
+}
+
+// FailOutboxEntry marks the queued post as failed after the given number of attempts
+// and removes it from the outbox
+func (s *Store) FailOutboxEntry(ctx context.Context, postID uuid.UUID, attempts int, lastError string) error {
+	return s.finishOutboxEntry(ctx, postID, attempts, PostStatusFailed, sql.NullString{}, sql.NullString{String: lastError, Valid: true})
+}
+
+// finishOutboxEntry records the final status of a queued post and removes it from the outbox
+func (s *Store) finishOutboxEntry(ctx context.Context, postID uuid.UUID, attempts int, status string, remoteID, lastError sql.NullString) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin outbox transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE posts SET
+			status = $2,
+			remote_id = $3,
+			error = $4,
+			attempts = $5,
+			next_retry_at = NULL
+		WHERE id = $1
+	`, postID, status, remoteID, lastError, attempts)
+	if err != nil {
+		return fmt.Errorf("failed to update post: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_outbox WHERE post_id = $1`, postID); err != nil {
+		return fmt.Errorf("failed to delete outbox entry: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit outbox transaction: %w", err)
+	}
+	return nil
+}
+
+// RescheduleOutboxEntry records another failed attempt of a queued post and releases it
+// to be retried at nextAttemptAt
+func (s *Store) RescheduleOutboxEntry(ctx context.Context, postID uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin outbox transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE posts SET
+			error = $2,
+			attempts = $3,
+			next_retry_at = $4
+		WHERE id = $1
+	`, postID, lastError, attempts, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("failed to update post: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE post_outbox SET
+			attempts = $2,
+			next_attempt_at = $3,
+			locked_until = NULL
+		WHERE post_id = $1
+	`, postID, attempts, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule outbox entry: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit outbox transaction: %w", err)
+	}
+	return nil
+}
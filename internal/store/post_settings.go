@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PostSettings represents a user's posting preferences
+type PostSettings struct {
+	UserID      uuid.UUID
+	AttachMedia bool
+	// DedupeWindowMinutes skips reposting the same item to a platform within the window (0 = disabled)
+	DedupeWindowMinutes int
+	// SessionGapMinutes threads a post as a reply to the previous post on the platform when it
+	// was made within this many minutes (0 = disabled)
+	SessionGapMinutes int
+	// PostPaused allows posting an item while playback is paused
+	PostPaused bool
+	// PostLocalFiles allows posting local files, which have no Spotify URL or artwork
+	PostLocalFiles bool
+	// RecentlyPlayedWindowMinutes posts the last played track when nothing is playing and it
+	// ended within this many minutes (0 = disabled)
+	RecentlyPlayedWindowMinutes int
+}
+
+// MaxDedupeWindowMinutes is the longest allowed dedupe window (one day)
+const MaxDedupeWindowMinutes = 24 * 60
+
+// MaxSessionGapMinutes is the longest allowed idle gap between posts of a session (half a day)
+const MaxSessionGapMinutes = 12 * 60
+
+// MaxRecentlyPlayedWindowMinutes is the longest allowed recently played fallback window (one hour)
+const MaxRecentlyPlayedWindowMinutes = 60
+
+// DedupeWindow returns the dedupe window as a duration
+func (p *PostSettings) DedupeWindow() time.Duration {
+	return time.Duration(p.DedupeWindowMinutes) * time.Minute
+}
+
+// SessionGap returns the session idle gap as a duration
+func (p *PostSettings) SessionGap() time.Duration {
+	return time.Duration(p.SessionGapMinutes) * time.Minute
+}
+
+// RecentlyPlayedWindow returns the recently played fallback window as a duration
+func (p *PostSettings) RecentlyPlayedWindow() time.Duration {
+	return time.Duration(p.RecentlyPlayedWindowMinutes) * time.Minute
+}
+
+// DefaultPostSettings returns the settings used when a user has not saved any
+func DefaultPostSettings(userID uuid.UUID) *PostSettings {
+	return &PostSettings{
+		UserID:                      userID,
+		AttachMedia:                 false,
+		DedupeWindowMinutes:         0,
+		SessionGapMinutes:           0,
+		PostPaused:                  true,
+		PostLocalFiles:              false,
+		RecentlyPlayedWindowMinutes: 0,
+	}
+}
+
+// GetPostSettings retrieves the posting preferences for a user, or the defaults if none are saved
+func (s *Store) GetPostSettings(ctx context.Context, userID uuid.UUID) (*PostSettings, error) {
+	settings := DefaultPostSettings(userID)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT attach_media, dedupe_window_minutes, session_gap_minutes, post_paused, post_local_files,
+			recently_played_window_minutes
+		FROM post_settings WHERE user_id = $1
+	`, userID).Scan(
+		&settings.AttachMedia, &settings.DedupeWindowMinutes, &settings.SessionGapMinutes,
+		&settings.PostPaused, &settings.PostLocalFiles, &settings.RecentlyPlayedWindowMinutes,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return settings, nil
+		}
+		return nil, fmt.Errorf("failed to get post settings: %w", err)
+	}
+	return settings, nil
+}
+
+// UpsertPostSettings creates or updates the posting preferences for a user
+func (s *Store) UpsertPostSettings(ctx context.Context, settings *PostSettings) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO post_settings (
+			user_id, attach_media, dedupe_window_minutes, session_gap_minutes, post_paused, post_local_files,
+			recently_played_window_minutes
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE SET
+			attach_media = EXCLUDED.attach_media,
+			dedupe_window_minutes = EXCLUDED.dedupe_window_minutes,
+			session_gap_minutes = EXCLUDED.session_gap_minutes,
+			post_paused = EXCLUDED.post_paused,
+			post_local_files = EXCLUDED.post_local_files,
+			recently_played_window_minutes = EXCLUDED.recently_played_window_minutes,
+			updated_at = NOW()
+	`, settings.UserID, settings.AttachMedia, settings.DedupeWindowMinutes, settings.SessionGapMinutes,
+		settings.PostPaused, settings.PostLocalFiles, settings.RecentlyPlayedWindowMinutes)
+	if err != nil {
+		return fmt.Errorf("failed to upsert post settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Post statuses
+const (
+	PostStatusSuccess  = "success"
+	PostStatusFailed   = "failed"
+	PostStatusDeleted  = "deleted"
+	PostStatusRetrying = "retrying" // queued in the outbox after a transient failure
+)
+
+// Post represents a posting attempt to a platform
+type Post struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Platform  string
+	ItemURI   sql.NullString
+	Text      string
+	RemoteID  sql.NullString
+	Status    string
+	Error     sql.NullString
+	CreatedAt time.Time
+	DeletedAt sql.NullTime
+	// Attempts is the number of times the post was sent to the platform
+	Attempts int
+	// NextRetryAt is when a retrying post will be sent again
+	NextRetryAt sql.NullTime
+}
+
+// PostFilter filters and paginates post history
+type PostFilter struct {
+	Platform string
+	Status   string
+	Limit    int
+	Offset   int
+}
+
+const postColumns = `id, user_id, platform, item_uri, text, remote_id, status, error, created_at, deleted_at, attempts, next_retry_at`
+
+func scanPost(row interface{ Scan(...any) error }, post *Post) error {
+	return row.Scan(
+		&post.ID, &post.UserID, &post.Platform, &post.ItemURI, &post.Text,
+		&post.RemoteID, &post.Status, &post.Error, &post.CreatedAt, &post.DeletedAt,
+		&post.Attempts, &post.NextRetryAt,
+	)
+}
+
+// CreatePost records a posting attempt
+func (s *Store) CreatePost(ctx context.Context, post *Post) error {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO posts (user_id, platform, item_uri, text, remote_id, status, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, post.UserID, post.Platform, post.ItemURI, post.Text, post.RemoteID, post.Status, post.Error).Scan(
+		&post.ID, &post.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create post: %w", err)
+	}
+	return nil
+}
+
+// HasRecentPost reports whether the item was successfully posted (or is queued to be posted)
+// to the platform since the given time
+func (s *Store) HasRecentPost(ctx context.Context, userID uuid.UUID, platform, itemURI string, since time.Time) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM posts
+			WHERE user_id = $1 AND platform = $2 AND item_uri = $3
+				AND status IN ($4, $5) AND created_at >= $6
+		)
+	`, userID, platform, itemURI, PostStatusSuccess, PostStatusRetrying, since).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check recent posts: %w", err)
+	}
+	return exists, nil
+}
+
+// GetLatestPost returns the user's most recent successful post to the platform since the given
+// time that has a remote ID, or nil if there is none
+func (s *Store) GetLatestPost(ctx context.Context, userID uuid.UUID, platform string, since time.Time) (*Post, error) {
+	post := &Post{}
+	err := scanPost(s.db.QueryRowContext(ctx, `
+		SELECT `+postColumns+` FROM posts
+		WHERE user_id = $1 AND platform = $2 AND status = $3
+			AND remote_id IS NOT NULL AND created_at >= $4
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, userID, platform, PostStatusSuccess, since), post)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get latest post: %w", err)
+	}
+	return post, nil
+}
+
+// ListPosts returns the user's post history (newest first) and the total number of matching posts
+func (s *Store) ListPosts(ctx context.Context, userID uuid.UUID, filter PostFilter) ([]Post, int, error) {
+	conditions := []string{"user_id = $1"}
+	args := []any{userID}
+	if filter.Platform != "" {
+		args = append(args, filter.Platform)
+		conditions = append(conditions, fmt.Sprintf("platform = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM posts WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count posts: %w", err)
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s FROM posts WHERE %s
+		ORDER BY created_at DESC, id
+		LIMIT $%d OFFSET $%d
+	`, postColumns, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list posts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	posts := []Post{}
+	for rows.Next() {
+		var post Post
+		if err := scanPost(rows, &post); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan post: %w", err)
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list posts: %w", err)
+	}
+	return posts, total, nil
+}
+
+// GetPost retrieves a post owned by the user
+func (s *Store) GetPost(ctx context.Context, userID, postID uuid.UUID) (*Post, error) {
+	post := &Post{}
+	err := scanPost(s.db.QueryRowContext(ctx, `
+		SELECT `+postColumns+` FROM posts WHERE id = $1 AND user_id = $2
+	`, postID, userID), post)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+	return post, nil
+}
+
+// MarkPostDeleted records that a post was deleted from the remote platform
+func (s *Store) MarkPostDeleted(ctx context.Context, postID uuid.UUID) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE posts SET
+			status = $2,
+			deleted_at = NOW()
+		WHERE id = $1
+	`, postID, PostStatusDeleted)
+	if err != nil {
+		return fmt.Errorf("failed to mark post deleted: %w", err)
+	}
+	return nil
+}
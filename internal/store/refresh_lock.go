@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/google/uuid"
+)
+
+// refreshLockKey derives the advisory lock key for a user's token refresh on a platform
+func refreshLockKey(platform string, userID uuid.UUID) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("token-refresh:" + platform + ":"))
+	_, _ = h.Write(userID[:])
+	return int64(h.Sum64())
+}
+
+// WithRefreshLock runs fn while holding a transaction-scoped Postgres advisory lock
+// for the user's token refresh on the platform, so that only one replica refreshes at a time.
+// fn should re-read the stored tokens, as another replica may have refreshed them while waiting.
+func (s *Store) WithRefreshLock(ctx context.Context, platform string, userID uuid.UUID, fn func(ctx context.Context) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin refresh lock transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, refreshLockKey(platform, userID)); err != nil {
+		return fmt.Errorf("failed to acquire refresh lock: %w", err)
+	}
+
+	if err := fn(ctx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to release refresh lock: %w", err)
+	}
+	return nil
+}
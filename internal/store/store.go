@@ -24,17 +24,51 @@ type User struct {
 	MisskeyUsername       sql.NullString
 	MisskeyAvatarURL      sql.NullString
 	MisskeyHost           sql.NullString
+	// MisskeyNote holds the user's default options for Misskey notes
+	MisskeyNote           MisskeyNoteSettings
 	TwitterAccessToken    sql.NullString
 	TwitterRefreshToken   sql.NullString
 	TwitterTokenExpiresAt sql.NullTime
 	TwitterUserID         sql.NullString
 	TwitterUsername       sql.NullString
 	TwitterAvatarURL      sql.NullString
-	APIURLToken           uuid.UUID
-	APIHeaderTokenHash    sql.NullString
-	APIHeaderTokenEnabled bool
-	CreatedAt             time.Time
-	UpdatedAt             time.Time
+	// TwitterReconnectRequired is set when the refresh token was rejected
+	TwitterReconnectRequired bool
+	MastodonInstanceURL      sql.NullString
+	MastodonAccessToken      sql.NullString
+	MastodonUserID           sql.NullString
+	MastodonUsername         sql.NullString
+	MastodonAvatarURL        sql.NullString
+	MastodonHost             sql.NullString
+	BlueskyPDSURL            sql.NullString
+	BlueskyHandle            sql.NullString
+	BlueskyDID               sql.NullString
+	BlueskyAppPassword       sql.NullString
+	BlueskyAccessJwt         sql.NullString
+	BlueskyRefreshJwt        sql.NullString
+	APIURLToken              uuid.UUID
+	APIHeaderTokenHash       sql.NullString
+	APIHeaderTokenEnabled    bool
+	CreatedAt                time.Time
+	UpdatedAt                time.Time
+}
+
+// MisskeyNoteSettings are the user's default options for Misskey notes
+type MisskeyNoteSettings struct {
+	// Visibility is the Misskey visibility: public, home, followers or specified
+	Visibility string
+	// CW is the content warning; the note text is hidden behind it when set
+	CW        sql.NullString
+	LocalOnly bool
+	// ChannelID posts notes to a channel when set
+	ChannelID sql.NullString
+	// ReactionAcceptance restricts reactions; NULL accepts all reactions
+	ReactionAcceptance sql.NullString
+}
+
+// DefaultMisskeyNoteSettings returns the note options used before the user changes them
+func DefaultMisskeyNoteSettings() MisskeyNoteSettings {
+	return MisskeyNoteSettings{Visibility: "public"}
 }
 
 // MiAuthSession represents a MiAuth session
@@ -128,6 +162,38 @@ func decryptUserTokens(user *User) error {
 		user.TwitterRefreshToken.String = decrypted
 	}
 
+	// Decrypt Mastodon token
+	if user.MastodonAccessToken.Valid {
+		decrypted, err := crypto.DecryptToken(user.MastodonAccessToken.String)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt mastodon access token: %w", err)
+		}
+		user.MastodonAccessToken.String = decrypted
+	}
+
+	// Decrypt Bluesky credentials
+	if user.BlueskyAppPassword.Valid {
+		decrypted, err := crypto.DecryptToken(user.BlueskyAppPassword.String)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt bluesky app password: %w", err)
+		}
+		user.BlueskyAppPassword.String = decrypted
+	}
+	if user.BlueskyAccessJwt.Valid {
+		decrypted, err := crypto.DecryptToken(user.BlueskyAccessJwt.String)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt bluesky access jwt: %w", err)
+		}
+		user.BlueskyAccessJwt.String = decrypted
+	}
+	if user.BlueskyRefreshJwt.Valid {
+		decrypted, err := crypto.DecryptToken(user.BlueskyRefreshJwt.String)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt bluesky refresh jwt: %w", err)
+		}
+		user.BlueskyRefreshJwt.String = decrypted
+	}
+
 	return nil
 }
 
@@ -181,8 +247,16 @@ func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
 	err := s.db.QueryRowContext(ctx, `
 		SELECT id, spotify_user_id, spotify_access_token, spotify_refresh_token, spotify_token_expires_at,
 			misskey_instance_url, misskey_access_token, misskey_user_id, misskey_username,
-			misskey_avatar_url, misskey_host, twitter_access_token, twitter_refresh_token,
+			misskey_avatar_url, misskey_host,
+			misskey_visibility, misskey_cw, misskey_local_only, misskey_channel_id,
+			misskey_reaction_acceptance,
+			twitter_access_token, twitter_refresh_token,
 			twitter_token_expires_at, twitter_user_id, twitter_username, twitter_avatar_url,
+			twitter_reconnect_required,
+			mastodon_instance_url, mastodon_access_token, mastodon_user_id, mastodon_username,
+			mastodon_avatar_url, mastodon_host,
+			bluesky_pds_url, bluesky_handle, bluesky_did, bluesky_app_password,
+			bluesky_access_jwt, bluesky_refresh_jwt,
 			api_url_token, api_header_token_hash, api_header_token_enabled,
 			created_at, updated_at
 		FROM users WHERE id = $1
@@ -190,8 +264,15 @@ func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
 		&user.ID, &user.SpotifyUserID, &user.SpotifyAccessToken, &user.SpotifyRefreshToken,
 		&user.SpotifyTokenExpiresAt, &user.MisskeyInstanceURL, &user.MisskeyAccessToken,
 		&user.MisskeyUserID, &user.MisskeyUsername, &user.MisskeyAvatarURL, &user.MisskeyHost,
+		&user.MisskeyNote.Visibility, &user.MisskeyNote.CW, &user.MisskeyNote.LocalOnly,
+		&user.MisskeyNote.ChannelID, &user.MisskeyNote.ReactionAcceptance,
 		&user.TwitterAccessToken, &user.TwitterRefreshToken, &user.TwitterTokenExpiresAt,
 		&user.TwitterUserID, &user.TwitterUsername, &user.TwitterAvatarURL,
+		&user.TwitterReconnectRequired,
+		&user.MastodonInstanceURL, &user.MastodonAccessToken, &user.MastodonUserID,
+		&user.MastodonUsername, &user.MastodonAvatarURL, &user.MastodonHost,
+		&user.BlueskyPDSURL, &user.BlueskyHandle, &user.BlueskyDID, &user.BlueskyAppPassword,
+		&user.BlueskyAccessJwt, &user.BlueskyRefreshJwt,
 		&user.APIURLToken, &user.APIHeaderTokenHash, &user.APIHeaderTokenEnabled,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
@@ -215,8 +296,16 @@ func (s *Store) GetUserBySpotifyID(ctx context.Context, spotifyUserID string) (*
 	err := s.db.QueryRowContext(ctx, `
 		SELECT id, spotify_user_id, spotify_access_token, spotify_refresh_token, spotify_token_expires_at,
 			misskey_instance_url, misskey_access_token, misskey_user_id, misskey_username,
-			misskey_avatar_url, misskey_host, twitter_access_token, twitter_refresh_token,
+			misskey_avatar_url, misskey_host,
+			misskey_visibility, misskey_cw, misskey_local_only, misskey_channel_id,
+			misskey_reaction_acceptance,
+			twitter_access_token, twitter_refresh_token,
 			twitter_token_expires_at, twitter_user_id, twitter_username, twitter_avatar_url,
+			twitter_reconnect_required,
+			mastodon_instance_url, mastodon_access_token, mastodon_user_id, mastodon_username,
+			mastodon_avatar_url, mastodon_host,
+			bluesky_pds_url, bluesky_handle, bluesky_did, bluesky_app_password,
+			bluesky_access_jwt, bluesky_refresh_jwt,
 			api_url_token, api_header_token_hash, api_header_token_enabled,
 			created_at, updated_at
 		FROM users WHERE spotify_user_id = $1
@@ -224,8 +313,15 @@ func (s *Store) GetUserBySpotifyID(ctx context.Context, spotifyUserID string) (*
 		&user.ID, &user.SpotifyUserID, &user.SpotifyAccessToken, &user.SpotifyRefreshToken,
 		&user.SpotifyTokenExpiresAt, &user.MisskeyInstanceURL, &user.MisskeyAccessToken,
 		&user.MisskeyUserID, &user.MisskeyUsername, &user.MisskeyAvatarURL, &user.MisskeyHost,
+		&user.MisskeyNote.Visibility, &user.MisskeyNote.CW, &user.MisskeyNote.LocalOnly,
+		&user.MisskeyNote.ChannelID, &user.MisskeyNote.ReactionAcceptance,
 		&user.TwitterAccessToken, &user.TwitterRefreshToken, &user.TwitterTokenExpiresAt,
 		&user.TwitterUserID, &user.TwitterUsername, &user.TwitterAvatarURL,
+		&user.TwitterReconnectRequired,
+		&user.MastodonInstanceURL, &user.MastodonAccessToken, &user.MastodonUserID,
+		&user.MastodonUsername, &user.MastodonAvatarURL, &user.MastodonHost,
+		&user.BlueskyPDSURL, &user.BlueskyHandle, &user.BlueskyDID, &user.BlueskyAppPassword,
+		&user.BlueskyAccessJwt, &user.BlueskyRefreshJwt,
 		&user.APIURLToken, &user.APIHeaderTokenHash, &user.APIHeaderTokenEnabled,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
@@ -249,8 +345,16 @@ func (s *Store) GetUserByAPIToken(ctx context.Context, apiToken uuid.UUID) (*Use
 	err := s.db.QueryRowContext(ctx, `
 		SELECT id, spotify_user_id, spotify_access_token, spotify_refresh_token, spotify_token_expires_at,
 			misskey_instance_url, misskey_access_token, misskey_user_id, misskey_username,
-			misskey_avatar_url, misskey_host, twitter_access_token, twitter_refresh_token,
+			misskey_avatar_url, misskey_host,
+			misskey_visibility, misskey_cw, misskey_local_only, misskey_channel_id,
+			misskey_reaction_acceptance,
+			twitter_access_token, twitter_refresh_token,
 			twitter_token_expires_at, twitter_user_id, twitter_username, twitter_avatar_url,
+			twitter_reconnect_required,
+			mastodon_instance_url, mastodon_access_token, mastodon_user_id, mastodon_username,
+			mastodon_avatar_url, mastodon_host,
+			bluesky_pds_url, bluesky_handle, bluesky_did, bluesky_app_password,
+			bluesky_access_jwt, bluesky_refresh_jwt,
 			api_url_token, api_header_token_hash, api_header_token_enabled,
 			created_at, updated_at
 		FROM users WHERE api_url_token = $1
@@ -258,8 +362,15 @@ func (s *Store) GetUserByAPIToken(ctx context.Context, apiToken uuid.UUID) (*Use
 		&user.ID, &user.SpotifyUserID, &user.SpotifyAccessToken, &user.SpotifyRefreshToken,
 		&user.SpotifyTokenExpiresAt, &user.MisskeyInstanceURL, &user.MisskeyAccessToken,
 		&user.MisskeyUserID, &user.MisskeyUsername, &user.MisskeyAvatarURL, &user.MisskeyHost,
+		&user.MisskeyNote.Visibility, &user.MisskeyNote.CW, &user.MisskeyNote.LocalOnly,
+		&user.MisskeyNote.ChannelID, &user.MisskeyNote.ReactionAcceptance,
 		&user.TwitterAccessToken, &user.TwitterRefreshToken, &user.TwitterTokenExpiresAt,
 		&user.TwitterUserID, &user.TwitterUsername, &user.TwitterAvatarURL,
+		&user.TwitterReconnectRequired,
+		&user.MastodonInstanceURL, &user.MastodonAccessToken, &user.MastodonUserID,
+		&user.MastodonUsername, &user.MastodonAvatarURL, &user.MastodonHost,
+		&user.BlueskyPDSURL, &user.BlueskyHandle, &user.BlueskyDID, &user.BlueskyAppPassword,
+		&user.BlueskyAccessJwt, &user.BlueskyRefreshJwt,
 		&user.APIURLToken, &user.APIHeaderTokenHash, &user.APIHeaderTokenEnabled,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
@@ -277,6 +388,21 @@ func (s *Store) GetUserByAPIToken(ctx context.Context, apiToken uuid.UUID) (*Use
 	return user, nil
 }
 
+// GetUserByAPIHeaderTokenHash retrieves the user whose enabled API header token has the given hash
+func (s *Store) GetUserByAPIHeaderTokenHash(ctx context.Context, tokenHash string) (*User, error) {
+	var id uuid.UUID
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id FROM users WHERE api_header_token_hash = $1 AND api_header_token_enabled
+	`, tokenHash).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return s.GetUserByID(ctx, id)
+}
+
 // UpdateMisskeyToken updates the Misskey token and user information for a user
 func (s *Store) UpdateMisskeyToken(ctx context.Context, userID uuid.UUID, instanceURL, accessToken, misskeyUserID, username, avatarURL, host string) error {
 	encAccessToken, err := crypto.EncryptToken(accessToken)
@@ -301,6 +427,24 @@ func (s *Store) UpdateMisskeyToken(ctx context.Context, userID uuid.UUID, instan
 	return nil
 }
 
+// UpdateMisskeyNoteSettings updates the user's default options for Misskey notes
+func (s *Store) UpdateMisskeyNoteSettings(ctx context.Context, userID uuid.UUID, settings MisskeyNoteSettings) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE users SET
+			misskey_visibility = $2,
+			misskey_cw = $3,
+			misskey_local_only = $4,
+			misskey_channel_id = $5,
+			misskey_reaction_acceptance = $6,
+			updated_at = NOW()
+		WHERE id = $1
+	`, userID, settings.Visibility, settings.CW, settings.LocalOnly, settings.ChannelID, settings.ReactionAcceptance)
+	if err != nil {
+		return fmt.Errorf("failed to update misskey note settings: %w", err)
+	}
+	return nil
+}
+
 // UpdateTwitterToken updates the Twitter token and user information for a user
 func (s *Store) UpdateTwitterToken(ctx context.Context, userID uuid.UUID, accessToken, refreshToken string, expiresAt time.Time, twitterUserID, username, avatarURL string) error {
 	encAccessToken, err := crypto.EncryptToken(accessToken)
@@ -320,6 +464,7 @@ func (s *Store) UpdateTwitterToken(ctx context.Context, userID uuid.UUID, access
 			twitter_user_id = $5,
 			twitter_username = $6,
 			twitter_avatar_url = $7,
+			twitter_reconnect_required = FALSE,
 			updated_at = NOW()
 		WHERE id = $1
 	`, userID, encAccessToken, encRefreshToken, expiresAt, twitterUserID, username, avatarURL)
@@ -329,6 +474,86 @@ func (s *Store) UpdateTwitterToken(ctx context.Context, userID uuid.UUID, access
 	return nil
 }
 
+// UpdateMastodonToken updates the Mastodon token and user information for a user
+func (s *Store) UpdateMastodonToken(ctx context.Context, userID uuid.UUID, instanceURL, accessToken, mastodonUserID, username, avatarURL, host string) error {
+	encAccessToken, err := crypto.EncryptToken(accessToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt mastodon token: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE users SET
+			mastodon_instance_url = $2,
+			mastodon_access_token = $3,
+			mastodon_user_id = $4,
+			mastodon_username = $5,
+			mastodon_avatar_url = $6,
+			mastodon_host = $7,
+			updated_at = NOW()
+		WHERE id = $1
+	`, userID, instanceURL, encAccessToken, mastodonUserID, username, avatarURL, host)
+	if err != nil {
+		return fmt.Errorf("failed to update mastodon token: %w", err)
+	}
+	return nil
+}
+
+// UpdateBlueskyAccount updates the Bluesky account, app password and session for a user
+func (s *Store) UpdateBlueskyAccount(ctx context.Context, userID uuid.UUID, pdsURL, handle, did, appPassword, accessJwt, refreshJwt string) error {
+	encAppPassword, err := crypto.EncryptToken(appPassword)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt bluesky app password: %w", err)
+	}
+	encAccessJwt, err := crypto.EncryptToken(accessJwt)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt bluesky access jwt: %w", err)
+	}
+	encRefreshJwt, err := crypto.EncryptToken(refreshJwt)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt bluesky refresh jwt: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE users SET
+			bluesky_pds_url = $2,
+			bluesky_handle = $3,
+			bluesky_did = $4,
+			bluesky_app_password = $5,
+			bluesky_access_jwt = $6,
+			bluesky_refresh_jwt = $7,
+			updated_at = NOW()
+		WHERE id = $1
+	`, userID, pdsURL, handle, did, encAppPassword, encAccessJwt, encRefreshJwt)
+	if err != nil {
+		return fmt.Errorf("failed to update bluesky account: %w", err)
+	}
+	return nil
+}
+
+// UpdateBlueskySession updates the Bluesky session tokens for a user
+func (s *Store) UpdateBlueskySession(ctx context.Context, userID uuid.UUID, accessJwt, refreshJwt string) error {
+	encAccessJwt, err := crypto.EncryptToken(accessJwt)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt bluesky access jwt: %w", err)
+	}
+	encRefreshJwt, err := crypto.EncryptToken(refreshJwt)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt bluesky refresh jwt: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE users SET
+			bluesky_access_jwt = $2,
+			bluesky_refresh_jwt = $3,
+			updated_at = NOW()
+		WHERE id = $1
+	`, userID, encAccessJwt, encRefreshJwt)
+	if err != nil {
+		return fmt.Errorf("failed to update bluesky session: %w", err)
+	}
+	return nil
+}
+
 // UpdateSpotifyToken updates the Spotify token for a user
 func (s *Store) UpdateSpotifyToken(ctx context.Context, userID uuid.UUID, accessToken, refreshToken string, expiresAt time.Time) error {
 	encAccessToken, err := crypto.EncryptToken(accessToken)
@@ -406,6 +631,11 @@ func (s *Store) DisconnectMisskey(ctx context.Context, userID uuid.UUID) error {
 		UPDATE users SET
 			misskey_instance_url = NULL,
 			misskey_access_token = NULL,
+			misskey_visibility = DEFAULT,
+			misskey_cw = NULL,
+			misskey_local_only = DEFAULT,
+			misskey_channel_id = NULL,
+			misskey_reaction_acceptance = NULL,
 			updated_at = NOW()
 		WHERE id = $1
 	`, userID)
@@ -422,6 +652,7 @@ func (s *Store) DisconnectTwitter(ctx context.Context, userID uuid.UUID) error {
 			twitter_access_token = NULL,
 			twitter_refresh_token = NULL,
 			twitter_token_expires_at = NULL,
+			twitter_reconnect_required = FALSE,
 			updated_at = NOW()
 		WHERE id = $1
 	`, userID)
@@ -431,6 +662,51 @@ func (s *Store) DisconnectTwitter(ctx context.Context, userID uuid.UUID) error {
 	return nil
 }
 
+// MarkTwitterReconnectRequired flags that the user's Twitter token can no longer be refreshed
+func (s *Store) MarkTwitterReconnectRequired(ctx context.Context, userID uuid.UUID) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE users SET
+			twitter_reconnect_required = TRUE,
+			updated_at = NOW()
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark twitter reconnect required: %w", err)
+	}
+	return nil
+}
+
+// DisconnectMastodon disconnects Mastodon from the user account
+func (s *Store) DisconnectMastodon(ctx context.Context, userID uuid.UUID) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE users SET
+			mastodon_instance_url = NULL,
+			mastodon_access_token = NULL,
+			updated_at = NOW()
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to disconnect mastodon: %w", err)
+	}
+	return nil
+}
+
+// DisconnectBluesky disconnects Bluesky from the user account
+func (s *Store) DisconnectBluesky(ctx context.Context, userID uuid.UUID) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE users SET
+			bluesky_app_password = NULL,
+			bluesky_access_jwt = NULL,
+			bluesky_refresh_jwt = NULL,
+			updated_at = NOW()
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to disconnect bluesky: %w", err)
+	}
+	return nil
+}
+
 // MiAuth Session operations
 
 // CreateMiAuthSession creates a new MiAuth session
@@ -529,5 +805,9 @@ func (s *Store) CleanupExpiredSessions(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to cleanup twitter pkce sessions: %w", err)
 	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM mastodon_oauth_sessions WHERE expires_at < NOW()`)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup mastodon oauth sessions: %w", err)
+	}
 	return nil
 }
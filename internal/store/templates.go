@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// GetPostTemplates returns the user's post templates keyed by platform
+func (s *Store) GetPostTemplates(ctx context.Context, userID uuid.UUID) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT platform, template FROM post_templates WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post templates: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	templates := make(map[string]string)
+	for rows.Next() {
+		var platform, template string
+		if err := rows.Scan(&platform, &template); err != nil {
+			return nil, fmt.Errorf("failed to scan post template: %w", err)
+		}
+		templates[platform] = template
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get post templates: %w", err)
+	}
+	return templates, nil
+}
+
+// UpsertPostTemplate creates or updates the user's post template for a platform
+func (s *Store) UpsertPostTemplate(ctx context.Context, userID uuid.UUID, platform, template string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO post_templates (user_id, platform, template)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, platform) DO UPDATE SET
+			template = EXCLUDED.template,
+			updated_at = NOW()
+	`, userID, platform, template)
+	if err != nil {
+		return fmt.Errorf("failed to upsert post template: %w", err)
+	}
+	return nil
+}
+
+// DeletePostTemplate deletes the user's post template for a platform
+func (s *Store) DeletePostTemplate(ctx context.Context, userID uuid.UUID, platform string) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM post_templates WHERE user_id = $1 AND platform = $2
+	`, userID, platform)
+	if err != nil {
+		return fmt.Errorf("failed to delete post template: %w", err)
+	}
+	return nil
+}